@@ -11,6 +11,8 @@ import (
 
 var (
 	recipePath string
+	plan       bool
+	confirm    bool
 )
 
 // Cmd represents the cook command
@@ -18,10 +20,13 @@ var Cmd = &cobra.Command{
 	Use:   "cook",
 	Short: "Cook commands",
 	Long: `Cook cmd runs the recipe. Example: hotpot cook -r ./recipe.yaml.
-Add -v or --verbose to enable verbose output.`,
+Add -v or --verbose to enable verbose output.
+Use --plan to print the diff of every helm release without changing the cluster,
+or --confirm to review each release change before it is applied.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		verbose := cmd.Flag("verbose").Value.String() == "true"
-		must.Succeed(progress.RunTask(cook(verbose), true))
+		// the spinner would garble diffs and prompts
+		must.Succeed(progress.RunTask(cook(verbose), !plan && !confirm))
 	},
 }
 
@@ -33,6 +38,14 @@ func cook(verbose bool) func() error {
 				Pre: func(r *recipe.Recipe) error {
 					style := lipgloss.NewStyle().Bold(true)
 					r.Debug = verbose
+					r.Plan = plan
+					if confirm {
+						r.Confirm = confirmRelease
+					}
+					if plan {
+						fmt.Println(style.Render("📋 Planning..."))
+						return nil
+					}
 					fmt.Println(style.Render("🍲 Cooking..."))
 					return nil
 				},
@@ -44,8 +57,22 @@ func cook(verbose bool) func() error {
 	}
 }
 
+// confirmRelease prints the diff of a release and asks whether to apply it
+func confirmRelease(release, diff string) bool {
+	fmt.Printf("📋 %s:\n%s", release, diff)
+	fmt.Printf("Apply changes to %s? (y/n)\n", release)
+	var response string
+	if _, err := fmt.Scanln(&response); err != nil {
+		return false
+	}
+	return response == "y"
+}
+
 func init() {
 	Cmd.Flags().StringVarP(&recipePath, "recipe", "r", "./recipe.yaml", "yaml config file path (default is ./recipe.yaml)")
+	Cmd.Flags().BoolVar(&plan, "plan", false, "print the diff of every helm release without applying it")
+	Cmd.Flags().BoolVar(&confirm, "confirm", false, "ask before applying each helm release change")
+	Cmd.MarkFlagsMutuallyExclusive("plan", "confirm")
 
 	_ = Cmd.MarkFlagRequired("recipe")
 }
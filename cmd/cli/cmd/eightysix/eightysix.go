@@ -3,6 +3,7 @@ package eightysix
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
 	"github.com/zcubbs/hotpot/pkg/x/progress"
 	"os"
@@ -11,6 +12,9 @@ import (
 
 var silent bool
 var purgeExtraDirs []string
+var snapshotFirst bool
+var snapshotDir string
+var distributionName string
 
 // Cmd represents the cook command
 var Cmd = &cobra.Command{
@@ -27,6 +31,11 @@ var Cmd = &cobra.Command{
 }
 
 func clearCluster(verbose bool) error {
+	dist, err := selectDistribution()
+	if err != nil {
+		return err
+	}
+
 	// if not silent prompt for confirmation
 	if !silent {
 		fmt.Println("Are you sure you want to clear the cluster? (y/n)")
@@ -39,13 +48,34 @@ func clearCluster(verbose bool) error {
 			fmt.Println("Aborted.")
 			return nil
 		}
+
+		if !snapshotFirst && dist.Name() == distribution.K3s && hasDatastore() {
+			fmt.Println("Take a snapshot of the datastore before clearing? (y/n)")
+			_, err := fmt.Scanln(&response)
+			if err != nil {
+				return fmt.Errorf("failed to read response \n %w", err)
+			}
+			snapshotFirst = response == "y"
+		}
 	}
 
 	return progress.RunTask(func() error {
+		if snapshotFirst {
+			if dist.Name() != distribution.K3s {
+				return fmt.Errorf("snapshots are only supported with the k3s distribution")
+			}
+			fmt.Printf("Saving snapshot...\n")
+			s, err := k3s.SaveSnapshot(k3s.SnapshotConfig{Dir: snapshotDir}, verbose)
+			if err != nil {
+				return fmt.Errorf("failed to save snapshot, cluster left untouched \n %w", err)
+			}
+			fmt.Printf("    └─ %s\n", s.Location)
+		}
+
 		fmt.Printf("Clearing cluster...\n")
-		fmt.Printf("    ├─ uninstalling k3s... \n")
-		err := k3s.Uninstall(verbose)
-		if err != nil && !strings.Contains(err.Error(), "no such file or directory") { // ignore if k3s is not installed
+		fmt.Printf("    ├─ uninstalling %s... \n", dist.Name())
+		err := dist.Uninstall(verbose)
+		if err != nil && !strings.Contains(err.Error(), "no such file or directory") { // ignore if not installed
 			return err
 		}
 
@@ -62,6 +92,23 @@ func clearCluster(verbose bool) error {
 	}, true)
 }
 
+// selectDistribution returns the --distribution one, or the one installed
+// on the node, falling back to k3s
+func selectDistribution() (distribution.Distribution, error) {
+	if distributionName != "" {
+		return distribution.Get(distributionName)
+	}
+	if dist, err := distribution.Detect(); err == nil {
+		return dist, nil
+	}
+	return distribution.Get(distribution.K3s)
+}
+
+func hasDatastore() bool {
+	_, err := k3s.DetectDatastore(k3s.DefaultDataDir)
+	return err == nil
+}
+
 func purgeDir(dir string) error {
 	// delete dir
 	err := os.RemoveAll(dir)
@@ -75,4 +122,7 @@ func purgeDir(dir string) error {
 func init() {
 	Cmd.Flags().BoolVarP(&silent, "silent", "s", false, "silent exec")
 	Cmd.Flags().StringSliceVarP(&purgeExtraDirs, "purge", "p", []string{}, "extra dirs to purge")
+	Cmd.Flags().StringVarP(&distributionName, "distribution", "d", "", "distribution to uninstall, k3s or rke2 (default detected)")
+	Cmd.Flags().BoolVar(&snapshotFirst, "snapshot", false, "save a datastore snapshot before clearing")
+	Cmd.Flags().StringVar(&snapshotDir, "snapshot-dir", k3s.DefaultSnapshotDir, "dir to save the snapshot to")
 }
@@ -0,0 +1,50 @@
+package helm
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	helmclient "github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"os"
+	"path/filepath"
+)
+
+var (
+	kubeconfig string
+	namespace  string
+)
+
+// Cmd represents the helm command
+var Cmd = &cobra.Command{
+	Use:   "helm",
+	Short: "Inspect and roll back helm releases",
+	Long: `Show the revisions of a release and roll it back.
+The kubeconfig is taken from -k, KUBECONFIG or ~/.kube/config, then from the installed distribution.`,
+}
+
+func init() {
+	Cmd.AddCommand(historyCmd)
+	Cmd.AddCommand(rollbackCmd)
+
+	Cmd.PersistentFlags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "kubeconfig path")
+	Cmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "default", "release namespace")
+}
+
+func newClient(debug bool) *helmclient.Client {
+	client := helmclient.NewClient()
+	client.Settings.Debug = debug
+	client.Settings.SetNamespace(namespace)
+
+	switch {
+	case kubeconfig != "":
+		client.Settings.KubeConfig = kubeconfig
+	case os.Getenv("KUBECONFIG") == "":
+		home, _ := os.UserHomeDir()
+		if _, err := os.Stat(filepath.Join(home, ".kube", "config")); err == nil {
+			break
+		}
+		if dist, err := distribution.Detect(); err == nil {
+			client.Settings.KubeConfig = dist.KubeconfigPath()
+		}
+	}
+	return client
+}
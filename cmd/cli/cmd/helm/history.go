@@ -0,0 +1,35 @@
+package helm
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <release>",
+	Short: "Show the revisions of a release",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+
+		h, err := newClient(verbose).History(args[0])
+		if err != nil {
+			return err
+		}
+		if len(h) == 0 {
+			return fmt.Errorf("release %s not found in namespace %s", args[0], namespace)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+		for _, r := range h {
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s-%s\t%s\t%s\n",
+				r.Version, r.Info.LastDeployed.Format(time.RFC3339), r.Info.Status,
+				r.Chart.Metadata.Name, r.Chart.Metadata.Version, r.Chart.Metadata.AppVersion, r.Info.Description)
+		}
+		return w.Flush()
+	},
+}
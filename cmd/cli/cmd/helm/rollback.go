@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	helmclient "github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"strconv"
+	"time"
+)
+
+var (
+	wait    bool
+	timeout time.Duration
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <release> [revision]",
+	Short: "Roll a release back",
+	Long: `Roll a release back to the given revision, or to its last deployed revision when omitted.
+Also recovers a release stuck in a pending state.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+
+		revision := 0
+		if len(args) == 2 {
+			r, err := strconv.Atoi(args[1])
+			if err != nil || r < 1 {
+				return fmt.Errorf("invalid revision %q", args[1])
+			}
+			revision = r
+		}
+
+		fmt.Printf("⏪ Rolling back %s...\n", args[0])
+		if err := newClient(verbose).Rollback(args[0], revision, wait, timeout); err != nil {
+			return err
+		}
+		fmt.Println("✅ Rollback complete")
+		return nil
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().BoolVar(&wait, "wait", true, "wait for the release resources to become ready")
+	rollbackCmd.Flags().DurationVar(&timeout, "timeout", helmclient.DefaultTimeout, "time to wait for the rollback")
+}
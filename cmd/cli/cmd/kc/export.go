@@ -0,0 +1,59 @@
+package kc
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+)
+
+var (
+	exportOpts kubernetes.ExportOptions
+	output     string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a standalone kubeconfig",
+	Long: `Export one context as a standalone kubeconfig with credentials embedded.
+Example: hotpot kc export --name prod --server https://k3s.example.com:6443 -o prod.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		kc, err := getKubeConfig(kubeconfig, verbose)
+		if err != nil {
+			return err
+		}
+
+		config, err := kubernetes.ExportKubeconfig(kc, exportOpts)
+		if err != nil {
+			return fmt.Errorf("failed to export kubeconfig: %w", err)
+		}
+
+		if output != "" {
+			if err := kubernetes.WriteKubeconfig(config, output); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Kubeconfig exported to %s\n", output)
+			return nil
+		}
+
+		b, err := kubernetes.SerializeKubeconfig(config)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+// addExportFlags registers the flags shared by export and merge
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&exportOpts.Context, "context", "", "context to export (default current context)")
+	cmd.Flags().StringVar(&exportOpts.Name, "name", "", "rename the context, cluster and user")
+	cmd.Flags().StringVarP(&exportOpts.Server, "server", "u", "", "override the server url")
+	cmd.Flags().StringVar(&exportOpts.CaFile, "ca-file", "", "embed this certificate authority instead")
+}
+
+func init() {
+	addExportFlags(exportCmd)
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "write to file instead of stdout")
+}
@@ -0,0 +1,68 @@
+package kc
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"time"
+)
+
+var (
+	issueOpts   kubernetes.IssueOptions
+	issueMethod string
+	issueOutput string
+)
+
+var issueCmd = &cobra.Command{
+	Use:   "issue",
+	Short: "Issue a scoped kubeconfig",
+	Long: `Issue a kubeconfig bound to a ClusterRole (view, edit, admin, ...) for a team member or CI.
+With --namespace the role is bound in that namespace only, otherwise cluster wide.
+--method serviceaccount creates a ServiceAccount and a token expiring after --ttl.
+--method certificate signs a client certificate expiring after --ttl.
+Example: hotpot kc issue --user alice --namespace hub --role edit --ttl 720h -o alice.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		kc, err := getKubeConfig(kubeconfig, verbose)
+		if err != nil {
+			return err
+		}
+
+		issueOpts.Method = kubernetes.IssueMethod(issueMethod)
+		config, err := kubernetes.IssueKubeconfig(context.Background(), kc, issueOpts)
+		if err != nil {
+			return fmt.Errorf("failed to issue kubeconfig: %w", err)
+		}
+
+		if issueOutput != "" {
+			if err := kubernetes.WriteKubeconfig(config, issueOutput); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Kubeconfig for %s written to %s\n", issueOpts.User, issueOutput)
+			return nil
+		}
+
+		b, err := kubernetes.SerializeKubeconfig(config)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+func init() {
+	f := issueCmd.Flags()
+	f.StringVar(&issueOpts.User, "user", "", "user or service account name")
+	f.StringVarP(&issueOpts.Namespace, "namespace", "n", "", "namespace to bind the role in (default cluster wide)")
+	f.StringVar(&issueOpts.Role, "role", "view", "cluster role to bind")
+	f.StringSliceVar(&issueOpts.Groups, "group", []string{}, "certificate groups (certificate method only)")
+	f.DurationVar(&issueOpts.TTL, "ttl", 720*time.Hour, "credentials lifetime")
+	f.StringVar(&issueMethod, "method", string(kubernetes.IssueServiceAccount), "serviceaccount or certificate")
+	f.StringVar(&issueOpts.ClusterName, "cluster-name", "hotpot", "cluster name in the issued kubeconfig")
+	f.StringVarP(&issueOpts.Server, "server", "u", "", "override the server url")
+	f.StringVarP(&issueOutput, "output", "o", "", "write to file instead of stdout")
+
+	_ = issueCmd.MarkFlagRequired("user")
+}
@@ -8,14 +8,19 @@ import (
 	"github.com/zcubbs/hotpot/pkg/x/progress"
 )
 
-var url string
+var (
+	url        string
+	kubeconfig string
+)
 
-// Cmd represents the cook command
+// Cmd represents the kc command
 var Cmd = &cobra.Command{
 	Use:   "kc",
-	Short: "Print kubeconfig",
-	Long: `Use -u or --url to override server url.
-Example: hotpot kc -u https://localhost:6443`,
+	Short: "Print and manage kubeconfig",
+	Long: `Print the current kubeconfig context. Use -u or --url to override server url.
+Example: hotpot kc -u https://localhost:6443
+
+The kubeconfig is looked up in KUBECONFIG, ~/.kube/config then /etc/rancher/k3s/k3s.yaml, unless -k is set.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		verbose := cmd.Flag("verbose").Value.String() == "true"
 		must.Succeed(progress.RunTask(printKc(verbose), true))
@@ -24,18 +29,14 @@ Example: hotpot kc -u https://localhost:6443`,
 
 func printKc(verbose bool) func() error {
 	return func() error {
-		kc, err := getKubeConfig("", verbose)
+		kc, err := getKubeConfig(kubeconfig, verbose)
 		if err != nil {
 			return err
 		}
 
-		if kc != "" {
-			err := k3s.PrintKubeconfig(kc, url)
-			if err != nil {
-				return fmt.Errorf("failed to print kubeconfig \n %w", err)
-			}
-		} else {
-			return fmt.Errorf("kubeconfig not found")
+		err = k3s.PrintKubeconfig(kc, url)
+		if err != nil {
+			return fmt.Errorf("failed to print kubeconfig \n %w", err)
 		}
 
 		return nil
@@ -43,5 +44,11 @@ func printKc(verbose bool) func() error {
 }
 
 func init() {
+	Cmd.AddCommand(exportCmd)
+	Cmd.AddCommand(mergeCmd)
+	Cmd.AddCommand(useCmd)
+	Cmd.AddCommand(issueCmd)
+
+	Cmd.PersistentFlags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "source kubeconfig path")
 	Cmd.Flags().StringVarP(&url, "url", "u", "", "override kubeconfig url")
 }
@@ -2,52 +2,76 @@ package kc
 
 import (
 	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func getKubeConfig(path string, debug bool) (string, error) {
 	const (
-		found             = "kubeconfig found in default location %s\n"
-		notFound          = "kubeconfig not found in default location %s\n"
+		found             = "kubeconfig found in %s\n"
+		notFound          = "kubeconfig not found in %s\n"
 		rancherKubeconfig = "/etc/rancher/k3s/k3s.yaml"
 	)
 	if path != "" {
 		return path, nil
 	}
 
+	// KUBECONFIG first, as kubectl does. It may hold a list of files
+	for _, kc := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if _, err := os.Stat(kc); err == nil {
+			fmt.Fprintf(os.Stderr, found, kc)
+			return kc, nil
+		}
+		if debug {
+			fmt.Fprintf(os.Stderr, notFound, kc)
+		}
+	}
+
 	hd, err := getUserHomeDir()
 	if err != nil {
 		return "", err
 	}
 
-	kc := fmt.Sprintf("%s/.kube/config", hd)
-	fi, err := os.Stat(kc)
-	if err != nil && os.IsNotExist(err) && debug {
-		fmt.Printf(notFound, kc)
+	for _, kc := range []string{filepath.Join(hd, ".kube", "config"), rancherKubeconfig} {
+		if _, err := os.Stat(kc); err == nil {
+			fmt.Fprintf(os.Stderr, found, kc)
+			return kc, nil
+		}
+		if debug {
+			fmt.Fprintf(os.Stderr, notFound, kc)
+		}
 	}
 
-	if fi != nil {
-		fmt.Printf(found, kc)
-		return kc, nil
-	}
+	return "", fmt.Errorf("no kubeconfig found in KUBECONFIG or default locations")
+}
 
-	fi, err = os.Stat(rancherKubeconfig)
-	if err != nil && os.IsNotExist(err) && debug {
-		fmt.Printf(notFound, rancherKubeconfig)
+// getDistributionKubeconfig returns path when set, else the kubeconfig written
+// by the k3s or rke2 service of this node
+func getDistributionKubeconfig(path string, debug bool) (string, error) {
+	if path != "" {
+		return path, nil
 	}
-
-	if fi != nil {
-		fmt.Printf(found, rancherKubeconfig)
-		return rancherKubeconfig, nil
+	for _, kc := range kubernetes.DistributionKubeconfigs {
+		if _, err := os.Stat(kc); err == nil {
+			if debug {
+				fmt.Fprintf(os.Stderr, "kubeconfig found in %s\n", kc)
+			}
+			return kc, nil
+		}
 	}
+	return "", fmt.Errorf("no k3s or rke2 kubeconfig found in %s, set the source with -k",
+		strings.Join(kubernetes.DistributionKubeconfigs, ", "))
+}
 
-	kc = os.Getenv("KUBECONFIG")
-	if kc == "" {
-		return "", fmt.Errorf("KUBECONFIG variable not set, and no kubeconfig found in default locations")
+// defaultMergeTarget is the kubeconfig kubectl reads by default
+func defaultMergeTarget() (string, error) {
+	hd, err := getUserHomeDir()
+	if err != nil {
+		return "", err
 	}
-
-	fmt.Printf(found, kc)
-	return kc, nil
+	return filepath.Join(hd, ".kube", "config"), nil
 }
 
 func getUserHomeDir() (string, error) {
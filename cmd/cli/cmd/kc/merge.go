@@ -0,0 +1,59 @@
+package kc
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+)
+
+var (
+	into      string
+	overwrite bool
+	switchTo  bool
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge into ~/.kube/config",
+	Long: `Export one context and merge it into ~/.kube/config (or --into).
+The context is read from the k3s or rke2 kubeconfig of this node unless -k is set.
+Entries that already exist with different content are refused unless --overwrite is set.
+Example: hotpot kc merge --name prod --server https://k3s.example.com:6443`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		kc, err := getDistributionKubeconfig(kubeconfig, verbose)
+		if err != nil {
+			return err
+		}
+
+		dest := into
+		if dest == "" {
+			dest, err = defaultMergeTarget()
+			if err != nil {
+				return err
+			}
+		}
+		if kubernetes.SamePath(dest, kc) {
+			return fmt.Errorf("source and target kubeconfig are the same file %s", kc)
+		}
+
+		config, err := kubernetes.ExportKubeconfig(kc, exportOpts)
+		if err != nil {
+			return fmt.Errorf("failed to export kubeconfig: %w", err)
+		}
+
+		if err := kubernetes.MergeKubeconfig(config, dest, overwrite, switchTo); err != nil {
+			return fmt.Errorf("failed to merge kubeconfig: %w", err)
+		}
+
+		fmt.Printf("✅ Context %s merged into %s\n", config.CurrentContext, dest)
+		return nil
+	},
+}
+
+func init() {
+	addExportFlags(mergeCmd)
+	mergeCmd.Flags().StringVar(&into, "into", "", "target kubeconfig (default ~/.kube/config)")
+	mergeCmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace conflicting clusters, users and contexts")
+	mergeCmd.Flags().BoolVar(&switchTo, "use", false, "switch the current context to the merged one")
+}
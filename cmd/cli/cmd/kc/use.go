@@ -0,0 +1,44 @@
+package kc
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"sort"
+)
+
+var useCmd = &cobra.Command{
+	Use:   "use [context]",
+	Short: "Switch the current context",
+	Long:  `Switch the current context of the kubeconfig. Without argument, lists the available contexts.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		kc, err := getKubeConfig(kubeconfig, verbose)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			names, current, err := kubernetes.ListContexts(kc)
+			if err != nil {
+				return err
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				marker := " "
+				if name == current {
+					marker = "*"
+				}
+				fmt.Printf("%s %s\n", marker, name)
+			}
+			return nil
+		}
+
+		if err := kubernetes.UseContext(kc, args[0]); err != nil {
+			return fmt.Errorf("failed to switch context: %w", err)
+		}
+		fmt.Printf("✅ Switched to context %s\n", args[0])
+		return nil
+	},
+}
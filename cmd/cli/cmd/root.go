@@ -5,7 +5,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/zcubbs/hotpot/cmd/cli/cmd/cook"
 	"github.com/zcubbs/hotpot/cmd/cli/cmd/eightysix"
+	"github.com/zcubbs/hotpot/cmd/cli/cmd/helm"
 	"github.com/zcubbs/hotpot/cmd/cli/cmd/kc"
+	"github.com/zcubbs/hotpot/cmd/cli/cmd/snapshot"
+	"github.com/zcubbs/hotpot/cmd/cli/cmd/status"
+	"github.com/zcubbs/hotpot/cmd/cli/cmd/supportbundle"
 	"github.com/zcubbs/hotpot/cmd/cli/cmd/syncd"
 	"os"
 )
@@ -59,6 +63,10 @@ func init() {
 	rootCmd.AddCommand(kc.Cmd)
 	rootCmd.AddCommand(eightysix.Cmd)
 	rootCmd.AddCommand(syncd.Cmd)
+	rootCmd.AddCommand(snapshot.Cmd)
+	rootCmd.AddCommand(helm.Cmd)
+	rootCmd.AddCommand(status.Cmd)
+	rootCmd.AddCommand(supportbundle.Cmd)
 }
 
 func About() {
@@ -0,0 +1,38 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List datastore snapshots",
+	Long:    `List local and S3 snapshots, oldest first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		c, err := config(cmd)
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := k3s.ListSnapshots(c, verbose)
+		if err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tLOCATION\tSIZE\tCREATED")
+		for _, s := range snapshots {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				s.Name, s.Location, osx.BytesToString(uint64(s.Size)), s.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,27 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete snapshots beyond retention",
+	Long:  `Delete all but the newest --retention snapshots, locally and in S3 when enabled.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		c, err := config(cmd)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("🧹 Pruning snapshots...")
+		if err := k3s.PruneSnapshots(c, verbose); err != nil {
+			return fmt.Errorf("failed to prune snapshots: %w", err)
+		}
+		fmt.Println("✅ Snapshots pruned")
+		return nil
+	},
+}
@@ -0,0 +1,29 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore a datastore snapshot",
+	Long: `Stop k3s, restore the named snapshot and start k3s again.
+The name is as printed by 'hotpot snapshot list'. Snapshots missing locally are fetched from S3 when enabled.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		c, err := config(cmd)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("⏪ Restoring snapshot %s...\n", args[0])
+		if err := k3s.RestoreSnapshot(c, args[0], verbose); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+		fmt.Println("✅ Snapshot restored")
+		return nil
+	},
+}
@@ -0,0 +1,41 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+)
+
+var prune bool
+
+var saveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Take a datastore snapshot",
+	Long:  `Take a snapshot of the k3s datastore and upload it to S3 when enabled.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose := cmd.Flag("verbose").Value.String() == "true"
+		c, err := config(cmd)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("📸 Saving snapshot...")
+		s, err := k3s.SaveSnapshot(c, verbose)
+		if err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+		fmt.Printf("✅ Snapshot saved: %s\n", s.Location)
+
+		if prune {
+			if err := k3s.PruneSnapshots(c, verbose); err != nil {
+				return fmt.Errorf("failed to prune snapshots: %w", err)
+			}
+			fmt.Println("✅ Snapshots pruned")
+		}
+		return nil
+	},
+}
+
+func init() {
+	saveCmd.Flags().BoolVar(&prune, "prune", false, "prune snapshots beyond retention after saving")
+}
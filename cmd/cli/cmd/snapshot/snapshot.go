@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+	"github.com/zcubbs/hotpot/pkg/recipe"
+)
+
+var (
+	recipePath string
+	cfg        k3s.SnapshotConfig
+)
+
+// Cmd represents the snapshot command
+var Cmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Datastore snapshot commands",
+	Long: `Save, list, restore and prune snapshots of the k3s datastore (embedded etcd or sqlite).
+Settings are read from the recipe k3s.snapshots block when -r is set, flags take precedence.`,
+}
+
+func init() {
+	Cmd.AddCommand(saveCmd)
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(restoreCmd)
+	Cmd.AddCommand(pruneCmd)
+
+	f := Cmd.PersistentFlags()
+	f.StringVarP(&recipePath, "recipe", "r", "", "yaml config file path to read k3s.snapshots from")
+	f.StringVar(&cfg.DataDir, "data-dir", k3s.DefaultDataDir, "k3s data dir")
+	f.StringVar(&cfg.Dir, "dir", k3s.DefaultSnapshotDir, "local snapshot dir")
+	f.StringVar(&cfg.Name, "name", k3s.DefaultSnapshotName, "snapshot name prefix")
+	f.IntVar(&cfg.Retention, "retention", k3s.DefaultSnapshotRetention, "number of snapshots to keep when pruning")
+	f.BoolVar(&cfg.S3.Enabled, "s3", false, "enable the S3 target")
+	f.StringVar(&cfg.S3.Endpoint, "s3-endpoint", "", "S3 endpoint, e.g. http://127.0.0.1:9000")
+	f.StringVar(&cfg.S3.EndpointCA, "s3-endpoint-ca", "", "S3 endpoint CA bundle")
+	f.StringVar(&cfg.S3.Bucket, "s3-bucket", "", "S3 bucket")
+	f.StringVar(&cfg.S3.Folder, "s3-folder", "", "S3 folder")
+	f.StringVar(&cfg.S3.Region, "s3-region", "", "S3 region")
+	f.StringVar(&cfg.S3.AccessKey, "s3-access-key", "", "S3 access key, accepts secret provider references")
+	f.StringVar(&cfg.S3.SecretKey, "s3-secret-key", "", "S3 secret key, accepts secret provider references")
+	f.BoolVar(&cfg.S3.Insecure, "s3-insecure", false, "use plain http for the S3 endpoint")
+	f.BoolVar(&cfg.S3.SkipSSLVerify, "s3-skip-ssl-verify", false, "skip S3 endpoint certificate verification")
+}
+
+// config returns the snapshot config from the recipe, if any, with
+// explicitly set flags applied on top
+func config(cmd *cobra.Command) (k3s.SnapshotConfig, error) {
+	if recipePath == "" {
+		return cfg, nil
+	}
+
+	r, err := recipe.Load(recipePath)
+	if err != nil {
+		return k3s.SnapshotConfig{}, err
+	}
+	c := recipe.K3sSnapshotConfig(r)
+
+	flags := cmd.Flags()
+	override := func(name string, dst *string, src string) {
+		if flags.Changed(name) {
+			*dst = src
+		}
+	}
+	override("data-dir", &c.DataDir, cfg.DataDir)
+	override("dir", &c.Dir, cfg.Dir)
+	override("name", &c.Name, cfg.Name)
+	override("s3-endpoint", &c.S3.Endpoint, cfg.S3.Endpoint)
+	override("s3-endpoint-ca", &c.S3.EndpointCA, cfg.S3.EndpointCA)
+	override("s3-bucket", &c.S3.Bucket, cfg.S3.Bucket)
+	override("s3-folder", &c.S3.Folder, cfg.S3.Folder)
+	override("s3-region", &c.S3.Region, cfg.S3.Region)
+	override("s3-access-key", &c.S3.AccessKey, cfg.S3.AccessKey)
+	override("s3-secret-key", &c.S3.SecretKey, cfg.S3.SecretKey)
+	if flags.Changed("retention") {
+		c.Retention = cfg.Retention
+	}
+	if flags.Changed("s3") {
+		c.S3.Enabled = cfg.S3.Enabled
+	}
+	if flags.Changed("s3-insecure") {
+		c.S3.Insecure = cfg.S3.Insecure
+	}
+	if flags.Changed("s3-skip-ssl-verify") {
+		c.S3.SkipSSLVerify = cfg.S3.SkipSSLVerify
+	}
+	return c, nil
+}
@@ -0,0 +1,126 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/recipe"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	recipePath string
+	output     string
+)
+
+// Cmd represents the status command
+var Cmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the health of the cluster and the recipe components",
+	Long: `Status shows node readiness, the distribution version, the syncd service state
+and, for every component the recipe enables, its helm release, deployments,
+cert-manager ClusterIssuers and ArgoCD applications.
+Use -o json for monitoring. The command exits non-zero when something is unhealthy.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := recipe.Status(recipePath, recipe.DefaultDependencies())
+		if err != nil {
+			return err
+		}
+
+		switch output {
+		case "json":
+			b, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+		case "table":
+			if err := printReport(report); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported output %q, expected table or json", output)
+		}
+
+		if !report.Healthy() {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return fmt.Errorf("cluster is not healthy")
+		}
+		return nil
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&recipePath, "recipe", "r", "./recipe.yaml", "yaml config file path (default is ./recipe.yaml)")
+	Cmd.Flags().StringVarP(&output, "output", "o", "table", "output format, table or json")
+}
+
+func printReport(report *recipe.StatusReport) error {
+	fmt.Printf("Distribution: %s %s\n", report.Distribution, report.Version)
+	fmt.Printf("Syncd:        %s\n", report.Syncd)
+	fmt.Printf("Cluster:      %s\n\n", readiness(report.ClusterReady))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NODE\tSTATUS\tVERSION")
+	for _, n := range report.Nodes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", n.Name, readiness(n.Ready), n.Version)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "COMPONENT\tNAMESPACE\tRELEASE\tREVISION\tCHART\tAPP VERSION\tDEPLOYMENTS\tHEALTH")
+	for _, c := range report.Components {
+		status, revision, chart, appVersion := "not installed", "-", "-", "-"
+		if c.Release != nil {
+			status = c.Release.Status
+			revision = fmt.Sprintf("%d", c.Release.Revision)
+			chart = c.Release.ChartVersion
+			appVersion = c.Release.AppVersion
+		}
+		ready := 0
+		for _, d := range c.Deployments {
+			if d.IsReady() {
+				ready++
+			}
+		}
+		health := "healthy"
+		if !c.Healthy {
+			health = "unhealthy"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+			c.Name, c.Namespace, status, revision, chart, appVersion, ready, len(c.Deployments), health)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, c := range report.Components {
+		for _, d := range c.Deployments {
+			if !d.IsReady() {
+				fmt.Printf("%s: deployment %s has %d/%d ready replicas\n", c.Name, d.Name, d.Ready, d.Replicas)
+			}
+		}
+		for _, i := range c.Issuers {
+			fmt.Printf("%s: ClusterIssuer %s is %s %s\n", c.Name, i.Name, readiness(i.Ready), i.Message)
+		}
+		for _, a := range c.Applications {
+			fmt.Printf("%s: Application %s is %s and %s\n", c.Name, a.Name, a.Sync, a.Health)
+		}
+		if len(c.Errors) > 0 {
+			fmt.Printf("%s: %s\n", c.Name, strings.Join(c.Errors, "; "))
+		}
+	}
+	for _, e := range report.Errors {
+		fmt.Println(e)
+	}
+	return nil
+}
+
+func readiness(ready bool) string {
+	if ready {
+		return "Ready"
+	}
+	return "NotReady"
+}
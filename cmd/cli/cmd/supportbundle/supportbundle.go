@@ -0,0 +1,41 @@
+package supportbundle
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/zcubbs/hotpot/pkg/recipe"
+	"time"
+)
+
+var (
+	recipePath string
+	output     string
+)
+
+// Cmd represents the support-bundle command
+var Cmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect troubleshooting information into a tarball",
+	Long: `Support-bundle gathers the redacted recipe, the distribution config and journal,
+node and pod status, recent events, helm release history, component logs,
+prerequisite check results and host facts into a single tarball to attach to tickets.
+Values of password, secret, token and key fields are redacted; secret references are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if output == "" {
+			output = fmt.Sprintf("hotpot-support-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+
+		fmt.Println("🧺 Collecting support bundle...")
+		if err := recipe.SupportBundle(recipePath, output, recipe.DefaultDependencies()); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Support bundle written to %s\n", output)
+		return nil
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&recipePath, "recipe", "r", "./recipe.yaml", "yaml config file path (default is ./recipe.yaml)")
+	Cmd.Flags().StringVarP(&output, "output", "o", "", "tarball path (default is ./hotpot-support-<timestamp>.tar.gz)")
+}
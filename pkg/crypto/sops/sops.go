@@ -1,71 +1,199 @@
 package sops
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/pgp"
+	"google.golang.org/grpc"
+	"io"
 	"os"
-	"os/exec"
+	"strings"
+	"time"
 )
 
-// Encrypt takes a string of plaintext data and returns the encrypted data
-func Encrypt(plaintext, pubKeyPath string) (string, error) {
-	// Create a temporary file
-	tempFile, err := os.CreateTemp("", "sops-encrypt-")
+// PgpKeyFileEnv names a pgp private key file used when Keys has none
+const PgpKeyFileEnv = "HOTPOT_SOPS_PGP_KEY_FILE"
+
+// Keys holds the key files decrypting sops files. Age identities are also read
+// from SOPS_AGE_KEY, SOPS_AGE_KEY_FILE and ~/.config/sops/age/keys.txt, pgp keys
+// from the GnuPG keyring when no key file decrypts the data key.
+type Keys struct {
+	// AgeKeyFile holds age identities, one per line
+	AgeKeyFile string
+	// PgpKeyFile holds armored or binary pgp private keys, without passphrase
+	PgpKeyFile string
+}
+
+// Decrypt takes a string of encrypted data and returns the plaintext. keyPath
+// is an age identities or pgp private key file, it may be empty.
+func Decrypt(encrypted, keyPath string) (string, error) {
+	keys, err := KeysFromFile(keyPath)
 	if err != nil {
-		return "", fmt.Errorf("error creating temp file: %v", err)
+		return "", err
 	}
-	defer os.Remove(tempFile.Name()) // Clean up
 
-	// Write plaintext to the temporary file
-	if _, err := tempFile.WriteString(plaintext); err != nil {
-		return "", fmt.Errorf("error writing to temp file: %v", err)
-	}
-	err = tempFile.Close()
+	b, err := DecryptData([]byte(encrypted), formats.Yaml, keys)
 	if err != nil {
-		return "", fmt.Errorf("error closing temp file: %v", err)
+		return "", err
 	}
+	return string(b), nil
+}
 
-	// Run sops to encrypt the file
-	encrypted, err := execSopsCommand("--pgp", pubKeyPath, "--encrypt", tempFile.Name())
+// KeysFromFile returns the Keys of an age identities or pgp private key file, none when path is empty
+func KeysFromFile(path string) (Keys, error) {
+	if path == "" {
+		return Keys{}, nil
+	}
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return Keys{}, fmt.Errorf("failed to read sops key \n %w", err)
+	}
+	if strings.Contains(string(b), "AGE-SECRET-KEY-") {
+		return Keys{AgeKeyFile: path}, nil
 	}
+	return Keys{PgpKeyFile: path}, nil
+}
 
-	return encrypted, nil
+// DecryptFile decrypts the sops file at path, its format given by its extension
+func DecryptFile(path string, keys Keys) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sops file \n %w", err)
+	}
+	return DecryptData(data, formats.FormatForPath(path), keys)
 }
 
-// Decrypt takes a string of encrypted data and returns the plaintext
-func Decrypt(encrypted, privKeyPath string) (string, error) {
-	// Create a temporary file
-	tempFile, err := os.CreateTemp("", "sops-decrypt-")
+// DecryptData decrypts sops data of format in-process, only age and pgp keys are supported
+func DecryptData(data []byte, format formats.Format, keys Keys) ([]byte, error) {
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(data)
 	if err != nil {
-		return "", fmt.Errorf("error creating temp file: %v", err)
+		return nil, fmt.Errorf("failed to load sops file \n %w", err)
 	}
-	defer os.Remove(tempFile.Name()) // Clean up
 
-	// Write encrypted data to the temporary file
-	if _, err := tempFile.WriteString(encrypted); err != nil {
-		return "", fmt.Errorf("error writing to temp file: %v", err)
+	ks, err := newKeyService(keys)
+	if err != nil {
+		return nil, err
 	}
-	err = tempFile.Close()
+	key, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{ks}, nil)
 	if err != nil {
-		return "", fmt.Errorf("error closing temp file: %v", err)
+		// the user error lists why each key failed, like the sops cli
+		if userErr, ok := err.(interface{ UserError() string }); ok {
+			return nil, fmt.Errorf("failed to get sops data key \n %s", userErr.UserError())
+		}
+		return nil, fmt.Errorf("failed to get sops data key \n %w", err)
 	}
 
-	// Run sops to decrypt the file
-	decrypted, err := execSopsCommand("--pgp", privKeyPath, "--decrypt", tempFile.Name())
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to decrypt sops file \n %w", err)
+	}
+	originalMac, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, key, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops mac \n %w", err)
+	}
+	if originalMac != mac {
+		return nil, fmt.Errorf("failed to verify sops file integrity, the mac does not match")
 	}
 
-	return decrypted, nil
+	return store.EmitPlainFile(tree.Branches)
 }
 
-// helper function to execute sops command
-func execSopsCommand(args ...string) (string, error) {
-	cmd := exec.Command("sops", args...)
-	output, err := cmd.CombinedOutput()
+// IsEncrypted reports whether yaml or json data is a sops file
+func IsEncrypted(data []byte) bool {
+	store := common.StoreForFormat(formats.Yaml, config.NewStoresConfig())
+	_, err := store.LoadEncryptedFile(data)
+	return err == nil
+}
+
+// keyService decrypts sops data keys in-process with age and pgp keys
+type keyService struct {
+	identities age.ParsedIdentities
+	pgpRing    openpgp.EntityList
+}
+
+func newKeyService(keys Keys) (*keyService, error) {
+	ks := &keyService{}
+	if keys.AgeKeyFile != "" {
+		b, err := os.ReadFile(keys.AgeKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read age key file \n %w", err)
+		}
+		if err := ks.identities.Import(string(b)); err != nil {
+			return nil, err
+		}
+	}
+
+	pgpKeyFile := keys.PgpKeyFile
+	if pgpKeyFile == "" {
+		pgpKeyFile = os.Getenv(PgpKeyFileEnv)
+	}
+	if pgpKeyFile != "" {
+		b, err := os.ReadFile(pgpKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pgp key file \n %w", err)
+		}
+		ks.pgpRing, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+		if err != nil {
+			ks.pgpRing, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pgp key file \n %w", err)
+		}
+	}
+	return ks, nil
+}
+
+func (ks *keyService) Decrypt(_ context.Context, in *keyservice.DecryptRequest, _ ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	var plaintext []byte
+	var err error
+	switch k := in.Key.KeyType.(type) {
+	case *keyservice.Key_AgeKey:
+		key := &age.MasterKey{Recipient: k.AgeKey.Recipient, EncryptedKey: string(in.Ciphertext)}
+		if len(ks.identities) > 0 {
+			ks.identities.ApplyToMasterKey(key)
+		}
+		plaintext, err = key.Decrypt()
+	case *keyservice.Key_PgpKey:
+		if len(ks.pgpRing) > 0 {
+			plaintext, err = decryptPgp(ks.pgpRing, in.Ciphertext)
+			break
+		}
+		key := pgp.NewMasterKeyFromFingerprint(k.PgpKey.Fingerprint)
+		key.EncryptedKey = string(in.Ciphertext)
+		plaintext, err = key.Decrypt()
+	default:
+		return nil, fmt.Errorf("unsupported sops key type %T, only age and pgp keys are supported", k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+func (ks *keyService) Encrypt(_ context.Context, _ *keyservice.EncryptRequest, _ ...grpc.CallOption) (*keyservice.EncryptResponse, error) {
+	return nil, fmt.Errorf("sops encryption is not supported")
+}
+
+// decryptPgp decrypts an armored pgp message with the keys of ring
+func decryptPgp(ring openpgp.EntityList, ciphertext []byte) ([]byte, error) {
+	block, err := armor.Decode(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pgp data key \n %w", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, ring, nil, nil)
 	if err != nil {
-		return "", fmt.Errorf("sops command error: %v, output: %s", err, string(output))
+		return nil, fmt.Errorf("failed to decrypt pgp data key \n %w", err)
 	}
-	return string(output), nil
+	return io.ReadAll(md.UnverifiedBody)
 }
@@ -1,7 +1,9 @@
 package argocd
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"github.com/zcubbs/hotpot/pkg/x/pretty"
@@ -28,6 +30,8 @@ type Application struct {
 	AllowEmpty       bool     `json:"allowEmpty"`
 
 	ArgoNamespace string `json:"argoNamespace"`
+	// Labels are added to the Application
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func CreateApplication(app Application, kubeconfig string, debug bool) error {
@@ -46,14 +50,26 @@ func CreateApplication(app Application, kubeconfig string, debug bool) error {
 	// create app
 	if app.IsOCI {
 		// Apply template
-		err := kubernetes.ApplyManifestWithKc(argoAppOciTmpl, app, kubeconfig, debug)
+		err := kubernetes.ApplyManifestWithKc(argoAppOciTmpl, app, kubeconfig, app.Labels, debug)
 		if err != nil {
 			return fmt.Errorf("failed to create application: %s, %w", app.Name, err)
 		}
 		return nil
 	}
 
-	return kubernetes.ApplyManifestWithKc(argoAppTmpl, app, kubeconfig, debug)
+	return kubernetes.ApplyManifestWithKc(argoAppTmpl, app, kubeconfig, app.Labels, debug)
+}
+
+// WaitApplications waits for the applications called names in the argocd
+// namespace to be synced and healthy
+func WaitApplications(namespace string, names []string, timeout time.Duration, kubeconfig string, debug bool) error {
+	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := kubernetes.Wait(ctxWithTimeout, kubeconfig, debug, kubernetes.Applications(namespace, names...)...)
+	if err != nil {
+		return fmt.Errorf("failed to wait for applications to be synced and healthy \n %w", err)
+	}
+	return nil
 }
 
 func validateApp(app *Application) error {
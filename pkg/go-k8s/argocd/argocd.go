@@ -26,6 +26,7 @@ const (
 	argocdDexServerDeploymentName                = "argo-cd-argocd-dex-server"
 	argocdApplicationsetControllerDeploymentName = "argo-cd-argocd-applicationset-controller"
 	argocdNotificationsControllerDeploymentName  = "argo-cd-argocd-notifications-controller"
+	argocdApplicationControllerStatefulSetName   = "argo-cd-argocd-application-controller"
 )
 
 func Install(values Values, kubeconfig string, debug bool) error {
@@ -33,50 +34,31 @@ func Install(values Values, kubeconfig string, debug bool) error {
 		return err
 	}
 
-	// install argocd
-	helmClient := helm.NewClient()
-	helmClient.Settings.KubeConfig = kubeconfig
-	helmClient.Settings.SetNamespace(argocdNamespace)
-	helmClient.Settings.Debug = debug
-
-	// add argocd helm repo
-	err := helmClient.RepoAddAndUpdate(argocdHelmRepoName, argocdHelmRepoURL)
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
 	if err != nil {
-		return fmt.Errorf("failed to add helm repo: %w", err)
+		return err
 	}
 
 	// install argocd
-	err = helmClient.InstallChart(helm.Chart{
-		ChartName:       argocdChartName,
-		ReleaseName:     argocdChartName,
-		RepoName:        argocdHelmRepoName,
-		Values:          nil,
-		ValuesFiles:     nil,
-		Debug:           debug,
-		CreateNamespace: true,
-		Upgrade:         true,
-	})
+	err = helmClient.InstallChart(chart)
 	if err != nil {
 		return fmt.Errorf("failed to install argocd \n %w", err)
 	}
 
-	// wait for argocd server to be ready
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
-	defer cancel()
-	err = kubernetes.IsDeploymentReady(
-		ctxWithTimeout,
-		kubeconfig,
+	// wait for argocd server and its application controller to be ready
+	targets := kubernetes.Deployments(
 		argocdNamespace,
-		[]string{
-			argocdServerDeploymentName,
-			argocdRepoServerDeploymentName,
-			argocdRedisDeploymentName,
-			argocdDexServerDeploymentName,
-			argocdApplicationsetControllerDeploymentName,
-			argocdNotificationsControllerDeploymentName,
-		},
-		debug,
+		argocdServerDeploymentName,
+		argocdRepoServerDeploymentName,
+		argocdRedisDeploymentName,
+		argocdDexServerDeploymentName,
+		argocdApplicationsetControllerDeploymentName,
+		argocdNotificationsControllerDeploymentName,
 	)
+	targets = append(targets, kubernetes.StatefulSets(argocdNamespace, argocdApplicationControllerStatefulSetName)...)
+	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug, targets...)
 	if err != nil {
 		return fmt.Errorf("failed to wait for argocd server to be ready \n %w", err)
 	}
@@ -100,6 +82,50 @@ func Install(values Values, kubeconfig string, debug bool) error {
 	return nil
 }
 
+// prepareChart returns the argo-cd chart to install
+func prepareChart(values Values, kubeconfig string, debug bool) (*helm.Client, helm.Chart, error) {
+	helmClient := helm.NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.SetNamespace(argocdNamespace)
+	helmClient.Settings.Debug = debug
+
+	chart := values.Overrides.Apply(values.Chart.Apply(helm.Chart{
+		ChartName:       argocdChartName,
+		ReleaseName:     argocdChartName,
+		RepoName:        argocdHelmRepoName,
+		Values:          nil,
+		ValuesFiles:     nil,
+		Debug:           debug,
+		CreateNamespace: true,
+		Upgrade:         true,
+		Atomic:          true,
+	}))
+
+	// add argocd helm repo unless the chart comes from elsewhere
+	if chart.Ref == "" {
+		repo := values.Chart.RepositoryOr(argocdHelmRepoName, argocdHelmRepoURL)
+		err := helmClient.RepoAddAndUpdate(repo.Name, repo.Url, repo.Options)
+		if err != nil {
+			return nil, helm.Chart{}, fmt.Errorf("failed to add helm repo: %w", err)
+		}
+	}
+
+	return helmClient, chart, nil
+}
+
+// Plan returns the diff of the argo-cd release against the cluster without changing it
+func Plan(values Values, kubeconfig string, debug bool) (string, error) {
+	if err := validateValues(values); err != nil {
+		return "", err
+	}
+
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
+	if err != nil {
+		return "", err
+	}
+	return helmClient.DiffChart(chart)
+}
+
 func Uninstall(kubeconfig string, debug bool) error {
 	helmClient := helm.NewClient()
 	helmClient.Settings.KubeConfig = kubeconfig
@@ -114,6 +140,10 @@ type Values struct {
 	Insecure      bool
 	ChartVersion  string
 	AdminPassword string
+	// Chart overrides the argo-cd chart of the public repo
+	Chart helm.Source
+	// Overrides are merged over the values hotpot generates for the argo-cd chart
+	Overrides helm.Overrides
 }
 
 const patchPasswordAnnotation = "patched-password"
@@ -177,15 +207,8 @@ func PatchPassword(values Values, kubeconfig string, debug bool) error {
 	// wait for argocd server to be ready
 	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
-	err = kubernetes.IsDeploymentReady(
-		ctxWithTimeout,
-		kubeconfig,
-		argocdNamespace,
-		[]string{
-			argocdServerDeploymentName,
-		},
-		debug,
-	)
+	err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug,
+		kubernetes.Deployments(argocdNamespace, argocdServerDeploymentName)...)
 	if err != nil {
 		return fmt.Errorf("failed to wait for argocd server to be ready \n %w", err)
 	}
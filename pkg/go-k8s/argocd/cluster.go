@@ -13,9 +13,11 @@ type Cluster struct {
 	ServerName string `mapstructure:"serverName" json:"serverName" yaml:"serverName"` // Base64
 	ServerUrl  string `mapstructure:"serverUrl" json:"serverUrl" yaml:"serverUrl"`    // Base64
 	Config     string `mapstructure:"config" json:"config" yaml:"config"`             // Base64
+	// Labels are added to the cluster secret
+	Labels map[string]string `mapstructure:"-" json:"-" yaml:"-"`
 }
 
-func CreateCluster(cluster Cluster, _ string, debug bool) error {
+func CreateCluster(cluster Cluster, kubeconfig string, debug bool) error {
 	if cluster.Namespace == "" {
 		cluster.Namespace = argocdNamespace
 	}
@@ -34,7 +36,7 @@ func CreateCluster(cluster Cluster, _ string, debug bool) error {
 	}
 
 	// Apply template
-	err = kubernetes.ApplyManifest(clusterTmpl, tmpValues, debug)
+	err = kubernetes.ApplyManifestWithKc(clusterTmpl, tmpValues, kubeconfig, cluster.Labels, debug)
 	if err != nil {
 		return fmt.Errorf("failed to create cluster: %w", err)
 	}
@@ -1,5 +1,7 @@
 package argocd
 
+import "time"
+
 // DefaultManager is the default implementation of ArgoCDManager
 type DefaultManager struct{}
 
@@ -7,6 +9,10 @@ func (d DefaultManager) Install(values Values, kubeconfig string, debug bool) er
 	return Install(values, kubeconfig, debug)
 }
 
+func (d DefaultManager) Plan(values Values, kubeconfig string, debug bool) (string, error) {
+	return Plan(values, kubeconfig, debug)
+}
+
 func (d DefaultManager) Uninstall(kubeconfig string, debug bool) error {
 	return Uninstall(kubeconfig, debug)
 }
@@ -22,3 +28,7 @@ func (d DefaultManager) CreateApplication(app Application, kubeconfig string, de
 func (d DefaultManager) CreateRepository(repo Repository, kubeconfig string, debug bool) error {
 	return CreateRepository(repo, kubeconfig, debug)
 }
+
+func (d DefaultManager) WaitApplications(namespace string, names []string, timeout time.Duration, kubeconfig string, debug bool) error {
+	return WaitApplications(namespace, names, timeout, kubeconfig, debug)
+}
@@ -10,6 +10,8 @@ type Project struct {
 	Name        string   `mapstructure:"name" json:"name" yaml:"name"`
 	Namespace   string   `mapstructure:"namespace" json:"namespace" yaml:"namespace"`
 	ClustersUrl []string `mapstructure:"clustersUrl" json:"clustersUrl" yaml:"clustersUrl"`
+	// Labels are added to the AppProject
+	Labels map[string]string `mapstructure:"-" json:"-" yaml:"-"`
 }
 
 func CreateProject(project Project, kubeconfig string, debug bool) error {
@@ -17,7 +19,7 @@ func CreateProject(project Project, kubeconfig string, debug bool) error {
 		project.Namespace = argocdNamespace
 	}
 	// Apply template
-	err := kubernetes.ApplyManifestWithKc(projectTmpl, project, kubeconfig, debug)
+	err := kubernetes.ApplyManifestWithKc(projectTmpl, project, kubeconfig, project.Labels, debug)
 	if err != nil {
 		return fmt.Errorf("failed to create project: %w", err)
 	}
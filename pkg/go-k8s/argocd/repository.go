@@ -22,6 +22,8 @@ type Repository struct {
 	IsOci bool   `json:"isOci"`
 
 	Namespace string `json:"namespace"`
+	// Labels are added to the repository secret
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func CreateRepository(repo Repository, kubeconfig string, debug bool) error {
@@ -64,7 +66,7 @@ func CreateRepository(repo Repository, kubeconfig string, debug bool) error {
 		Password:  password,
 	}
 
-	err = kubernetes.ApplyManifestWithKc(repoTmpl, tmpValues, kubeconfig, debug)
+	err = kubernetes.ApplyManifestWithKc(repoTmpl, tmpValues, kubeconfig, repo.Labels, debug)
 	if err != nil {
 		return err
 	}
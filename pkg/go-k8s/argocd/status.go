@@ -0,0 +1,57 @@
+package argocd
+
+import (
+	"context"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReleaseName and Namespace locate the argo-cd helm release
+const (
+	ReleaseName = argocdChartName
+	Namespace   = argocdNamespace
+)
+
+var applicationResource = schema.GroupVersionResource{
+	Group:    "argoproj.io",
+	Version:  "v1alpha1",
+	Resource: "applications",
+}
+
+// ApplicationStatus is the sync and health status of an ArgoCD application
+type ApplicationStatus struct {
+	Name   string `json:"name"`
+	Sync   string `json:"sync"`
+	Health string `json:"health"`
+}
+
+// IsHealthy reports whether the application is synced and healthy
+func (a ApplicationStatus) IsHealthy() bool {
+	return a.Sync == "Synced" && a.Health == "Healthy"
+}
+
+// GetApplicationStatuses returns the status of every application in the argocd namespace
+func GetApplicationStatuses(ctx context.Context, kubeconfig string) ([]ApplicationStatus, error) {
+	client, err := kubernetes.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := client.Resource(applicationResource).Namespace(argocdNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ApplicationStatus, 0, len(apps.Items))
+	for _, app := range apps.Items {
+		sync, _, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
+		health, _, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+		statuses = append(statuses, ApplicationStatus{
+			Name:   app.GetName(),
+			Sync:   sync,
+			Health: health,
+		})
+	}
+	return statuses, nil
+}
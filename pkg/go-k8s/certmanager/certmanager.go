@@ -54,6 +54,13 @@ type Values struct {
 	DnsOvhApplicationSecret string
 	DnsOvhConsumerKey       string
 	DnsOvhZone              string
+
+	// Chart overrides the cert-manager chart of the public repo
+	Chart helm.Source
+	// Overrides are merged over the values hotpot generates for the cert-manager chart
+	Overrides helm.Overrides
+	// Labels are added to the issuers and secrets hotpot creates
+	Labels map[string]string
 }
 
 func Install(values Values, kubeconfig string, debug bool) error {
@@ -65,51 +72,12 @@ func Install(values Values, kubeconfig string, debug bool) error {
 		return err
 	}
 
-	// create cert-manager values.yaml from template
-	configFileContent, err := yaml.ApplyTmpl(
-		valuesFileTmpl,
-		ValuesFile{
-			InstallCRDs:                   true,
-			ReplicaCount:                  1,
-			DnsEnabled:                    values.DnsChallengeEnabled,
-			DnsRecursiveNameservers:       removePortFromHosts(values.DnsRecursiveNameservers),
-			DnsRecursiveNameserversMerged: getMergedRecursiveNameservers(values.DnsRecursiveNameservers),
-			DnsRecursiveNameserversOnly:   values.DnsRecursiveNameserversOnly,
-		},
-		debug,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to apply template \n %w", err)
-	}
-
-	valuesPath := getTmpFilePath("values")
-	// write tmp manifest
-	err = os.WriteFile(valuesPath, configFileContent, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to write traefik values.yaml \n %w", err)
-	}
-
-	helmClient := helm.NewClient()
-	helmClient.Settings.KubeConfig = kubeconfig
-	helmClient.Settings.Debug = debug
-	helmClient.Settings.SetNamespace(certmanagerNamespace)
-
-	// add repo
-	err = helmClient.RepoAddAndUpdate(certmanagerHelmRepoName, certmanagerHelmRepoURL)
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
 	if err != nil {
-		return fmt.Errorf("failed to add cert-manager helm repo \n %w", err)
+		return err
 	}
 
-	err = helmClient.InstallChart(helm.Chart{
-		ChartName:       certmanagerChartName,
-		ReleaseName:     certmanagerChartName,
-		RepoName:        certmanagerHelmRepoName,
-		Values:          nil,
-		ValuesFiles:     []string{valuesPath},
-		Debug:           debug,
-		CreateNamespace: true,
-		Upgrade:         true,
-	})
+	err = helmClient.InstallChart(chart)
 	if err != nil {
 		return fmt.Errorf("failed to install cert-manager \n %w", err)
 	}
@@ -118,15 +86,8 @@ func Install(values Values, kubeconfig string, debug bool) error {
 	// wait for cert-manager to be ready
 	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
-	err = kubernetes.IsDeploymentReady(
-		ctxWithTimeout,
-		kubeconfig,
-		certmanagerNamespace,
-		[]string{
-			certmanagerDeploymentName,
-		},
-		debug,
-	)
+	err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug,
+		kubernetes.Deployments(certmanagerNamespace, certmanagerDeploymentName)...)
 	if err != nil {
 		return fmt.Errorf("failed to wait for cert-manager to be ready \n %w", err)
 	}
@@ -146,7 +107,8 @@ func Install(values Values, kubeconfig string, debug bool) error {
 					kubeconfig,
 					v1.Secret{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "azuredns-config",
+							Name:   "azuredns-config",
+							Labels: values.Labels,
 						},
 						Type: v1.SecretTypeOpaque,
 						Data: map[string][]byte{
@@ -167,7 +129,8 @@ func Install(values Values, kubeconfig string, debug bool) error {
 					kubeconfig,
 					v1.Secret{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "ovh-credentials",
+							Name:   "ovh-credentials",
+							Labels: values.Labels,
 						},
 						Type: v1.SecretTypeOpaque,
 						Data: map[string][]byte{
@@ -216,7 +179,7 @@ func Install(values Values, kubeconfig string, debug bool) error {
 			DnsOvhApplicationSecret: values.DnsOvhApplicationSecret,
 			DnsOvhConsumerKey:       values.DnsOvhConsumerKey,
 			DnsOvhZone:              values.DnsOvhZone,
-		}, kubeconfig, debug)
+		}, kubeconfig, values.Labels, debug)
 		if err != nil {
 			return fmt.Errorf("failed to apply letsencrypt staging issuer \n %w", err)
 		}
@@ -244,15 +207,92 @@ func Install(values Values, kubeconfig string, debug bool) error {
 			DnsOvhApplicationSecret: values.DnsOvhApplicationSecret,
 			DnsOvhConsumerKey:       values.DnsOvhConsumerKey,
 			DnsOvhZone:              values.DnsOvhZone,
-		}, kubeconfig, debug)
+		}, kubeconfig, values.Labels, debug)
 		if err != nil {
 			return fmt.Errorf("failed to apply letsencrypt production issuer \n %w", err)
 		}
+
+		// the issuers are ready once their acme account is registered
+		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug,
+			kubernetes.ClusterIssuers(letsencryptStagingIssuerName, letsencryptProductionIssuerName)...)
+		if err != nil {
+			return fmt.Errorf("failed to wait for letsencrypt issuers to be ready \n %w", err)
+		}
 	}
 
 	return nil
 }
 
+// prepareChart writes the cert-manager values file and returns the chart to install
+func prepareChart(values Values, kubeconfig string, debug bool) (*helm.Client, helm.Chart, error) {
+	// create cert-manager values.yaml from template
+	configFileContent, err := yaml.ApplyTmpl(
+		valuesFileTmpl,
+		ValuesFile{
+			InstallCRDs:                   true,
+			ReplicaCount:                  1,
+			DnsEnabled:                    values.DnsChallengeEnabled,
+			DnsRecursiveNameservers:       removePortFromHosts(values.DnsRecursiveNameservers),
+			DnsRecursiveNameserversMerged: getMergedRecursiveNameservers(values.DnsRecursiveNameservers),
+			DnsRecursiveNameserversOnly:   values.DnsRecursiveNameserversOnly,
+		},
+		debug,
+	)
+	if err != nil {
+		return nil, helm.Chart{}, fmt.Errorf("failed to apply template \n %w", err)
+	}
+
+	valuesPath := getTmpFilePath("values")
+	// write tmp manifest
+	err = os.WriteFile(valuesPath, configFileContent, 0600)
+	if err != nil {
+		return nil, helm.Chart{}, fmt.Errorf("failed to write traefik values.yaml \n %w", err)
+	}
+
+	helmClient := helm.NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.Debug = debug
+	helmClient.Settings.SetNamespace(certmanagerNamespace)
+
+	chart := values.Overrides.Apply(values.Chart.Apply(helm.Chart{
+		ChartName:       certmanagerChartName,
+		ReleaseName:     certmanagerChartName,
+		RepoName:        certmanagerHelmRepoName,
+		Values:          nil,
+		ValuesFiles:     []string{valuesPath},
+		Debug:           debug,
+		CreateNamespace: true,
+		Upgrade:         true,
+		Atomic:          true,
+	}))
+
+	// add repo unless the chart comes from elsewhere
+	if chart.Ref == "" {
+		repo := values.Chart.RepositoryOr(certmanagerHelmRepoName, certmanagerHelmRepoURL)
+		err = helmClient.RepoAddAndUpdate(repo.Name, repo.Url, repo.Options)
+		if err != nil {
+			return nil, helm.Chart{}, fmt.Errorf("failed to add cert-manager helm repo \n %w", err)
+		}
+	}
+
+	return helmClient, chart, nil
+}
+
+// Plan returns the diff of the cert-manager release against the cluster without changing it
+func Plan(values Values, kubeconfig string, debug bool) (string, error) {
+	if err := validateValues(&values); err != nil {
+		return "", err
+	}
+
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
+	if err != nil {
+		return "", err
+	}
+	return helmClient.DiffChart(chart)
+}
+
 func Uninstall(kubeconfig string, debug bool) error {
 	helmClient := helm.NewClient()
 	helmClient.Settings.KubeConfig = kubeconfig
@@ -376,10 +416,12 @@ func parseSecretValues(values *Values) error {
 	return nil
 }
 
-func applyIssuer(issuer Issuer, _ string, debug bool) error {
-	return kubernetes.ApplyManifest(
+func applyIssuer(issuer Issuer, kubeconfig string, labels map[string]string, debug bool) error {
+	return kubernetes.ApplyManifestWithKc(
 		issuerTmpl,
 		issuer,
+		kubeconfig,
+		labels,
 		debug,
 	)
 }
@@ -561,13 +603,15 @@ const certManagerWebhookOvhChartRepo = "https://aureq.github.io/cert-manager-web
 
 func installOvhHook(values Values, kubeconfig string, debug bool) error {
 	// create service account
-	err := kubernetes.ApplyManifest(
+	err := kubernetes.ApplyManifestWithKc(
 		ovhHookServiceAccountTmpl,
 		struct {
 			Namespace string
 		}{
 			Namespace: certmanagerNamespace,
 		},
+		kubeconfig,
+		values.Labels,
 		debug,
 	)
 	if err != nil {
@@ -629,6 +673,7 @@ func installOvhHook(values Values, kubeconfig string, debug bool) error {
 		Debug:           debug,
 		CreateNamespace: true,
 		Upgrade:         true,
+		Atomic:          true,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to install cert-manager-webhook-ovh \n %w", err)
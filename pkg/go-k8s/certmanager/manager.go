@@ -7,6 +7,10 @@ func (d DefaultManager) Install(values Values, kubeconfig string, debug bool) er
 	return Install(values, kubeconfig, debug)
 }
 
+func (d DefaultManager) Plan(values Values, kubeconfig string, debug bool) (string, error) {
+	return Plan(values, kubeconfig, debug)
+}
+
 func (d DefaultManager) Uninstall(kubeconfig string, debug bool) error {
 	return Uninstall(kubeconfig, debug)
 }
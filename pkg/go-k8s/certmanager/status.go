@@ -0,0 +1,56 @@
+package certmanager
+
+import (
+	"context"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReleaseName and Namespace locate the cert-manager helm release
+const (
+	ReleaseName = certmanagerChartName
+	Namespace   = certmanagerNamespace
+)
+
+var clusterIssuerResource = schema.GroupVersionResource{
+	Group:    "cert-manager.io",
+	Version:  "v1",
+	Resource: "clusterissuers",
+}
+
+// ClusterIssuerStatus is the Ready condition of a ClusterIssuer
+type ClusterIssuerStatus struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// GetClusterIssuerStatuses returns the readiness of every ClusterIssuer
+func GetClusterIssuerStatuses(ctx context.Context, kubeconfig string) ([]ClusterIssuerStatus, error) {
+	client, err := kubernetes.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := client.Resource(clusterIssuerResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ClusterIssuerStatus, 0, len(issuers.Items))
+	for _, issuer := range issuers.Items {
+		status := ClusterIssuerStatus{Name: issuer.GetName()}
+		conditions, _, _ := unstructured.NestedSlice(issuer.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != "Ready" {
+				continue
+			}
+			status.Ready = condition["status"] == "True"
+			status.Message, _ = condition["message"].(string)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,66 @@
+// Package distribution abstracts the Kubernetes distribution hotpot installs
+package distribution
+
+import (
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+)
+
+const (
+	K3s  = "k3s"
+	RKE2 = "rke2"
+)
+
+// Config holds the cluster settings shared by all distributions.
+// Settings a distribution doesn't support are ignored.
+type Config struct {
+	Version                 string
+	Disable                 []string
+	TlsSan                  []string
+	DataDir                 string
+	DefaultLocalStoragePath string
+	WriteKubeconfigMode     string
+	HttpsListenPort         string
+	ResolvConfPath          string
+	// Profile is the rke2 hardening profile, e.g. cis
+	Profile    string
+	Registries k3s.Registries
+	// InstallScriptSha256 pins the install script of a version without a built-in sum
+	InstallScriptSha256 string
+}
+
+// Distribution installs and manages a Kubernetes distribution on the node
+type Distribution interface {
+	Name() string
+	Install(cfg Config, debug bool) error
+	Uninstall(debug bool) error
+	IsInstalled() bool
+	KubeconfigPath() string
+	// ConfigDir holds config.yaml and registries.yaml
+	ConfigDir() string
+	ServiceName() string
+	RenderConfig(cfg Config) ([]byte, error)
+	InstalledVersion() (string, error)
+}
+
+// Get returns the distribution called name, k3s when empty
+func Get(name string) (Distribution, error) {
+	switch name {
+	case K3s, "":
+		return K3sDistribution{}, nil
+	case RKE2:
+		return RKE2Distribution{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported distribution %q, expected %s or %s", name, K3s, RKE2)
+	}
+}
+
+// Detect returns the distribution installed on the node
+func Detect() (Distribution, error) {
+	for _, d := range []Distribution{K3sDistribution{}, RKE2Distribution{}} {
+		if d.IsInstalled() {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("no supported distribution installed")
+}
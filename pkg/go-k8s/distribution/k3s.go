@@ -0,0 +1,81 @@
+package distribution
+
+import (
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+)
+
+// K3sDistribution is the k3s implementation of Distribution
+type K3sDistribution struct{}
+
+func (K3sDistribution) Name() string { return K3s }
+
+func (K3sDistribution) Install(cfg Config, debug bool) error {
+	return k3s.Install(k3sConfig(cfg), debug)
+}
+
+func (K3sDistribution) Uninstall(debug bool) error {
+	return k3s.Uninstall(debug)
+}
+
+func (K3sDistribution) IsInstalled() bool {
+	return k3s.IsInstalled()
+}
+
+func (K3sDistribution) KubeconfigPath() string {
+	return k3s.KubeconfigPath
+}
+
+func (K3sDistribution) ConfigDir() string {
+	return k3s.ConfigFileLocation
+}
+
+func (K3sDistribution) ServiceName() string {
+	return k3s.ServiceName
+}
+
+func (K3sDistribution) RenderConfig(cfg Config) ([]byte, error) {
+	return k3s.RenderConfig(k3sConfig(cfg))
+}
+
+func (K3sDistribution) InstalledVersion() (string, error) {
+	return k3s.InstalledVersion()
+}
+
+// K3sInstaller installs and uninstalls k3s, e.g. k3s.DefaultManager
+type K3sInstaller interface {
+	Install(cfg k3s.Config, debug bool) error
+	Uninstall(debug bool) error
+}
+
+// WrapK3s returns the k3s distribution, installed and uninstalled through i
+func WrapK3s(i K3sInstaller) Distribution {
+	return wrappedK3s{installer: i}
+}
+
+type wrappedK3s struct {
+	K3sDistribution
+	installer K3sInstaller
+}
+
+func (w wrappedK3s) Install(cfg Config, debug bool) error {
+	return w.installer.Install(k3sConfig(cfg), debug)
+}
+
+func (w wrappedK3s) Uninstall(debug bool) error {
+	return w.installer.Uninstall(debug)
+}
+
+func k3sConfig(cfg Config) k3s.Config {
+	return k3s.Config{
+		Version:                 cfg.Version,
+		Disable:                 cfg.Disable,
+		TlsSan:                  cfg.TlsSan,
+		DataDir:                 cfg.DataDir,
+		DefaultLocalStoragePath: cfg.DefaultLocalStoragePath,
+		WriteKubeconfigMode:     cfg.WriteKubeconfigMode,
+		HttpsListenPort:         cfg.HttpsListenPort,
+		ResolvConfPath:          cfg.ResolvConfPath,
+		Registries:              cfg.Registries,
+		InstallScriptSha256:     cfg.InstallScriptSha256,
+	}
+}
@@ -0,0 +1,56 @@
+package distribution
+
+import (
+	"github.com/zcubbs/hotpot/pkg/go-k8s/rke2"
+)
+
+// RKE2Distribution is the rke2 implementation of Distribution
+type RKE2Distribution struct{}
+
+func (RKE2Distribution) Name() string { return RKE2 }
+
+func (RKE2Distribution) Install(cfg Config, debug bool) error {
+	return rke2.Install(rke2Config(cfg), debug)
+}
+
+func (RKE2Distribution) Uninstall(debug bool) error {
+	return rke2.Uninstall(debug)
+}
+
+func (RKE2Distribution) IsInstalled() bool {
+	return rke2.IsInstalled()
+}
+
+func (RKE2Distribution) KubeconfigPath() string {
+	return rke2.KubeconfigPath
+}
+
+func (RKE2Distribution) ConfigDir() string {
+	return rke2.ConfigFileLocation
+}
+
+func (RKE2Distribution) ServiceName() string {
+	return rke2.ServiceName
+}
+
+func (RKE2Distribution) RenderConfig(cfg Config) ([]byte, error) {
+	return rke2.RenderConfig(rke2Config(cfg))
+}
+
+func (RKE2Distribution) InstalledVersion() (string, error) {
+	return rke2.InstalledVersion()
+}
+
+func rke2Config(cfg Config) rke2.Config {
+	return rke2.Config{
+		Version:             cfg.Version,
+		Disable:             cfg.Disable,
+		TlsSan:              cfg.TlsSan,
+		DataDir:             cfg.DataDir,
+		WriteKubeconfigMode: cfg.WriteKubeconfigMode,
+		ResolvConfPath:      cfg.ResolvConfPath,
+		Profile:             cfg.Profile,
+		Registries:          cfg.Registries,
+		InstallScriptSha256: cfg.InstallScriptSha256,
+	}
+}
@@ -10,58 +10,103 @@ import (
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
 	"helm.sh/helm/v3/pkg/strvals"
 	"os"
+	"time"
 )
 
 type Chart struct {
-	ChartName       string
-	ReleaseName     string
-	RepoName        string
-	Values          map[string]string
-	ValuesFiles     []string
+	ChartName   string
+	ReleaseName string
+	RepoName    string
+	// Ref is a full chart reference, e.g. oci://registry.example.com/charts/app.
+	// It takes precedence over RepoName and ChartName.
+	Ref string
+	// Version constrains the chart version, latest when empty
+	Version string
+	// Registry holds the credentials of the OCI registry serving Ref
+	Registry RegistryOptions
+	// Values are set like helm --set, over ValuesFiles
+	Values      map[string]string
+	ValuesFiles []string
+	// Overrides are deep-merged over ValuesFiles and Values
+	Overrides       Values
 	Debug           bool
 	CreateNamespace bool
 	Upgrade         bool
+	// Wait for the release resources to become ready
+	Wait bool
+	// Atomic uninstalls a failed install and rolls a failed upgrade back to
+	// the last deployed revision. Implies Wait.
+	Atomic bool
+	// Timeout bounds Wait and Atomic, DefaultTimeout when zero
+	Timeout time.Duration
 }
 
 func (c *Client) InstallChart(chartInput Chart) error {
-	actionConfig, err := c.initActionConfig()
+	actionConfig, chartRequested, vals, err := c.prepareChart(chartInput)
 	if err != nil {
 		return err
 	}
 
-	cp, err := c.locateChart(chartInput.RepoName, chartInput.ChartName, actionConfig)
-	if err != nil {
+	if err := c.recoverRelease(chartInput, actionConfig); err != nil {
 		return err
 	}
 
-	vals, err := c.loadValues(chartInput.Values, chartInput.ValuesFiles)
+	if chartInput.Upgrade && c.releaseExists(chartInput.ReleaseName, actionConfig) {
+		if chartInput.Debug {
+			diff, err := c.diff(chartInput, chartRequested, vals, actionConfig)
+			if err != nil {
+				return err
+			}
+			fmt.Print(diff)
+		}
+		return c.upgradeChart(chartRequested, vals, chartInput, actionConfig)
+	} else if !c.releaseExists(chartInput.ReleaseName, actionConfig) {
+		return c.installNewChart(chartRequested, vals, chartInput, actionConfig)
+	} else {
+		return fmt.Errorf("release %s already exists, and upgrade was not specified", chartInput.ReleaseName)
+	}
+}
+
+// prepareChart locates and loads the chart with its dependencies and values
+func (c *Client) prepareChart(chartInput Chart) (*action.Configuration, *chart.Chart, map[string]interface{}, error) {
+	actionConfig, err := c.initActionConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rc, err := c.newRegistryClient(chartInput.Registry)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+	actionConfig.RegistryClient = rc
+
+	cp, err := c.locateChart(chartInput, actionConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	vals, err := c.loadValues(chartInput)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	chartRequested, err := loader.Load(cp)
 	if err != nil {
-		return fmt.Errorf("failed to load chart: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to load chart: %w", err)
 	}
 
 	validInstallableChart, err := isChartInstallable(chartRequested)
 	if !validInstallableChart {
-		return fmt.Errorf("chart is not installable: %w", err)
+		return nil, nil, nil, fmt.Errorf("chart is not installable: %w", err)
 	}
 
 	if err := c.handleDependencies(chartRequested, cp, actionConfig); err != nil {
-		return err
-	}
-
-	if chartInput.Upgrade && c.releaseExists(chartInput.ReleaseName, actionConfig) {
-		return c.upgradeChart(chartRequested, vals, chartInput, actionConfig)
-	} else if !c.releaseExists(chartInput.ReleaseName, actionConfig) {
-		return c.installNewChart(chartRequested, vals, chartInput, actionConfig)
-	} else {
-		return fmt.Errorf("release %s already exists, and upgrade was not specified", chartInput.ReleaseName)
+		return nil, nil, nil, err
 	}
+	return actionConfig, chartRequested, vals, nil
 }
 
 func (c *Client) initActionConfig() (*action.Configuration, error) {
@@ -76,33 +121,50 @@ func (c *Client) initActionConfig() (*action.Configuration, error) {
 	return actionConfig, nil
 }
 
-func (c *Client) locateChart(repo, chart string, actionConfig *action.Configuration) (string, error) {
+func (c *Client) locateChart(chartInput Chart, actionConfig *action.Configuration) (string, error) {
+	name := chartInput.Ref
+	if name == "" {
+		name = fmt.Sprintf("%s/%s", chartInput.RepoName, chartInput.ChartName)
+	}
+
 	client := action.NewInstall(actionConfig)
-	cp, err := client.ChartPathOptions.LocateChart(fmt.Sprintf("%s/%s", repo, chart), c.Settings)
+	client.SetRegistryClient(actionConfig.RegistryClient)
+	client.ChartPathOptions.Version = chartInput.Version
+	// the downloader reads repository credentials and certificates from the
+	// repository file but not whether to skip tls verification
+	if chartInput.Ref == "" {
+		if f, err := repo.LoadFile(c.Settings.RepositoryConfig); err == nil {
+			if entry := f.Get(chartInput.RepoName); entry != nil {
+				client.ChartPathOptions.InsecureSkipTLSverify = entry.InsecureSkipTLSverify
+			}
+		}
+	}
+	cp, err := client.ChartPathOptions.LocateChart(name, c.Settings)
 	if err != nil {
-		return "", fmt.Errorf("failed to locate chart: %w", err)
+		return "", fmt.Errorf("failed to locate chart %s: %w", name, err)
 	}
 	return cp, nil
 }
 
-func (c *Client) loadValues(valuesMap map[string]string, valuesFiles []string) (map[string]interface{}, error) {
-	vals := make(map[string]interface{})
-	for k, v := range valuesMap {
-		setString := fmt.Sprintf("%s=%s", k, v)
-		if err := strvals.ParseInto(setString, vals); err != nil {
-			return nil, errors.Wrapf(err, "failed setting value for %s", k)
-		}
-	}
-
+// loadValues merges the values files in order, then the --set style values
+// and the overrides on top
+func (c *Client) loadValues(chartInput Chart) (map[string]interface{}, error) {
 	valueOpts := &values.Options{
-		ValueFiles: valuesFiles,
+		ValueFiles: chartInput.ValuesFiles,
 	}
 	finalVals, err := valueOpts.MergeValues(getter.All(c.Settings))
 	if err != nil {
 		return nil, fmt.Errorf("failed to merge values: %w", err)
 	}
 
-	return finalVals, nil
+	for k, v := range chartInput.Values {
+		setString := fmt.Sprintf("%s=%s", k, v)
+		if err := strvals.ParseInto(setString, finalVals); err != nil {
+			return nil, errors.Wrapf(err, "failed setting value for %s", k)
+		}
+	}
+
+	return MergeValues(finalVals, chartInput.Overrides), nil
 }
 
 func (c *Client) handleDependencies(ch *chart.Chart, cp string, actionConfig *action.Configuration) error {
@@ -118,6 +180,7 @@ func (c *Client) handleDependencies(ch *chart.Chart, cp string, actionConfig *ac
 				Getters:          getter.All(c.Settings),
 				RepositoryConfig: c.Settings.RepositoryConfig,
 				RepositoryCache:  c.Settings.RepositoryCache,
+				RegistryClient:   actionConfig.RegistryClient,
 			}
 			if err := man.Update(); err != nil {
 				return fmt.Errorf("failed to update dependencies: %w", err)
@@ -132,6 +195,9 @@ func (c *Client) installNewChart(ch *chart.Chart, vals map[string]interface{}, c
 	client.ReleaseName = chartInput.ReleaseName
 	client.Namespace = c.Settings.Namespace()
 	client.CreateNamespace = chartInput.CreateNamespace
+	client.Wait = chartInput.Wait
+	client.Atomic = chartInput.Atomic
+	client.Timeout = chartTimeout(chartInput)
 
 	release, err := client.Run(ch, vals)
 	if err != nil {
@@ -148,8 +214,19 @@ func (c *Client) installNewChart(ch *chart.Chart, vals map[string]interface{}, c
 func (c *Client) upgradeChart(ch *chart.Chart, vals map[string]interface{}, chartInput Chart, actionConfig *action.Configuration) error {
 	upgradeClient := action.NewUpgrade(actionConfig)
 	upgradeClient.Namespace = c.Settings.Namespace()
+	upgradeClient.Wait = chartInput.Wait || chartInput.Atomic
+	upgradeClient.Timeout = chartTimeout(chartInput)
+	upgradeClient.CleanupOnFail = chartInput.Atomic
 
 	release, err := upgradeClient.Run(chartInput.ReleaseName, ch, vals)
+	if err != nil && chartInput.Atomic {
+		// helm's own atomic upgrade rolls back to the previous revision,
+		// which may itself be a failed one
+		if rbErr := c.rollbackToLastDeployed(chartInput, actionConfig); rbErr != nil {
+			return fmt.Errorf("failed to upgrade chart: %w, rollback failed: %v", err, rbErr)
+		}
+		return fmt.Errorf("failed to upgrade chart, rolled back to the last deployed revision: %w", err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to upgrade chart: %w", err)
 	}
@@ -161,6 +238,18 @@ func (c *Client) upgradeChart(ch *chart.Chart, vals map[string]interface{}, char
 	return nil
 }
 
+func (c *Client) rollbackToLastDeployed(chartInput Chart, actionConfig *action.Configuration) error {
+	h, err := history(chartInput.ReleaseName, actionConfig)
+	if err != nil {
+		return err
+	}
+	last := lastDeployed(h)
+	if last == nil {
+		return fmt.Errorf("release %s has no deployed revision to roll back to", chartInput.ReleaseName)
+	}
+	return rollback(chartInput.ReleaseName, last.Version, true, chartTimeout(chartInput), actionConfig)
+}
+
 // UninstallChart uninstalls a helm chart
 func (c *Client) UninstallChart(name string) error {
 	actionConfig := new(action.Configuration)
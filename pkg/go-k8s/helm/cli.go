@@ -7,44 +7,42 @@ package helm
 import (
 	"fmt"
 	"github.com/zcubbs/hotpot/pkg/x/bash"
+	"github.com/zcubbs/hotpot/pkg/x/download"
+	"runtime"
+	"strings"
 )
 
 const (
-	TmpHelmScript    = "/tmp/get_helm.sh"
-	InstallScriptUrl = "https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3"
+	// DefaultVersion is the helm release installed when none is pinned
+	DefaultVersion = "v3.16.4"
+	BinaryPath     = "/usr/local/bin/helm"
+	ReleaseUrl     = "https://get.helm.sh"
 )
 
-func InstallCli(debug bool) error {
+// InstallCli installs the given helm release, verified against its published checksum
+func InstallCli(version string, debug bool) error {
+	if version == "" || version == "latest" {
+		version = DefaultVersion
+	}
 	if debug {
-		fmt.Printf("installing helm %s", "curl -fsSL -o "+TmpHelmScript+InstallScriptUrl)
+		fmt.Printf("installing helm %s\n", version)
 	}
 
-	err := bash.ExecuteCmd(
-		"curl",
-		debug,
-		"-fsSL",
-		"-o",
-		TmpHelmScript,
-		"https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3",
-	)
+	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	archive := fmt.Sprintf("%s/helm-%s-%s.tar.gz", ReleaseUrl, version, platform)
+	file, err := download.Fetch(download.Artifact{
+		Name:        "helm",
+		Version:     version,
+		URL:         archive,
+		ChecksumURL: archive + ".sha256sum",
+	}, debug)
 	if err != nil {
 		return err
 	}
 
-	// chmod 700 get_helm.sh
-	err = bash.Chmod("/tmp/get_helm.sh", 0700, debug)
+	err = download.ExtractFile(file, platform+"/helm", BinaryPath, 0755)
 	if err != nil {
-		return err
-	}
-
-	// sh ./get_helm.sh
-	_, err = bash.ExecuteScript(
-		TmpHelmScript,
-		debug,
-		TmpHelmScript,
-	)
-	if err != nil {
-		return err
+		return fmt.Errorf("failed to install helm %s \n %w", version, err)
 	}
 
 	return nil
@@ -62,3 +60,12 @@ func IsHelmInstalled() (bool, error) {
 	}
 	return true, nil
 }
+
+// InstalledVersion returns the version of the helm cli on the PATH, e.g. v3.16.4
+func InstalledVersion() (string, error) {
+	out, err := bash.ExecuteCmdWithOutput("helm", "version", "--template", "{{.Version}}")
+	if err != nil {
+		return "", fmt.Errorf("failed to get helm version \n %s %w", out, err)
+	}
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,172 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+// DiffChart renders chartInput with a dry-run and returns its per-resource
+// diff against the deployed revision of the release. A release that doesn't
+// exist yet diffs against an empty manifest. The release is left untouched.
+func (c *Client) DiffChart(chartInput Chart) (string, error) {
+	actionConfig, ch, vals, err := c.prepareChart(chartInput)
+	if err != nil {
+		return "", err
+	}
+	return c.diff(chartInput, ch, vals, actionConfig)
+}
+
+func (c *Client) diff(chartInput Chart, ch *chart.Chart, vals map[string]interface{}, actionConfig *action.Configuration) (string, error) {
+	var deployed, rendered string
+	if c.releaseExists(chartInput.ReleaseName, actionConfig) {
+		h, err := history(chartInput.ReleaseName, actionConfig)
+		if err != nil {
+			return "", err
+		}
+		if last := lastDeployed(h); last != nil {
+			deployed = last.Manifest
+		}
+
+		client := action.NewUpgrade(actionConfig)
+		client.Namespace = c.Settings.Namespace()
+		client.DryRun = true
+		rel, err := client.Run(chartInput.ReleaseName, ch, vals)
+		if err != nil {
+			return "", fmt.Errorf("failed to render chart: %w", err)
+		}
+		rendered = rel.Manifest
+	} else {
+		client := action.NewInstall(actionConfig)
+		client.ReleaseName = chartInput.ReleaseName
+		client.Namespace = c.Settings.Namespace()
+		client.DryRun = true
+		client.Replace = true
+		rel, err := client.Run(ch, vals)
+		if err != nil {
+			return "", fmt.Errorf("failed to render chart: %w", err)
+		}
+		rendered = rel.Manifest
+	}
+
+	return ManifestDiff(deployed, rendered, c.Settings.Namespace()), nil
+}
+
+// ManifestDiff returns a unified diff per resource between two rendered
+// manifests, empty when they hold the same resources. Resources are matched
+// by kind, namespace and name, defaulting to namespace when unset.
+func ManifestDiff(old, new, namespace string) string {
+	before := splitResources(old, namespace)
+	after := splitResources(new, namespace)
+
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var sb strings.Builder
+	for _, k := range sorted {
+		a, b := before[k], after[k]
+		if a == b {
+			continue
+		}
+		switch {
+		case a == "":
+			_, _ = fmt.Fprintf(&sb, "+ %s\n%s\n", k, prefixLines(b, "+"))
+		case b == "":
+			_, _ = fmt.Fprintf(&sb, "- %s\n%s\n", k, prefixLines(a, "-"))
+		default:
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:       difflib.SplitLines(a),
+				B:       difflib.SplitLines(b),
+				Context: 3,
+			})
+			_, _ = fmt.Fprintf(&sb, "~ %s\n%s\n", k, diff)
+		}
+	}
+	return sb.String()
+}
+
+// splitResources maps "Kind namespace/name" to the resource yaml
+func splitResources(manifest, namespace string) map[string]string {
+	resources := make(map[string]string)
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var head struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &head); err != nil || head.Kind == "" {
+			continue
+		}
+		if head.Kind == "Secret" {
+			doc = redactSecret(doc)
+		}
+		ns := head.Metadata.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		resources[fmt.Sprintf("%s %s/%s", head.Kind, ns, head.Metadata.Name)] = stripSourceComment(doc)
+	}
+	return resources
+}
+
+// stripSourceComment drops the "# Source:" line helm puts in front of every
+// resource, which moves whenever templates are renamed
+func stripSourceComment(doc string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		if !strings.HasPrefix(line, "# Source: ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixLines(doc, prefix string) string {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// redactSecret replaces secret values with a short digest so changes
+// still show up in the diff without leaking the values
+func redactSecret(doc string) string {
+	var secret map[string]interface{}
+	if err := yaml.Unmarshal([]byte(doc), &secret); err != nil {
+		return doc
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := secret[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+			values[k] = fmt.Sprintf("<redacted %x>", sum[:4])
+		}
+	}
+	b, err := yaml.Marshal(secret)
+	if err != nil {
+		return doc
+	}
+	return string(b)
+}
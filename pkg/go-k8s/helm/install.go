@@ -9,12 +9,7 @@ import (
 func Install(debug bool) error {
 	fmt.Printf("🔨 Installing helm...\n")
 
-	cmd := "curl -fsSL -o get_helm.sh https://raw.githubusercontent.com/helm/helm/master/scripts/get-helm-3 && " +
-		"chmod 700 get_helm.sh && " +
-		"./get_helm.sh && " +
-		"rm get_helm.sh"
-
-	err := bash.ExecuteCmd(cmd, debug)
+	err := InstallCli(DefaultVersion, debug)
 	if err != nil {
 		return fmt.Errorf("failed to install helm: %w", err)
 	}
@@ -26,7 +21,7 @@ func Install(debug bool) error {
 func Uninstall(debug bool) error {
 	fmt.Printf("🔨 Uninstalling helm...\n")
 
-	cmd := "rm -f /usr/local/bin/helm"
+	cmd := "rm -f " + BinaryPath
 
 	err := bash.ExecuteCmd(cmd, debug)
 	if err != nil {
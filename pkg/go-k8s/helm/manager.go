@@ -1,5 +1,7 @@
 package helm
 
+import "fmt"
+
 // DefaultManager is the default implementation of HelmManager
 type DefaultManager struct{}
 
@@ -7,8 +9,8 @@ func (d DefaultManager) Install(debug bool) error {
 	return Install(debug)
 }
 
-func (d DefaultManager) InstallCli(debug bool) error {
-	return Install(debug)
+func (d DefaultManager) InstallCli(version string, debug bool) error {
+	return InstallCli(version, debug)
 }
 
 func (d DefaultManager) Uninstall(debug bool) error {
@@ -18,3 +20,40 @@ func (d DefaultManager) Uninstall(debug bool) error {
 func (d DefaultManager) IsHelmInstalled() (bool, error) {
 	return IsHelmInstalled()
 }
+
+func (d DefaultManager) HelmVersion() (string, error) {
+	return InstalledVersion()
+}
+
+// InstallChart installs or upgrades chart in namespace, adding its repository
+// first unless the chart is pulled from a ref
+func (d DefaultManager) InstallChart(chart Chart, repo Repository, namespace, kubeconfig string) error {
+	helmClient, err := clientFor(chart, repo, namespace, kubeconfig)
+	if err != nil {
+		return err
+	}
+	return helmClient.InstallChart(chart)
+}
+
+// DiffChart returns the diff of chart against its deployed release in namespace
+func (d DefaultManager) DiffChart(chart Chart, repo Repository, namespace, kubeconfig string) (string, error) {
+	helmClient, err := clientFor(chart, repo, namespace, kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	return helmClient.DiffChart(chart)
+}
+
+func clientFor(chart Chart, repo Repository, namespace, kubeconfig string) (*Client, error) {
+	helmClient := NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.SetNamespace(namespace)
+	helmClient.Settings.Debug = chart.Debug
+
+	if chart.Ref == "" {
+		if err := helmClient.RepoAddAndUpdate(repo.Name, repo.Url, repo.Options); err != nil {
+			return nil, fmt.Errorf("failed to add helm repo %s: %w", repo.Name, err)
+		}
+	}
+	return helmClient, nil
+}
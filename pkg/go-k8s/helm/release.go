@@ -0,0 +1,171 @@
+package helm
+
+import (
+	"fmt"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"time"
+)
+
+// DefaultTimeout bounds wait, atomic and rollback operations when no timeout is set
+const DefaultTimeout = 5 * time.Minute
+
+// History returns the revisions of a release, oldest first
+func (c *Client) History(name string) ([]*release.Release, error) {
+	actionConfig, err := c.initActionConfig()
+	if err != nil {
+		return nil, err
+	}
+	return history(name, actionConfig)
+}
+
+// ReleaseStatus summarizes the current revision of a release
+type ReleaseStatus struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion"`
+	Updated      time.Time `json:"updated"`
+}
+
+// IsDeployed reports whether the current revision deployed successfully
+func (s ReleaseStatus) IsDeployed() bool {
+	return s.Status == release.StatusDeployed.String()
+}
+
+// Status returns the status of the current revision of a release, nil when it is not installed
+func (c *Client) Status(name string) (*ReleaseStatus, error) {
+	actionConfig, err := c.initActionConfig()
+	if err != nil {
+		return nil, err
+	}
+	h, err := history(name, actionConfig)
+	if err != nil || len(h) == 0 {
+		return nil, err
+	}
+
+	current := h[len(h)-1]
+	status := &ReleaseStatus{
+		Name:      current.Name,
+		Namespace: current.Namespace,
+		Revision:  current.Version,
+	}
+	if current.Info != nil {
+		status.Status = current.Info.Status.String()
+		status.Updated = current.Info.LastDeployed.Time
+	}
+	if current.Chart != nil && current.Chart.Metadata != nil {
+		status.ChartVersion = current.Chart.Metadata.Version
+		status.AppVersion = current.Chart.Metadata.AppVersion
+	}
+	return status, nil
+}
+
+// Rollback rolls a release back to revision, or to its last deployed revision when revision is 0
+func (c *Client) Rollback(name string, revision int, wait bool, timeout time.Duration) error {
+	actionConfig, err := c.initActionConfig()
+	if err != nil {
+		return err
+	}
+	if revision == 0 {
+		h, err := history(name, actionConfig)
+		if err != nil {
+			return err
+		}
+		last := lastDeployed(h)
+		if last == nil {
+			return fmt.Errorf("release %s has no deployed revision to roll back to", name)
+		}
+		revision = last.Version
+	}
+	return rollback(name, revision, wait, timeout, actionConfig)
+}
+
+// recoverRelease brings a release stuck in a pending state, e.g. after an
+// interrupted cook, back to its last deployed revision. A release that was
+// never deployed is uninstalled so it can be installed again.
+func (c *Client) recoverRelease(chartInput Chart, actionConfig *action.Configuration) error {
+	h, err := history(chartInput.ReleaseName, actionConfig)
+	if err != nil {
+		return err
+	}
+	if len(h) == 0 {
+		return nil
+	}
+
+	current := h[len(h)-1]
+	last := lastDeployed(h)
+	pending := current.Info.Status.IsPending()
+	if !pending && (last != nil || current.Info.Status != release.StatusFailed) {
+		return nil
+	}
+
+	// a pending release younger than the timeout may belong to a running operation
+	timeout := chartTimeout(chartInput)
+	if pending && time.Since(current.Info.LastDeployed.Time) < timeout {
+		return fmt.Errorf("release %s is %s since %s, another operation is in progress",
+			chartInput.ReleaseName, current.Info.Status, current.Info.LastDeployed.Format(time.RFC3339))
+	}
+
+	if last == nil {
+		if chartInput.Debug {
+			fmt.Printf("release %s was never deployed (%s), uninstalling it\n", chartInput.ReleaseName, current.Info.Status)
+		}
+		if _, err := action.NewUninstall(actionConfig).Run(chartInput.ReleaseName); err != nil {
+			return fmt.Errorf("failed to uninstall %s release %s: %w", current.Info.Status, chartInput.ReleaseName, err)
+		}
+		return nil
+	}
+
+	if chartInput.Debug {
+		fmt.Printf("release %s is %s, rolling back to revision %d\n", chartInput.ReleaseName, current.Info.Status, last.Version)
+	}
+	return rollback(chartInput.ReleaseName, last.Version, chartInput.Wait, timeout, actionConfig)
+}
+
+func history(name string, actionConfig *action.Configuration) ([]*release.Release, error) {
+	h, err := action.NewHistory(actionConfig).Run(name)
+	if err == driver.ErrReleaseNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of release %s: %w", name, err)
+	}
+	releaseutil.SortByRevision(h)
+	return h, nil
+}
+
+func rollback(name string, revision int, wait bool, timeout time.Duration, actionConfig *action.Configuration) error {
+	client := action.NewRollback(actionConfig)
+	client.Version = revision
+	client.Wait = wait
+	client.Timeout = timeout
+	client.CleanupOnFail = true
+	if err := client.Run(name); err != nil {
+		return fmt.Errorf("failed to roll back release %s to revision %d: %w", name, revision, err)
+	}
+	return nil
+}
+
+// lastDeployed returns the newest revision that reached the deployed or
+// superseded state, or nil when the release was never deployed
+func lastDeployed(h []*release.Release) *release.Release {
+	for i := len(h) - 1; i >= 0; i-- {
+		switch h[i].Info.Status {
+		case release.StatusDeployed, release.StatusSuperseded:
+			return h[i]
+		}
+	}
+	return nil
+}
+
+func chartTimeout(chartInput Chart) time.Duration {
+	if chartInput.Timeout > 0 {
+		return chartInput.Timeout
+	}
+	return DefaultTimeout
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gofrs/flock"
 	"github.com/pkg/errors"
+	"github.com/zcubbs/hotpot/pkg/secret"
 	"gopkg.in/yaml.v2"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/repo"
@@ -16,21 +17,47 @@ import (
 	"time"
 )
 
+// RepoOptions authenticates against a chart repository.
+// Username and Password accept secret provider references, e.g. env.NEXUS_PASSWORD.
+type RepoOptions struct {
+	Username           string
+	Password           string
+	CaFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	// PassCredentialsAll sends the credentials to chart hosts other than the repository's
+	PassCredentialsAll bool
+}
+
+// Repository is a chart repository with its credentials
+type Repository struct {
+	Name    string
+	Url     string
+	Options RepoOptions
+}
+
 // RepoAddAndUpdate adds repo with given name and url and updates charts for all helm repos
-func (c *Client) RepoAddAndUpdate(name, url string) error {
-	err := c.RepoAdd(name, url)
+func (c *Client) RepoAddAndUpdate(name, url string, opts ...RepoOptions) error {
+	err := c.RepoAdd(name, url, opts...)
 	if err != nil {
 		return err
 	}
 	return c.RepoUpdate()
 }
 
-// RepoAdd adds repo with given name and url
-func (c *Client) RepoAdd(name, url string) error {
+// RepoAdd adds repo with given name and url. A repo already added under name
+// is replaced when its url or options changed.
+func (c *Client) RepoAdd(name, url string, opts ...RepoOptions) error {
+	clt, err := repoEntry(name, url, opts...)
+	if err != nil {
+		return err
+	}
+
 	repoFile := c.Settings.RepositoryConfig
 
 	//Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(repoFile), 0750)
+	err = os.MkdirAll(filepath.Dir(repoFile), 0750)
 	if err != nil && !os.IsExist(err) {
 		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(repoFile), err)
 	}
@@ -62,16 +89,11 @@ func (c *Client) RepoAdd(name, url string) error {
 		log.Fatal(err)
 	}
 
-	if f.Has(name) {
+	if existing := f.Get(name); existing != nil && *existing == clt {
 		// repo already exists
 		return nil
 	}
 
-	clt := repo.Entry{
-		Name: name,
-		URL:  url,
-	}
-
 	r, err := repo.NewChartRepository(&clt, getter.All(c.Settings))
 	if err != nil {
 		return fmt.Errorf("failed to create chart repository: %w", err)
@@ -84,13 +106,42 @@ func (c *Client) RepoAdd(name, url string) error {
 
 	f.Update(&clt)
 
-	if err := f.WriteFile(repoFile, 0644); err != nil {
+	// the file holds repository credentials
+	if err := f.WriteFile(repoFile, 0600); err != nil {
 		return fmt.Errorf("failed to write repository file: %w", err)
 	}
 
 	return nil
 }
 
+// repoEntry returns the repository file entry for name, resolving its credentials
+func repoEntry(name, url string, opts ...RepoOptions) (repo.Entry, error) {
+	entry := repo.Entry{
+		Name: name,
+		URL:  url,
+	}
+	for _, o := range opts {
+		if o.Username != "" || o.Password != "" {
+			username, err := secret.Provide(o.Username)
+			if err != nil {
+				return repo.Entry{}, fmt.Errorf("failed to get repository username: %w", err)
+			}
+			password, err := secret.Provide(o.Password)
+			if err != nil {
+				return repo.Entry{}, fmt.Errorf("failed to get repository password: %w", err)
+			}
+			entry.Username = username
+			entry.Password = password
+		}
+		entry.CAFile = o.CaFile
+		entry.CertFile = o.CertFile
+		entry.KeyFile = o.KeyFile
+		entry.InsecureSkipTLSverify = o.InsecureSkipVerify
+		entry.PassCredentialsAll = o.PassCredentialsAll
+	}
+	return entry, nil
+}
+
 // RepoUpdate updates charts for all helm repos
 func (c *Client) RepoUpdate() error {
 	repoFile := c.Settings.RepositoryConfig
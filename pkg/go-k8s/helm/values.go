@@ -2,3 +2,36 @@ package helm
 
 // Values represents helm chart values
 type Values map[string]interface{}
+
+// Overrides are user supplied values applied over the ones a component generates
+type Overrides struct {
+	Values      Values
+	ValuesFiles []string
+}
+
+// Apply appends the override files to the chart values files and merges the
+// override values over them
+func (o Overrides) Apply(chart Chart) Chart {
+	chart.ValuesFiles = append(append([]string{}, chart.ValuesFiles...), o.ValuesFiles...)
+	chart.Overrides = MergeValues(chart.Overrides, o.Values)
+	return chart
+}
+
+// MergeValues returns base deep-merged with override. Nested maps are merged
+// key by key, any other value in override, lists included, replaces the one in base.
+func MergeValues(base, override Values) Values {
+	merged := make(Values, len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		if o, ok := v.(map[string]interface{}); ok {
+			if b, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = map[string]interface{}(MergeValues(b, o))
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
@@ -5,16 +5,30 @@ Copyright © 2023 zcubbs https://github.com/zcubbs
 package k3s
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/zcubbs/hotpot/pkg/x/bash"
+	"github.com/zcubbs/hotpot/pkg/x/download"
 	osx "github.com/zcubbs/hotpot/pkg/x/os"
 	"os"
+	"runtime"
+	"strings"
 	"text/template"
 )
 
 const InstallScript = "/tmp/k3s-install.sh"
 const UninstallScript = "/usr/local/bin/k3s-uninstall.sh"
 const ConfigFileLocation = "/etc/rancher/k3s"
+const BinaryPath = "/usr/local/bin/k3s"
+const KubeconfigPath = ConfigFileLocation + "/k3s.yaml"
+
+// DefaultVersion is the k3s release installed when none is pinned
+const DefaultVersion = "v1.31.4+k3s1"
+
+const (
+	releaseUrl = "https://github.com/k3s-io/k3s/releases/download"
+	scriptUrl  = "https://raw.githubusercontent.com/k3s-io/k3s"
+)
 
 type Config struct {
 	Version                 string
@@ -25,6 +39,26 @@ type Config struct {
 	WriteKubeconfigMode     string
 	HttpsListenPort         string
 	ResolvConfPath          string
+	Registries              Registries
+	// InstallScriptSha256 pins the install script of a version missing from InstallScriptSums
+	InstallScriptSha256 string
+}
+
+// InstallScriptSums pins the sha256 of the install script of each k3s release.
+// The script runs as root and is not part of the release checksum manifests,
+// so versions without a sum here need Config.InstallScriptSha256.
+var InstallScriptSums = map[string]string{}
+
+// InstallScriptSum returns the pinned sha256 of the install script of version,
+// sum when set
+func InstallScriptSum(version, sum string) (string, error) {
+	if sum != "" {
+		return sum, nil
+	}
+	if sum, ok := InstallScriptSums[version]; ok {
+		return sum, nil
+	}
+	return "", fmt.Errorf("no sha256 pinned for the k3s %s install script, set k3s.installScriptSha256 to the sha256sum of %s/%s/install.sh", version, scriptUrl, strings.ReplaceAll(version, "+", "%2B"))
 }
 
 var configTmpl = `---
@@ -59,50 +93,50 @@ kubelet-arg:
 `
 
 func Install(config Config, debug bool) error {
-	if config.Version == "" {
-		config.Version = "latest"
+	if config.Version == "" || config.Version == "latest" {
+		config.Version = DefaultVersion
 	}
 	if debug {
 		fmt.Printf("%+v\n", config)
 	}
 
+	// the install script runs as root, refuse to go on without its sum
+	scriptSum, err := InstallScriptSum(config.Version, config.InstallScriptSha256)
+	if err != nil {
+		return err
+	}
+
 	// prepare config file
-	err := osx.CreateDirIfNotExist(ConfigFileLocation)
+	err = osx.CreateDirIfNotExist(ConfigFileLocation)
 	if err != nil {
 		return err
 	}
 	targetFile := fmt.Sprintf("%s/%s", ConfigFileLocation, "config.yaml")
-	err = WriteTemplateToFile(configTmpl, config, targetFile)
+	b, err := RenderConfig(config)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(targetFile, b, 0600)
 	if err != nil {
 		return err
 	}
 
-	//err = PrintFileContents(targetFile)
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
+	// registries.yaml must be in place before k3s starts
+	err = ConfigureRegistries(config.Registries, debug)
+	if err != nil {
+		return fmt.Errorf("failed to configure registries \n %w", err)
+	}
 
-	// curl -sfL https://get.k3s.io -o k3s-install.sh
-	err = bash.ExecuteCmd(
-		"curl",
-		debug,
-		"https://get.k3s.io",
-		"-o",
-		InstallScript,
-	)
+	// fetch the install script and binary pinned to the release
+	err = fetchRelease(config.Version, scriptSum, debug)
 	if err != nil {
-		return fmt.Errorf("error while executing %s \n%v",
-			"curl https://get.k3s.io -o k3s-install.sh",
-			err,
-		)
+		return err
 	}
 
-	// sh ./k3s-install.sh -s - --write-kubeconfig-mode 644
-	err = os.Chmod("/tmp/k3s-install.sh", 0600)
+	// the binary is already in place, the script only sets up the service
+	err = os.Setenv("INSTALL_K3S_SKIP_DOWNLOAD", "true")
 	if err != nil {
-		return fmt.Errorf("error while running %s \n%v",
-			"chmod 0700 ./tmp/k3s-install.sh -s - --write-kubeconfig-mode 644",
-			err)
+		return fmt.Errorf("error while setting env var %s \n%v", "INSTALL_K3S_SKIP_DOWNLOAD", err)
 	}
 
 	// export INSTALL_K3S_VERSION
@@ -128,6 +162,91 @@ func Install(config Config, debug bool) error {
 	return nil
 }
 
+// fetchRelease downloads the install script and the k3s binary for version,
+// verifying the script against its pinned sum and the binary against the
+// release sha256sum manifest
+func fetchRelease(version, scriptSum string, debug bool) error {
+	binary, suffix, err := releaseAsset(runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	tag := strings.ReplaceAll(version, "+", "%2B")
+
+	script, err := download.Fetch(download.Artifact{
+		Name:    "k3s",
+		Version: version,
+		URL:     fmt.Sprintf("%s/%s/install.sh", scriptUrl, tag),
+		Sha256:  scriptSum,
+	}, debug)
+	if err != nil {
+		return fmt.Errorf("failed to download k3s install script \n %w", err)
+	}
+	err = download.Install(script, InstallScript, 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := download.Fetch(download.Artifact{
+		Name:        "k3s",
+		Version:     version,
+		URL:         fmt.Sprintf("%s/%s/%s", releaseUrl, tag, binary),
+		ChecksumURL: fmt.Sprintf("%s/%s/sha256sum-%s.txt", releaseUrl, tag, suffix),
+	}, debug)
+	if err != nil {
+		return fmt.Errorf("failed to download k3s %s \n %w", version, err)
+	}
+
+	return download.Install(file, BinaryPath, 0755)
+}
+
+// releaseAsset maps GOARCH to the k3s binary and checksum manifest names
+func releaseAsset(arch string) (string, string, error) {
+	switch arch {
+	case "amd64":
+		return "k3s", "amd64", nil
+	case "arm64":
+		return "k3s-arm64", "arm64", nil
+	case "arm":
+		return "k3s-armhf", "arm", nil
+	default:
+		return "", "", fmt.Errorf("unsupported k3s architecture %s", arch)
+	}
+}
+
+// RenderConfig returns the content of /etc/rancher/k3s/config.yaml for config
+func RenderConfig(config Config) ([]byte, error) {
+	tmpl, err := template.New("config").Parse(configTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// InstalledVersion returns the version of the installed k3s binary
+func InstalledVersion() (string, error) {
+	out, err := bash.ExecuteCmdWithOutput("k3s", "--version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get k3s version \n %w", err)
+	}
+	return ParseVersion(out), nil
+}
+
+// ParseVersion extracts the version from `k3s --version` or `rke2 --version`:
+// k3s version v1.31.4+k3s1 (a562d090)
+func ParseVersion(out string) string {
+	fields := strings.Fields(out)
+	for i, f := range fields {
+		if f == "version" && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
 func WriteTemplateToFile(templateStr string, config Config, outputFilePath string) error {
 	// Create a new template and parse the letter into it.
 	tmpl, err := template.New("myTemplate").Parse(templateStr)
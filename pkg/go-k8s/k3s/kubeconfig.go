@@ -2,41 +2,26 @@ package k3s
 
 import (
 	"fmt"
-	"os"
-	"strings"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 )
 
+// PrintKubeconfig prints the current context of kubeconfig with its server
+// url replaced by serverUrl when set
 func PrintKubeconfig(kubeconfig, serverUrl string) error {
-	const defaultUrl = "https://127.0.0.1:6443"
-	// read kubeconfig
-	kubeconfigContent, err := readKubeconfig(kubeconfig)
+	config, err := kubernetes.ExportKubeconfig(kubeconfig, kubernetes.ExportOptions{
+		Server: serverUrl,
+	})
 	if err != nil {
 		return err
 	}
 
-	if serverUrl == "" {
-		serverUrl = defaultUrl
+	b, err := kubernetes.SerializeKubeconfig(config)
+	if err != nil {
+		return err
 	}
 
-	// replace server url
-	kubeconfigContent = replaceValueInString(kubeconfigContent,
-		defaultUrl, serverUrl)
-
 	// print kubeconfig
-	fmt.Println(kubeconfigContent)
+	fmt.Println(string(b))
 
 	return nil
 }
-
-func readKubeconfig(kubeconfig string) (string, error) {
-	// read kubeconfig
-	kubeconfigContent, err := os.ReadFile(kubeconfig)
-	if err != nil {
-		return "", err
-	}
-	return string(kubeconfigContent), nil
-}
-
-func replaceValueInString(str string, oldValue string, newValue string) string {
-	return strings.ReplaceAll(str, oldValue, newValue)
-}
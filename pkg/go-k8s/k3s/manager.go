@@ -1,6 +1,8 @@
 package k3s
 
 // DefaultManager is the default implementation of K3sManager
+//
+// Deprecated: use distribution.K3sDistribution.
 type DefaultManager struct{}
 
 func (d DefaultManager) Install(cfg Config, debug bool) error {
@@ -0,0 +1,223 @@
+package k3s
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/secret"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
+	"github.com/zcubbs/hotpot/pkg/x/yaml"
+	"os"
+	"path/filepath"
+)
+
+const RegistriesFile = ConfigFileLocation + "/registries.yaml"
+const ServiceName = "k3s"
+
+// Registries holds the containerd mirror and registry configuration
+// rendered to /etc/rancher/k3s/registries.yaml
+type Registries struct {
+	Mirrors []Mirror
+	Configs []RegistryConfig
+}
+
+// Mirror redirects pulls for a registry to one or more endpoints
+type Mirror struct {
+	Registry  string
+	Endpoints []string
+	Rewrite   map[string]string
+}
+
+// RegistryConfig holds the auth and tls settings for a registry endpoint
+type RegistryConfig struct {
+	Registry           string
+	Username           string
+	Password           string
+	Token              string
+	CaFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+var registriesTmpl = `---
+{{- if .Mirrors }}
+mirrors:
+{{- range .Mirrors }}
+  {{ printf "%q" .Registry }}:
+    endpoint:
+{{- range .Endpoints }}
+      - {{ printf "%q" . }}
+{{- end }}
+{{- if .Rewrite }}
+    rewrite:
+{{- range $k, $v := .Rewrite }}
+      {{ printf "%q" $k }}: {{ printf "%q" $v }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Configs }}
+configs:
+{{- range .Configs }}
+  {{ printf "%q" .Registry }}:
+{{- if or .Username .Password .Token }}
+    auth:
+{{- if .Username }}
+      username: {{ printf "%q" .Username }}
+{{- end }}
+{{- if .Password }}
+      password: {{ printf "%q" .Password }}
+{{- end }}
+{{- if .Token }}
+      identitytoken: {{ printf "%q" .Token }}
+{{- end }}
+{{- end }}
+{{- if or .CaFile .CertFile .KeyFile .InsecureSkipVerify }}
+    tls:
+{{- if .CaFile }}
+      ca_file: {{ printf "%q" .CaFile }}
+{{- end }}
+{{- if .CertFile }}
+      cert_file: {{ printf "%q" .CertFile }}
+{{- end }}
+{{- if .KeyFile }}
+      key_file: {{ printf "%q" .KeyFile }}
+{{- end }}
+{{- if .InsecureSkipVerify }}
+      insecure_skip_verify: true
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+// WriteRegistries renders the registries file, resolving credentials through
+// the secret providers. It reports whether the file content changed.
+func WriteRegistries(registries Registries, debug bool) (bool, error) {
+	return WriteRegistriesFile(RegistriesFile, registries, debug)
+}
+
+// WriteRegistriesFile is WriteRegistries for another containerd based
+// distribution reading the same format from file
+func WriteRegistriesFile(file string, registries Registries, debug bool) (bool, error) {
+	configs := make([]RegistryConfig, len(registries.Configs))
+	copy(configs, registries.Configs)
+	registries.Configs = configs
+
+	for i, c := range registries.Configs {
+		if err := validateRegistryConfig(c); err != nil {
+			return false, err
+		}
+
+		username, err := secret.Provide(c.Username)
+		if err != nil {
+			return false, fmt.Errorf("failed to provide registry %s username \n %w", c.Registry, err)
+		}
+		password, err := secret.Provide(c.Password)
+		if err != nil {
+			return false, fmt.Errorf("failed to provide registry %s password \n %w", c.Registry, err)
+		}
+		token, err := secret.Provide(c.Token)
+		if err != nil {
+			return false, fmt.Errorf("failed to provide registry %s token \n %w", c.Registry, err)
+		}
+
+		registries.Configs[i].Username = username
+		registries.Configs[i].Password = password
+		registries.Configs[i].Token = token
+	}
+
+	for _, m := range registries.Mirrors {
+		if m.Registry == "" {
+			return false, fmt.Errorf("registry mirror name is required")
+		}
+		if len(m.Endpoints) == 0 {
+			return false, fmt.Errorf("registry mirror %s requires at least one endpoint", m.Registry)
+		}
+	}
+
+	b, err := yaml.ApplyTmpl(registriesTmpl, registries, false)
+	if err != nil {
+		return false, fmt.Errorf("failed to render registries template \n %w", err)
+	}
+
+	current, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s \n %w", file, err)
+	}
+	if bytes.Equal(current, b) {
+		if debug {
+			fmt.Printf("%s is up to date\n", file)
+		}
+		return false, nil
+	}
+
+	if err := osx.CreateDirIfNotExist(filepath.Dir(file)); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(file, b, 0600); err != nil {
+		return false, fmt.Errorf("failed to write %s \n %w", file, err)
+	}
+	if debug {
+		fmt.Printf("wrote %s\n", file)
+	}
+
+	return true, nil
+}
+
+// RemoveRegistriesFile removes a registries file left by an earlier recipe.
+// It reports whether the file existed.
+func RemoveRegistriesFile(file string, debug bool) (bool, error) {
+	err := os.Remove(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove %s \n %w", file, err)
+	}
+	if debug {
+		fmt.Printf("removed %s\n", file)
+	}
+	return true, nil
+}
+
+// ConfigureRegistries writes the registries file, or removes it when no
+// registry is configured, and restarts k3s when the file changed on a node
+// where k3s is already installed.
+func ConfigureRegistries(registries Registries, debug bool) error {
+	var changed bool
+	var err error
+	if len(registries.Mirrors) == 0 && len(registries.Configs) == 0 {
+		changed, err = RemoveRegistriesFile(RegistriesFile, debug)
+	} else {
+		changed, err = WriteRegistries(registries, debug)
+	}
+	if err != nil {
+		return err
+	}
+
+	if changed && IsInstalled() {
+		return osx.RestartSystemdService(ServiceName, debug)
+	}
+
+	return nil
+}
+
+func validateRegistryConfig(c RegistryConfig) error {
+	if c.Registry == "" {
+		return fmt.Errorf("registry config name is required")
+	}
+	if c.Token != "" && (c.Username != "" || c.Password != "") {
+		return fmt.Errorf("registry %s: token can't be combined with username/password", c.Registry)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("registry %s: certFile and keyFile must be set together", c.Registry)
+	}
+	return nil
+}
+
+// IsInstalled reports whether k3s was installed by the install script
+func IsInstalled() bool {
+	_, err := os.Stat(UninstallScript)
+	return err == nil
+}
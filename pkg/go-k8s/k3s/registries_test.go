@@ -0,0 +1,104 @@
+package k3s
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRegistriesFile(t *testing.T) {
+	t.Setenv("HOTPOT_REGISTRY_PASSWORD", "p4ss")
+	file := filepath.Join(t.TempDir(), "rancher", "registries.yaml")
+	registries := Registries{
+		Mirrors: []Mirror{
+			{Registry: "docker.io", Endpoints: []string{"https://mirror.internal:5000", "https://registry-1.docker.io"}},
+			{Registry: "quay.io", Endpoints: []string{"https://mirror.internal:5000"}, Rewrite: map[string]string{"^(.*)$": "quay/$1"}},
+		},
+		Configs: []RegistryConfig{
+			{Registry: "mirror.internal:5000", Username: "robot", Password: "env.HOTPOT_REGISTRY_PASSWORD", CaFile: "/etc/ssl/mirror-ca.crt"},
+			{Registry: "registry.internal", Token: "t0ken", CertFile: "/etc/ssl/client.crt", KeyFile: "/etc/ssl/client.key"},
+			{Registry: "lab.internal", InsecureSkipVerify: true},
+		},
+	}
+
+	changed, err := WriteRegistriesFile(file, registries, false)
+	if err != nil {
+		t.Fatalf("WriteRegistriesFile() error = %v", err)
+	}
+	if !changed {
+		t.Error("WriteRegistriesFile() changed = false for a new file")
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "registries.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("registries.yaml = \n%s\nwant \n%s", got, want)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("registries.yaml mode = %o, want 600", mode)
+	}
+	if registries.Configs[0].Password != "env.HOTPOT_REGISTRY_PASSWORD" {
+		t.Error("WriteRegistriesFile() resolved the credentials of the caller's configs")
+	}
+
+	changed, err = WriteRegistriesFile(file, registries, false)
+	if err != nil || changed {
+		t.Errorf("WriteRegistriesFile() = %v, %v, want an unchanged file", changed, err)
+	}
+}
+
+func TestWriteRegistriesFileInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		registries Registries
+		wantErr    string
+	}{
+		{name: "config without name", registries: Registries{Configs: []RegistryConfig{{Username: "robot"}}}, wantErr: "name is required"},
+		{name: "token and password", registries: Registries{Configs: []RegistryConfig{{Registry: "r", Token: "t", Password: "p"}}}, wantErr: "token can't be combined"},
+		{name: "cert without key", registries: Registries{Configs: []RegistryConfig{{Registry: "r", CertFile: "/c.crt"}}}, wantErr: "must be set together"},
+		{name: "unresolved password", registries: Registries{Configs: []RegistryConfig{{Registry: "r", Password: "env.HOTPOT_MISSING"}}}, wantErr: "password"},
+		{name: "mirror without name", registries: Registries{Mirrors: []Mirror{{Endpoints: []string{"https://m"}}}}, wantErr: "name is required"},
+		{name: "mirror without endpoint", registries: Registries{Mirrors: []Mirror{{Registry: "docker.io"}}}, wantErr: "at least one endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "registries.yaml")
+			_, err := WriteRegistriesFile(file, tt.registries, false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("WriteRegistriesFile() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Error("WriteRegistriesFile() wrote an invalid configuration")
+			}
+		})
+	}
+}
+
+func TestRemoveRegistriesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registries.yaml")
+	if _, err := WriteRegistriesFile(file, Registries{Mirrors: []Mirror{{Registry: "docker.io", Endpoints: []string{"https://m"}}}}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := RemoveRegistriesFile(file, false)
+	if err != nil || !removed {
+		t.Fatalf("RemoveRegistriesFile() = %v, %v, want the file removed", removed, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("registries.yaml still exists")
+	}
+	removed, err = RemoveRegistriesFile(file, false)
+	if err != nil || removed {
+		t.Errorf("RemoveRegistriesFile() = %v, %v, want nothing to remove", removed, err)
+	}
+}
@@ -0,0 +1,406 @@
+package k3s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/secret"
+	"github.com/zcubbs/hotpot/pkg/x/bash"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDataDir           = "/var/lib/rancher/k3s"
+	DefaultSnapshotDir       = "/var/backups/hotpot/snapshots"
+	DefaultSnapshotName      = "hotpot"
+	DefaultSnapshotRetention = 5
+)
+
+// Datastore is the k3s backing store a snapshot is taken from
+type Datastore string
+
+const (
+	Etcd   Datastore = "etcd"
+	Sqlite Datastore = "sqlite"
+)
+
+// SnapshotConfig describes where snapshots are read from and written to.
+// Snapshots are kept outside the k3s data dir by default so they survive
+// an uninstall.
+type SnapshotConfig struct {
+	DataDir   string
+	Dir       string
+	Name      string
+	Retention int
+	S3        SnapshotS3Config
+}
+
+// SnapshotS3Config is an S3-compatible target, e.g. AWS S3 or MinIO.
+// AccessKey and SecretKey accept secret provider references.
+type SnapshotS3Config struct {
+	Enabled       bool
+	Endpoint      string
+	EndpointCA    string
+	Bucket        string
+	Folder        string
+	Region        string
+	AccessKey     string
+	SecretKey     string
+	Insecure      bool
+	SkipSSLVerify bool
+}
+
+type Snapshot struct {
+	Name      string
+	Location  string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// DetectDatastore reports whether the node runs embedded etcd or sqlite
+func DetectDatastore(dataDir string) (Datastore, error) {
+	if dataDir == "" {
+		dataDir = DefaultDataDir
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "server", "db", "etcd")); err == nil {
+		return Etcd, nil
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "server", "db", "state.db")); err == nil {
+		return Sqlite, nil
+	}
+	return "", fmt.Errorf("no k3s datastore found in %s", dataDir)
+}
+
+// SaveSnapshot takes a snapshot of the datastore and uploads it to S3 when configured
+func SaveSnapshot(cfg SnapshotConfig, debug bool) (Snapshot, error) {
+	if err := setSnapshotDefaults(&cfg); err != nil {
+		return Snapshot{}, err
+	}
+	ds, err := DetectDatastore(cfg.DataDir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := osx.CreateDirIfNotExist(cfg.Dir); err != nil {
+		return Snapshot{}, err
+	}
+
+	if ds == Etcd {
+		args := append([]string{"etcd-snapshot", "save", "--name", cfg.Name}, etcdSnapshotArgs(cfg)...)
+		out, err := bash.ExecuteCmdWithEnv("k3s", etcdSnapshotEnv(cfg), args...)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to save etcd snapshot \n %s %w", out, err)
+		}
+		if debug {
+			fmt.Println(out)
+		}
+		return newestSnapshot(cfg, ds, debug)
+	}
+
+	snapshot, err := saveSqliteSnapshot(cfg, debug)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if cfg.S3.Enabled {
+		store, err := newS3Store(cfg.S3)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if err := store.Upload(context.Background(), snapshot.Location); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// ListSnapshots returns local and S3 snapshots, oldest first
+func ListSnapshots(cfg SnapshotConfig, debug bool) ([]Snapshot, error) {
+	if err := setSnapshotDefaults(&cfg); err != nil {
+		return nil, err
+	}
+	ds, err := DetectDatastore(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	return listSnapshots(cfg, ds, debug)
+}
+
+// RestoreSnapshot stops k3s, restores the named snapshot and starts k3s again,
+// also when the restore fails
+func RestoreSnapshot(cfg SnapshotConfig, name string, debug bool) (err error) {
+	if err := setSnapshotDefaults(&cfg); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("snapshot name is required")
+	}
+	ds, err := DetectDatastore(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if err := bash.ExecuteCmd("systemctl", debug, "stop", ServiceName); err != nil {
+		return fmt.Errorf("failed to stop %s \n %w", ServiceName, err)
+	}
+	// the node must not stay down, whether the restore succeeded or not
+	defer func() {
+		if startErr := bash.ExecuteCmd("systemctl", debug, "start", ServiceName); startErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to start %s \n %w", ServiceName, startErr))
+		}
+	}()
+
+	if ds == Etcd {
+		path := name
+		if !cfg.S3.Enabled && !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.Dir, name)
+		}
+		args := []string{"server", "--cluster-reset", "--cluster-reset-restore-path=" + path}
+		args = append(args, etcdSnapshotArgs(cfg)...)
+		out, err := bash.ExecuteCmdWithEnv("k3s", etcdSnapshotEnv(cfg), args...)
+		if err != nil {
+			return fmt.Errorf("failed to restore etcd snapshot %s \n %s %w", name, out, err)
+		}
+		if debug {
+			fmt.Println(out)
+		}
+		return nil
+	}
+	return restoreSqliteSnapshot(cfg, name, debug)
+}
+
+// PruneSnapshots deletes all but the newest cfg.Retention snapshots
+func PruneSnapshots(cfg SnapshotConfig, debug bool) error {
+	if err := setSnapshotDefaults(&cfg); err != nil {
+		return err
+	}
+	ds, err := DetectDatastore(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if ds == Etcd {
+		args := append([]string{"etcd-snapshot", "prune",
+			"--name", cfg.Name,
+			"--snapshot-retention", strconv.Itoa(cfg.Retention),
+		}, etcdSnapshotArgs(cfg)...)
+		out, err := bash.ExecuteCmdWithEnv("k3s", etcdSnapshotEnv(cfg), args...)
+		if err != nil {
+			return fmt.Errorf("failed to prune etcd snapshots \n %s %w", out, err)
+		}
+		if debug {
+			fmt.Println(out)
+		}
+		return nil
+	}
+
+	local, err := localStore{dir: cfg.Dir}.List(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, s := range expired(local, cfg.Retention) {
+		if debug {
+			fmt.Printf("pruning snapshot %s\n", s.Location)
+		}
+		if err := os.Remove(s.Location); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s \n %w", s.Name, err)
+		}
+	}
+
+	if cfg.S3.Enabled {
+		store, err := newS3Store(cfg.S3)
+		if err != nil {
+			return err
+		}
+		return pruneStore(context.Background(), store, cfg.Retention, debug)
+	}
+	return nil
+}
+
+func pruneStore(ctx context.Context, store *s3Store, retention int, debug bool) error {
+	remote, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range expired(remote, retention) {
+		if debug {
+			fmt.Printf("pruning snapshot %s\n", s.Location)
+		}
+		if err := store.Delete(ctx, s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired returns the snapshots beyond the retention count, oldest first
+func expired(snapshots []Snapshot, retention int) []Snapshot {
+	sortSnapshots(snapshots)
+	if retention <= 0 || len(snapshots) <= retention {
+		return nil
+	}
+	return snapshots[:len(snapshots)-retention]
+}
+
+func sortSnapshots(snapshots []Snapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+}
+
+func listSnapshots(cfg SnapshotConfig, ds Datastore, debug bool) ([]Snapshot, error) {
+	if ds == Etcd {
+		args := append([]string{"etcd-snapshot", "ls"}, etcdSnapshotArgs(cfg)...)
+		out, err := bash.ExecuteCmdWithEnv("k3s", etcdSnapshotEnv(cfg), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list etcd snapshots \n %s %w", out, err)
+		}
+		if debug {
+			fmt.Println(out)
+		}
+		snapshots := parseEtcdSnapshotList(out)
+		sortSnapshots(snapshots)
+		return snapshots, nil
+	}
+
+	snapshots, err := localStore{dir: cfg.Dir}.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.S3.Enabled {
+		store, err := newS3Store(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		remote, err := store.List(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, remote...)
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+func newestSnapshot(cfg SnapshotConfig, ds Datastore, debug bool) (Snapshot, error) {
+	snapshots, err := listSnapshots(cfg, ds, debug)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("snapshot saved but not found in %s", cfg.Dir)
+	}
+	return snapshots[len(snapshots)-1], nil
+}
+
+// parseEtcdSnapshotList parses the table printed by `k3s etcd-snapshot ls`:
+// Name Location Size Created
+func parseEtcdSnapshotList(out string) []Snapshot {
+	var snapshots []Snapshot
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "Name" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:      fields[0],
+			Location:  fields[1],
+			Size:      size,
+			CreatedAt: created,
+		})
+	}
+	return snapshots
+}
+
+func etcdSnapshotArgs(cfg SnapshotConfig) []string {
+	args := []string{"--data-dir", cfg.DataDir, "--etcd-snapshot-dir", cfg.Dir}
+	if !cfg.S3.Enabled {
+		return args
+	}
+	args = append(args,
+		"--etcd-s3",
+		"--etcd-s3-bucket", cfg.S3.Bucket,
+	)
+	if cfg.S3.Endpoint != "" {
+		args = append(args, "--etcd-s3-endpoint", cfg.S3.Endpoint)
+	}
+	if cfg.S3.EndpointCA != "" {
+		args = append(args, "--etcd-s3-endpoint-ca", cfg.S3.EndpointCA)
+	}
+	if cfg.S3.Folder != "" {
+		args = append(args, "--etcd-s3-folder", cfg.S3.Folder)
+	}
+	if cfg.S3.Region != "" {
+		args = append(args, "--etcd-s3-region", cfg.S3.Region)
+	}
+	if cfg.S3.Insecure {
+		args = append(args, "--etcd-s3-insecure")
+	}
+	if cfg.S3.SkipSSLVerify {
+		args = append(args, "--etcd-s3-skip-ssl-verify")
+	}
+	return args
+}
+
+// etcdSnapshotEnv passes the s3 credentials the way k3s reads them from the
+// environment, the command line would show them in the process list
+func etcdSnapshotEnv(cfg SnapshotConfig) []string {
+	if !cfg.S3.Enabled {
+		return nil
+	}
+	var env []string
+	if cfg.S3.AccessKey != "" {
+		env = append(env, "AWS_ACCESS_KEY_ID="+cfg.S3.AccessKey)
+	}
+	if cfg.S3.SecretKey != "" {
+		env = append(env, "AWS_SECRET_ACCESS_KEY="+cfg.S3.SecretKey)
+	}
+	return env
+}
+
+func setSnapshotDefaults(cfg *SnapshotConfig) error {
+	if cfg.DataDir == "" {
+		cfg.DataDir = DefaultDataDir
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = DefaultSnapshotDir
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultSnapshotName
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = DefaultSnapshotRetention
+	}
+
+	if !cfg.S3.Enabled {
+		return nil
+	}
+	if cfg.S3.Bucket == "" {
+		return fmt.Errorf("snapshot s3 bucket is required")
+	}
+	accessKey, err := secret.Provide(cfg.S3.AccessKey)
+	if err != nil {
+		return fmt.Errorf("failed to provide snapshot s3 access key \n %w", err)
+	}
+	secretKey, err := secret.Provide(cfg.S3.SecretKey)
+	if err != nil {
+		return fmt.Errorf("failed to provide snapshot s3 secret key \n %w", err)
+	}
+	cfg.S3.AccessKey = accessKey
+	cfg.S3.SecretKey = secretKey
+	return nil
+}
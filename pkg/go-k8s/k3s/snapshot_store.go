@@ -0,0 +1,411 @@
+package k3s
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/zcubbs/hotpot/pkg/x/bash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const sqliteSnapshotExt = ".tar.gz"
+
+// sqliteSnapshotPaths are archived relative to the k3s data dir
+var sqliteSnapshotPaths = []string{"server/db", "server/token"}
+
+// saveSqliteSnapshot archives the sqlite datastore and server token.
+// k3s is stopped while the archive is written so the database is consistent.
+func saveSqliteSnapshot(cfg SnapshotConfig, debug bool) (Snapshot, error) {
+	name := fmt.Sprintf("%s-%s%s", cfg.Name, time.Now().UTC().Format("20060102-150405"), sqliteSnapshotExt)
+	location := filepath.Join(cfg.Dir, name)
+
+	if IsInstalled() {
+		if err := bash.ExecuteCmd("systemctl", debug, "stop", ServiceName); err != nil {
+			return Snapshot{}, fmt.Errorf("failed to stop %s \n %w", ServiceName, err)
+		}
+		defer func() {
+			_ = bash.ExecuteCmd("systemctl", debug, "start", ServiceName)
+		}()
+	}
+
+	if err := writeArchive(location, cfg.DataDir, sqliteSnapshotPaths); err != nil {
+		_ = os.Remove(location)
+		return Snapshot{}, fmt.Errorf("failed to save sqlite snapshot \n %w", err)
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if debug {
+		fmt.Printf("saved snapshot %s\n", location)
+	}
+
+	return Snapshot{
+		Name:      name,
+		Location:  location,
+		Size:      info.Size(),
+		CreatedAt: info.ModTime(),
+	}, nil
+}
+
+// restoreSqliteSnapshot replaces the datastore with the content of the
+// named archive, fetching it from S3 first when it isn't available locally.
+// k3s must be stopped by the caller.
+func restoreSqliteSnapshot(cfg SnapshotConfig, name string, debug bool) error {
+	location := name
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(cfg.Dir, name)
+	}
+
+	if _, err := os.Stat(location); os.IsNotExist(err) && cfg.S3.Enabled {
+		store, err := newS3Store(cfg.S3)
+		if err != nil {
+			return err
+		}
+		if err := store.Download(context.Background(), filepath.Base(name), location); err != nil {
+			return err
+		}
+	}
+
+	// the archive is extracted next to the datastore, which is only replaced
+	// once the whole snapshot was read
+	staging, err := os.MkdirTemp(cfg.DataDir, ".hotpot-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create restore dir \n %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractArchive(location, staging); err != nil {
+		return fmt.Errorf("failed to restore sqlite snapshot %s \n %w", name, err)
+	}
+	if _, err := os.Stat(filepath.Join(staging, "server", "db")); err != nil {
+		return fmt.Errorf("failed to restore sqlite snapshot %s: no datastore in the archive", name)
+	}
+	if err := swapPaths(staging, cfg.DataDir, sqliteSnapshotPaths); err != nil {
+		return fmt.Errorf("failed to restore sqlite snapshot %s \n %w", name, err)
+	}
+	if debug {
+		fmt.Printf("restored snapshot %s\n", location)
+	}
+	return nil
+}
+
+// swapPaths moves the paths found in staging into root. The paths they
+// replace are moved aside first and put back when a move fails.
+func swapPaths(staging, root string, paths []string) error {
+	type swapped struct{ live, aside string }
+	var done []swapped
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			_ = os.RemoveAll(done[i].live)
+			if done[i].aside != "" {
+				_ = os.Rename(done[i].aside, done[i].live)
+			}
+		}
+	}
+
+	for _, p := range paths {
+		staged := filepath.Join(staging, filepath.FromSlash(p))
+		if _, err := os.Lstat(staged); os.IsNotExist(err) {
+			continue
+		}
+		live := filepath.Join(root, filepath.FromSlash(p))
+		s := swapped{live: live}
+		if _, err := os.Lstat(live); err == nil {
+			s.aside = live + ".hotpot-old"
+			if err := os.RemoveAll(s.aside); err != nil {
+				rollback()
+				return err
+			}
+			if err := os.Rename(live, s.aside); err != nil {
+				rollback()
+				return err
+			}
+		}
+		done = append(done, s)
+		if err := os.MkdirAll(filepath.Dir(live), 0700); err != nil {
+			rollback()
+			return err
+		}
+		if err := os.Rename(staged, live); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	for _, s := range done {
+		if s.aside != "" {
+			_ = os.RemoveAll(s.aside)
+		}
+	}
+	return nil
+}
+
+func writeArchive(dest, root string, paths []string) error {
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	for _, p := range paths {
+		err := filepath.Walk(filepath.Join(root, p), func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			src, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(tw, src)
+			return err
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func extractArchive(src, root string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(root)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in snapshot: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// localStore lists sqlite snapshot archives in the snapshot dir
+type localStore struct {
+	dir string
+}
+
+func (l localStore) List(_ context.Context) ([]Snapshot, error) {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots in %s \n %w", l.dir, err)
+	}
+
+	var snapshots []Snapshot
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), sqliteSnapshotExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:      e.Name(),
+			Location:  filepath.Join(l.dir, e.Name()),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+	}
+	return snapshots, nil
+}
+
+// s3Store keeps sqlite snapshot archives in an S3-compatible bucket
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	folder string
+}
+
+func newS3Store(cfg SnapshotS3Config) (*s3Store, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	secure := !cfg.Insecure
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+		secure = u.Scheme == "https"
+	}
+
+	transport, err := s3Transport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:    secure,
+		Region:    cfg.Region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client \n %w", err)
+	}
+
+	return &s3Store{
+		client: client,
+		bucket: cfg.Bucket,
+		folder: strings.Trim(cfg.Folder, "/"),
+	}, nil
+}
+
+func s3Transport(cfg SnapshotS3Config) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.EndpointCA == "" && !cfg.SkipSSLVerify {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.SkipSSLVerify} // #nosec G402 opt-in via skipSSLVerify
+	if cfg.EndpointCA != "" {
+		ca, err := os.ReadFile(cfg.EndpointCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read s3 endpoint ca %s \n %w", cfg.EndpointCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.EndpointCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func (s *s3Store) key(name string) string {
+	if s.folder == "" {
+		return name
+	}
+	return path.Join(s.folder, name)
+}
+
+func (s *s3Store) Upload(ctx context.Context, file string) error {
+	name := filepath.Base(file)
+	_, err := s.client.FPutObject(ctx, s.bucket, s.key(name), file, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot %s to s3 \n %w", name, err)
+	}
+	return nil
+}
+
+func (s *s3Store) Download(ctx context.Context, name, dest string) error {
+	if err := s.client.FGetObject(ctx, s.bucket, s.key(name), dest, minio.GetObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to download snapshot %s from s3 \n %w", name, err)
+	}
+	return nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, s.key(name), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s from s3 \n %w", name, err)
+	}
+	return nil
+}
+
+func (s *s3Store) List(ctx context.Context) ([]Snapshot, error) {
+	prefix := ""
+	if s.folder != "" {
+		prefix = s.folder + "/"
+	}
+
+	var snapshots []Snapshot
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list snapshots in s3 \n %w", obj.Err)
+		}
+		name := path.Base(obj.Key)
+		if !strings.HasSuffix(name, sqliteSnapshotExt) {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:      name,
+			Location:  fmt.Sprintf("s3://%s/%s", s.bucket, obj.Key),
+			Size:      obj.Size,
+			CreatedAt: obj.LastModified,
+		})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,373 @@
+package k3s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type s3Object struct {
+	data     []byte
+	modified time.Time
+}
+
+// s3StandIn serves the bucket snapshots like S3, objects are a minute apart
+func s3StandIn(t *testing.T) (*httptest.Server, map[string]s3Object) {
+	t.Helper()
+	var mu sync.Mutex
+	objects := make(map[string]s3Object)
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if bucket != "snapshots" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code></Error>`)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			type content struct {
+				Key          string
+				Size         int64
+				LastModified string
+				ETag         string
+			}
+			var list struct {
+				XMLName  xml.Name `xml:"ListBucketResult"`
+				Name     string
+				Prefix   string
+				KeyCount int
+				Contents []content
+			}
+			list.Name, list.Prefix = bucket, r.URL.Query().Get("prefix")
+			for k, o := range objects {
+				if strings.HasPrefix(k, list.Prefix) {
+					list.Contents = append(list.Contents, content{Key: k, Size: int64(len(o.data)), LastModified: o.modified.Format(time.RFC3339), ETag: etag(o.data)})
+				}
+			}
+			sort.Slice(list.Contents, func(i, j int) bool { return list.Contents[i].Key < list.Contents[j].Key })
+			list.KeyCount = len(list.Contents)
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+				data = unchunk(data)
+			}
+			created = created.Add(time.Minute)
+			objects[key] = s3Object{data: data, modified: created}
+			w.Header().Set("ETag", etag(data))
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			o, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				if r.Method == http.MethodGet {
+					_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+				}
+				return
+			}
+			w.Header().Set("ETag", etag(o.data))
+			w.Header().Set("Last-Modified", o.modified.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", fmt.Sprint(len(o.data)))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(o.data)
+			}
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, objects
+}
+
+// unchunk decodes an aws-chunked body, e.g. 26;chunk-signature=...\r\n<data>\r\n0;...
+func unchunk(body []byte) []byte {
+	var data []byte
+	for len(body) > 0 {
+		header, rest, _ := bytes.Cut(body, []byte("\r\n"))
+		size, _, _ := strings.Cut(string(header), ";")
+		var n int
+		if _, err := fmt.Sscanf(size, "%x", &n); err != nil || n == 0 || n > len(rest) {
+			break
+		}
+		data = append(data, rest[:n]...)
+		body = bytes.TrimPrefix(rest[n:], []byte("\r\n"))
+	}
+	return data
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestS3Store(t *testing.T) {
+	server, objects := s3StandIn(t)
+	ctx := context.Background()
+	store, err := newS3Store(SnapshotS3Config{
+		Endpoint:  server.URL,
+		Bucket:    "snapshots",
+		Folder:    "/node-1/",
+		Region:    "us-east-1",
+		AccessKey: "hotpot",
+		SecretKey: "s3cr3t",
+	})
+	if err != nil {
+		t.Fatalf("newS3Store() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	names := []string{"hotpot-20260101-000100.tar.gz", "hotpot-20260101-000200.tar.gz", "hotpot-20260101-000300.tar.gz"}
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("snapshot "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Upload(ctx, file); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+	objects["node-1/notes.txt"] = s3Object{data: []byte("not a snapshot")}
+	if _, ok := objects["node-1/"+names[0]]; !ok {
+		t.Fatalf("objects = %v, want the snapshots in the folder", objects)
+	}
+
+	snapshots, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snapshots) != 3 || snapshots[0].Location != "s3://snapshots/node-1/"+names[0] {
+		t.Errorf("List() = %+v, want the 3 snapshots", snapshots)
+	}
+
+	dest := filepath.Join(t.TempDir(), names[1])
+	if err := store.Download(ctx, names[1], dest); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "snapshot "+names[1] {
+		t.Errorf("downloaded %q, want the uploaded snapshot", got)
+	}
+	if err := store.Download(ctx, "missing.tar.gz", filepath.Join(t.TempDir(), "missing.tar.gz")); err == nil {
+		t.Error("Download() of a missing snapshot succeeded")
+	}
+
+	if err := pruneStore(ctx, store, 1, false); err != nil {
+		t.Fatalf("pruneStore() error = %v", err)
+	}
+	snapshots, err = store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != names[2] {
+		t.Errorf("snapshots after prune = %+v, want only the newest", snapshots)
+	}
+	if _, ok := objects["node-1/notes.txt"]; !ok {
+		t.Error("pruneStore() deleted an object that is not a snapshot")
+	}
+}
+
+func TestParseEtcdSnapshotList(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []Snapshot
+	}{
+		{name: "empty", out: ""},
+		{name: "header only", out: "Name Location Size Created\n"},
+		{
+			name: "local and s3",
+			out: `Name                               Location                                                       Size     Created
+hotpot-node-1-1767225600           file:///var/lib/rancher/k3s/server/db/snapshots/hotpot-node-1-1767225600 6561824  2026-01-01T00:00:00Z
+hotpot-node-1-1767229200.s3        s3://snapshots/node-1/hotpot-node-1-1767229200                 6561856  2026-01-01T01:00:00Z
+`,
+			want: []Snapshot{
+				{Name: "hotpot-node-1-1767225600", Location: "file:///var/lib/rancher/k3s/server/db/snapshots/hotpot-node-1-1767225600", Size: 6561824, CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Name: "hotpot-node-1-1767229200.s3", Location: "s3://snapshots/node-1/hotpot-node-1-1767229200", Size: 6561856, CreatedAt: time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)},
+			},
+		},
+		{name: "log lines", out: "level=info msg=\"Managed etcd cluster not yet initialized\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseEtcdSnapshotList(tt.out)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseEtcdSnapshotList() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseEtcdSnapshotList()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	at := func(h int) Snapshot {
+		return Snapshot{Name: fmt.Sprintf("s%d", h), CreatedAt: time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC)}
+	}
+	tests := []struct {
+		name      string
+		snapshots []Snapshot
+		retention int
+		want      []string
+	}{
+		{name: "none", retention: 5},
+		{name: "within retention", snapshots: []Snapshot{at(1), at(2)}, retention: 2},
+		{name: "oldest first", snapshots: []Snapshot{at(3), at(1), at(4), at(2)}, retention: 2, want: []string{"s1", "s2"}},
+		{name: "keep all", snapshots: []Snapshot{at(1), at(2)}, retention: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range expired(tt.snapshots, tt.retention) {
+				got = append(got, s.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// archive writes a snapshot archive holding the files
+func archive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchive(t *testing.T) {
+	root := t.TempDir()
+	if err := extractArchive(archive(t, map[string]string{"server/db/state.db": "db"}), root); err != nil {
+		t.Fatalf("extractArchive() error = %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "server", "db", "state.db")); string(got) != "db" {
+		t.Errorf("state.db = %q, want db", got)
+	}
+
+	root = filepath.Join(t.TempDir(), "k3s")
+	err := extractArchive(archive(t, map[string]string{"../escaped": "x"}), root)
+	if err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Errorf("extractArchive() error = %v, want the path rejected", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escaped")); !os.IsNotExist(err) {
+		t.Error("extractArchive() wrote outside the root")
+	}
+}
+
+func TestRestoreSqliteSnapshot(t *testing.T) {
+	dataDir := t.TempDir()
+	db := filepath.Join(dataDir, "server", "db", "state.db")
+	token := filepath.Join(dataDir, "server", "token")
+	live := func() {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(db), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(db, []byte("live"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(token, []byte("live-token"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	live()
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.tar.gz")
+	b, _ := os.ReadFile(archive(t, map[string]string{"server/db/state.db": strings.Repeat("snapshot", 1024)}))
+	if err := os.WriteFile(corrupt, b[:len(b)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]string{
+		"corrupt":        corrupt,
+		"path traversal": archive(t, map[string]string{"server/db/state.db": "snapshot", "../../escaped": "x"}),
+		"no datastore":   archive(t, map[string]string{"server/token": "snapshot-token"}),
+	}
+	for name, path := range bad {
+		t.Run(name, func(t *testing.T) {
+			if err := restoreSqliteSnapshot(SnapshotConfig{DataDir: dataDir}, path, false); err == nil {
+				t.Fatal("restoreSqliteSnapshot() error = nil, want an error")
+			}
+			if got, _ := os.ReadFile(db); string(got) != "live" {
+				t.Errorf("state.db = %q, want the live datastore kept", got)
+			}
+			if got, _ := os.ReadFile(token); string(got) != "live-token" {
+				t.Errorf("token = %q, want the live token kept", got)
+			}
+		})
+	}
+
+	path := archive(t, map[string]string{"server/db/state.db": "snapshot", "server/token": "snapshot-token"})
+	if err := os.WriteFile(filepath.Join(filepath.Dir(db), "state.db-wal"), []byte("wal"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreSqliteSnapshot(SnapshotConfig{DataDir: dataDir}, path, false); err != nil {
+		t.Fatalf("restoreSqliteSnapshot() error = %v", err)
+	}
+	if got, _ := os.ReadFile(db); string(got) != "snapshot" {
+		t.Errorf("state.db = %q, want the snapshot", got)
+	}
+	if got, _ := os.ReadFile(token); string(got) != "snapshot-token" {
+		t.Errorf("token = %q, want the snapshot token", got)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(db), "state.db-wal")); !os.IsNotExist(err) {
+		t.Error("the write-ahead log of the replaced datastore was kept")
+	}
+	entries, _ := os.ReadDir(dataDir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".hotpot-restore-") {
+			t.Errorf("restore dir %s left behind", e.Name())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "server", "db.hotpot-old")); !os.IsNotExist(err) {
+		t.Error("the replaced datastore was left behind")
+	}
+}
@@ -3,18 +3,40 @@ package k9s
 import (
 	"fmt"
 	"github.com/zcubbs/hotpot/pkg/x/bash"
+	"github.com/zcubbs/hotpot/pkg/x/download"
+	"runtime"
+	"strings"
 )
 
-// Install installs k9s
-func Install(debug bool) error {
+const (
+	// DefaultVersion is the k9s release installed when none is pinned
+	DefaultVersion = "v0.32.7"
+	BinaryPath     = "/usr/local/bin/k9s"
+	ReleaseUrl     = "https://github.com/derailed/k9s/releases/download"
+)
+
+// Install installs the given k9s release, verified against its published checksums
+func Install(version string, debug bool) error {
 	fmt.Printf("🔨 Installing k9s...\n")
 
-	cmd := "curl -fsSL -o k9s.tar.gz https://github.com/derailed/k9s/releases/latest/download/k9s_Linux_amd64.tar.gz && " +
-		"tar -xzf k9s.tar.gz && " +
-		"mv k9s /usr/local/bin/ && " +
-		"rm k9s.tar.gz"
+	if version == "" || version == "latest" {
+		version = DefaultVersion
+	}
 
-	err := bash.ExecuteCmd(cmd, debug)
+	// release assets are named like k9s_Linux_amd64.tar.gz
+	goos := strings.ToUpper(runtime.GOOS[:1]) + runtime.GOOS[1:]
+	name := fmt.Sprintf("k9s_%s_%s.tar.gz", goos, runtime.GOARCH)
+	file, err := download.Fetch(download.Artifact{
+		Name:        "k9s",
+		Version:     version,
+		URL:         fmt.Sprintf("%s/%s/%s", ReleaseUrl, version, name),
+		ChecksumURL: fmt.Sprintf("%s/%s/checksums.sha256", ReleaseUrl, version),
+	}, debug)
+	if err != nil {
+		return fmt.Errorf("failed to install k9s: %w", err)
+	}
+
+	err = download.ExtractFile(file, "k9s", BinaryPath, 0755)
 	if err != nil {
 		return fmt.Errorf("failed to install k9s: %w", err)
 	}
@@ -26,7 +48,7 @@ func Install(debug bool) error {
 func Uninstall(debug bool) error {
 	fmt.Printf("🔨 Uninstalling k9s...\n")
 
-	cmd := "rm -f /usr/local/bin/k9s"
+	cmd := "rm -f " + BinaryPath
 
 	err := bash.ExecuteCmd(cmd, debug)
 	if err != nil {
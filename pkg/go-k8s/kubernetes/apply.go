@@ -1,57 +1,247 @@
 package kubernetes
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/zcubbs/hotpot/pkg/x/bash"
 	"github.com/zcubbs/hotpot/pkg/x/yaml"
-	"os"
+	"io"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	sigsyaml "sigs.k8s.io/yaml"
 	"time"
 )
 
+// FieldManager owns the fields hotpot applies
+const FieldManager = "hotpot"
+
+// crdTimeout bounds how long apply waits for CRDs to be established and served
+const crdTimeout = 2 * time.Minute
+
+var crdKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// ApplyResult is the outcome of applying one object of a manifest
+type ApplyResult struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (r ApplyResult) String() string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + r.Name
+	}
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s failed: %s", r.Kind, name, r.Err)
+	}
+	return fmt.Sprintf("%s %s applied", r.Kind, name)
+}
+
 func ApplyManifest(manifestTmpl string, data interface{}, debug bool) error {
-	return ApplyManifestWithKc(manifestTmpl, data, "", debug)
+	return ApplyManifestWithKc(manifestTmpl, data, "", nil, debug)
 }
 
-func ApplyManifestWithKc(manifestTmpl string, data interface{}, kubeconfig string, debug bool) error {
+// ApplyManifestWithKc renders manifestTmpl with data and server-side applies it
+// with labels added to every object.
+// An empty kubeconfig is resolved like kubectl does, KUBECONFIG then ~/.kube/config.
+func ApplyManifestWithKc(manifestTmpl string, data interface{}, kubeconfig string, labels map[string]string, debug bool) error {
 	b, err := yaml.ApplyTmpl(manifestTmpl, data, debug)
 	if err != nil {
 		return fmt.Errorf("failed to apply template \n %w", err)
 	}
 
-	// generate tmp file name
-	tmpDir := os.TempDir()
-	fn := fmt.Sprintf("%s%stmpManifest_%s.yaml",
-		tmpDir,
-		string(os.PathSeparator),
-		time.Unix(time.Now().Unix(), 0).Format("20060102150405"),
-	)
-	if debug {
-		fmt.Printf("tmp manifest file: %s\n", fn)
+	_, err = Apply(context.Background(), kubeconfig, b, labels, debug)
+	if err != nil {
+		return fmt.Errorf("failed to apply manifest \n %w", err)
 	}
+	return nil
+}
 
-	// write tmp manifest
-	err = os.WriteFile(fn, b, 0600)
+// Apply server-side applies every object of a multi-document yaml manifest,
+// with labels added, and returns the result of each. CRDs are applied first
+// and waited for, so the manifest may hold custom resources of the CRDs it defines.
+func Apply(ctx context.Context, kubeconfig string, manifest []byte, labels map[string]string, debug bool) ([]ApplyResult, error) {
+	objs, err := decodeManifest(manifest)
 	if err != nil {
-		return fmt.Errorf("failed to write tmp manifest \n %w", err)
+		return nil, err
 	}
 
-	fmt.Printf("Executing command: kubectl --kubeconfig %s apply -f %s\n", kubeconfig, fn)
-	if kubeconfig != "" {
-		err = bash.ExecuteCmd("kubectl", debug, "--kubeconfig", kubeconfig, "apply", "-f", fn)
-		if err != nil {
-			return fmt.Errorf("failed to apply manifest \n %w", err)
+	config, err := restConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+	return applyObjects(ctx, client, mapper, objs, labels, debug)
+}
+
+// applyObjects is Apply for decoded objects, mapper is reset once the CRDs are established
+func applyObjects(ctx context.Context, client dynamic.Interface, mapper meta.ResettableRESTMapper, objs []*unstructured.Unstructured, labels map[string]string, debug bool) ([]ApplyResult, error) {
+	if len(labels) > 0 {
+		for _, obj := range objs {
+			obj.SetLabels(MergeLabels(obj.GetLabels(), labels))
 		}
+	}
+
+	var crds, others []*unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() == crdKind {
+			crds = append(crds, obj)
+		} else {
+			others = append(others, obj)
+		}
+	}
+
+	var results []ApplyResult
+	var errs []error
+	apply := func(obj *unstructured.Unstructured) {
+		result := applyObject(ctx, client, mapper, obj)
+		if debug {
+			fmt.Println(result)
+		}
+		if result.Err != nil {
+			errs = append(errs, errors.New(result.String()))
+		}
+		results = append(results, result)
+	}
+
+	for _, crd := range crds {
+		apply(crd)
+	}
+	for _, crd := range crds {
+		if err := waitForCRD(ctx, client, crd.GetName(), debug); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(crds) > 0 {
+		mapper.Reset()
+	}
+	for _, obj := range others {
+		apply(obj)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func applyObject(ctx context.Context, client dynamic.Interface, mapper meta.ResettableRESTMapper, obj *unstructured.Unstructured) ApplyResult {
+	result := ApplyResult{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+
+	mapping, err := restMapping(ctx, mapper, obj.GroupVersionKind())
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var resource dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if result.Namespace == "" {
+			result.Namespace = metav1.NamespaceDefault
+		}
+		resource = client.Resource(mapping.Resource).Namespace(result.Namespace)
 	} else {
-		err = bash.ExecuteCmd("kubectl", debug, "apply", "-f", fn)
+		result.Namespace = ""
+	}
+
+	_, result.Err = resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	return result
+}
+
+// restMapping resolves the resource of gvk, refreshing discovery until a CRD
+// applied by someone else, e.g. a helm chart, is served
+func restMapping(ctx context.Context, mapper meta.ResettableRESTMapper, gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	var mapping *meta.RESTMapping
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, crdTimeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			mapper.Reset()
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no resource found for %s: %w", gvk, err)
+	}
+	return mapping, nil
+}
+
+// waitForCRD waits for the CRD called name to be established
+func waitForCRD(ctx context.Context, client dynamic.Interface, name string, debug bool) error {
+	ctx, cancel := context.WithTimeout(ctx, crdTimeout)
+	defer cancel()
+	// CRDs have no pods to explain, no clientset needed
+	return waitFor(ctx, client, nil, WaitTarget{Kind: KindCRD, Name: name}, debug)
+}
+
+// Manifest returns the multi-document yaml manifest of objects
+func Manifest(objects ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, obj := range objects {
+		b, err := sigsyaml.Marshal(obj)
 		if err != nil {
-			return fmt.Errorf("failed to apply manifest \n %w", err)
+			return nil, err
 		}
+		buf.WriteString("---\n")
+		buf.Write(b)
 	}
+	return buf.Bytes(), nil
+}
 
-	// delete tmp manifest
-	err = os.Remove(fn)
-	if err != nil {
-		return fmt.Errorf("failed to delete tmp manifest \n %w", err)
+// decodeManifest splits a multi-document yaml manifest into objects, skipping empty documents
+func decodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	var objs []*unstructured.Unstructured
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("manifest object without kind or name: %v", obj.Object)
+		}
+		objs = append(objs, obj)
 	}
-	return nil
+	return objs, nil
+}
+
+// restConfig loads kubeconfig, or the default kubeconfig like kubectl when empty
+func restConfig(kubeconfig string) (*rest.Config, error) {
+	return contextRestConfig(kubeconfig, "")
+}
+
+// contextRestConfig is restConfig for kubeContext, the current context when empty
+func contextRestConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
 }
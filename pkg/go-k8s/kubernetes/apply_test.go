@@ -0,0 +1,241 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"testing"
+)
+
+func TestDecodeManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifest  string
+		wantNames []string
+		wantErr   string
+	}{
+		{
+			name: "multi document",
+			manifest: `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team-a
+data:
+  key: value
+`,
+			wantNames: []string{"team-a", "settings"},
+		},
+		{
+			name:      "empty documents",
+			manifest:  "---\n---\n# only a comment\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: team-a\n---\n",
+			wantNames: []string{"team-a"},
+		},
+		{name: "empty manifest", manifest: ""},
+		{name: "bad yaml", manifest: "apiVersion: v1\nkind: [Namespace\n", wantErr: "failed to decode manifest"},
+		{name: "without name", manifest: "apiVersion: v1\nkind: Namespace\nmetadata: {}\n", wantErr: "without kind or name"},
+		{name: "without kind", manifest: "apiVersion: v1\nmetadata:\n  name: team-a\n", wantErr: "without kind or name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := decodeManifest([]byte(tt.manifest))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeManifest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeManifest() error = %v", err)
+			}
+			var names []string
+			for _, obj := range objs {
+				names = append(names, obj.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("decodeManifest() = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+var (
+	namespaces    = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	configMaps    = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	crds          = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	widgets       = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	widgetsServed = metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+	}
+)
+
+// applyClients returns a fake cluster serving namespaces, config maps and CRDs.
+// Widgets are only served once their CRD was applied.
+func applyClients(t *testing.T) (*dynamicfake.FakeDynamicClient, *restmapper.DeferredDiscoveryRESTMapper) {
+	t.Helper()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		namespaces: "NamespaceList",
+		configMaps: "ConfigMapList",
+		crds:       "CustomResourceDefinitionList",
+		widgets:    "WidgetList",
+	})
+	discovery := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace"},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+		}},
+		{GroupVersion: "apiextensions.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition"},
+		}},
+	}}}
+	// the fake tracker only patches existing objects, apply creates them like the api server
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		tracker := client.Tracker()
+		gvr, ns := action.GetResource(), action.GetNamespace()
+		if _, err := tracker.Get(gvr, ns, patch.GetName()); apierrors.IsNotFound(err) {
+			return true, obj, tracker.Create(gvr, obj, ns)
+		}
+		return true, obj, tracker.Update(gvr, obj, ns)
+	})
+	client.PrependReactor("patch", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		discovery.Resources = append(discovery.Resources, &widgetsServed)
+		return false, nil, nil
+	})
+	return client, restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discovery))
+}
+
+const widgetManifest = `---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: gear
+  namespace: team-a
+spec:
+  size: 3
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+status:
+  conditions:
+    - type: Established
+      status: "True"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+  labels:
+    team: a
+`
+
+func TestApplyObjects(t *testing.T) {
+	ctx := context.Background()
+	client, mapper := applyClients(t)
+	objs, err := decodeManifest([]byte(widgetManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := applyObjects(ctx, client, mapper, objs, map[string]string{ManagedByLabel: "hotpot"}, false)
+	if err != nil {
+		t.Fatalf("applyObjects() error = %v", err)
+	}
+
+	// the CRD is applied first, the widget of the manifest is only served after it
+	var order []string
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "patch" {
+			order = append(order, a.GetResource().Resource)
+		}
+	}
+	if len(order) != 3 || order[0] != "customresourcedefinitions" {
+		t.Errorf("applied %v, want the CRD first", order)
+	}
+	if len(results) != 3 || results[0].Kind != "CustomResourceDefinition" {
+		t.Fatalf("applyObjects() = %v, want a result per object, the CRD first", results)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s", r)
+		}
+	}
+
+	widget, err := client.Resource(widgets).Namespace("team-a").Get(ctx, "gear", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("widget not applied \n %v", err)
+	}
+	if widget.GetLabels()[ManagedByLabel] != "hotpot" {
+		t.Errorf("widget labels = %v, want the managed label", widget.GetLabels())
+	}
+	ns, err := client.Resource(namespaces).Get(ctx, "team-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("namespace not applied \n %v", err)
+	}
+	if labels := ns.GetLabels(); labels["team"] != "a" || labels[ManagedByLabel] != "hotpot" {
+		t.Errorf("namespace labels = %v, want its own and the managed label", labels)
+	}
+}
+
+func TestApplyObjectsErrors(t *testing.T) {
+	ctx := context.Background()
+	client, mapper := applyClients(t)
+	client.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.PatchAction).GetName() == "denied" {
+			return true, nil, errors.New("admission webhook denied the request")
+		}
+		return false, nil, nil
+	})
+
+	configMap := func(name, namespace string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		return obj
+	}
+	objs := []*unstructured.Unstructured{configMap("denied", "team-a"), configMap("settings", "")}
+
+	results, err := applyObjects(ctx, client, mapper, objs, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "ConfigMap team-a/denied failed: admission webhook denied the request") {
+		t.Fatalf("applyObjects() error = %v, want the denied object", err)
+	}
+	if len(results) != 2 || results[0].Err == nil || results[1].Err != nil {
+		t.Fatalf("applyObjects() = %v, want the denied object failed and the next applied", results)
+	}
+	// namespaced objects without a namespace go to the default one
+	if results[1].Namespace != metav1.NamespaceDefault {
+		t.Errorf("result namespace = %q, want default", results[1].Namespace)
+	}
+	if _, err := client.Resource(configMaps).Namespace(metav1.NamespaceDefault).Get(ctx, "settings", metav1.GetOptions{}); err != nil {
+		t.Errorf("config map after the failed one not applied \n %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// GetNodes returns the nodes of the cluster without their managed fields
+func GetNodes(ctx context.Context, kubeconfig string) ([]v1.Node, error) {
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes.Items {
+		nodes.Items[i].ManagedFields = nil
+	}
+	return nodes.Items, nil
+}
+
+// DescribePods returns a table of the pods of every namespace with their
+// phase, ready containers and restarts
+func DescribePods(ctx context.Context, kubeconfig string) (string, error) {
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	pods, err := cs.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAMESPACE\tNAME\tPHASE\tREADY\tRESTARTS\tNODE\tAGE\tREASON")
+	for _, pod := range pods.Items {
+		ready, restarts, reason := 0, int32(0), pod.Status.Reason
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Ready {
+				ready++
+			}
+			restarts += c.RestartCount
+			if c.State.Waiting != nil && reason == "" {
+				reason = c.State.Waiting.Reason
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\t%s\n",
+			pod.Namespace, pod.Name, pod.Status.Phase, ready, len(pod.Spec.Containers), restarts,
+			pod.Spec.NodeName, time.Since(pod.CreationTimestamp.Time).Round(time.Second), reason)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DescribeEvents returns the events of every namespace seen within since, oldest first
+func DescribeEvents(ctx context.Context, kubeconfig string, since time.Duration) (string, error) {
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	events, err := cs.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	lastSeen := func(e v1.Event) time.Time {
+		if !e.LastTimestamp.IsZero() {
+			return e.LastTimestamp.Time
+		}
+		if !e.EventTime.IsZero() {
+			return e.EventTime.Time
+		}
+		return e.CreationTimestamp.Time
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool { return lastSeen(items[i]).Before(lastSeen(items[j])) })
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LAST SEEN\tNAMESPACE\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	cutoff := time.Now().Add(-since)
+	for _, e := range items {
+		seen := lastSeen(e)
+		if seen.Before(cutoff) {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/%s\t%d\t%s\n",
+			seen.Format(time.RFC3339), e.Namespace, e.Type, e.Reason,
+			e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Count, e.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// GetPodLogs returns the last tailLines lines of every container of the pods
+// in namespace, keyed by <pod>/<container>
+func GetPodLogs(ctx context.Context, kubeconfig, namespace string, tailLines int64) (map[string][]byte, error) {
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make(map[string][]byte)
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			b, err := cs.CoreV1().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container: c.Name,
+				TailLines: &tailLines,
+			}).DoRaw(ctx)
+			if err != nil {
+				b = []byte(fmt.Sprintf("failed to get logs: %s\n", err))
+			}
+			logs[fmt.Sprintf("%s/%s", pod.Name, c.Name)] = b
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,252 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"time"
+)
+
+// IssueMethod is how the credentials of an issued kubeconfig are obtained
+type IssueMethod string
+
+const (
+	// IssueServiceAccount binds a ServiceAccount and mints a token through the TokenRequest API
+	IssueServiceAccount IssueMethod = "serviceaccount"
+	// IssueCertificate signs a client certificate through the CertificateSigningRequest API
+	IssueCertificate IssueMethod = "certificate"
+)
+
+// IssueOptions describes a scoped kubeconfig
+type IssueOptions struct {
+	User string
+	// Namespace scopes the binding. Cluster wide when empty.
+	Namespace string
+	// Role is the ClusterRole to bind, e.g. view, edit or admin
+	Role string
+	// Groups are added to the certificate subject, certificate method only
+	Groups []string
+	TTL    time.Duration
+	Method IssueMethod
+	// ClusterName names the cluster entry of the issued kubeconfig
+	ClusterName string
+	// Server overrides the server url taken from the admin kubeconfig
+	Server string
+}
+
+// IssueKubeconfig creates the identity and role binding described by opts
+// using the admin kubeconfig and returns a kubeconfig for it.
+func IssueKubeconfig(ctx context.Context, kubeconfig string, opts IssueOptions) (*clientcmdapi.Config, error) {
+	if opts.User == "" {
+		return nil, fmt.Errorf("user is required")
+	}
+	if opts.Role == "" {
+		return nil, fmt.Errorf("role is required")
+	}
+
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return issueKubeconfig(ctx, cs, kubeconfig, opts)
+}
+
+// issueKubeconfig issues the kubeconfig with cs, the cluster entry is taken from the admin kubeconfig
+func issueKubeconfig(ctx context.Context, cs kubernetes.Interface, kubeconfig string, opts IssueOptions) (*clientcmdapi.Config, error) {
+	if opts.ClusterName == "" {
+		opts.ClusterName = ManagedByValue
+	}
+
+	if _, err := cs.RbacV1().ClusterRoles().Get(ctx, opts.Role, metav1.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to get cluster role %s \n %w", opts.Role, err)
+	}
+
+	admin, err := ExportKubeconfig(kubeconfig, ExportOptions{Server: opts.Server})
+	if err != nil {
+		return nil, err
+	}
+	cluster := admin.Clusters[admin.Contexts[admin.CurrentContext].Cluster]
+
+	var user *clientcmdapi.AuthInfo
+	var subject rbacv1.Subject
+	switch opts.Method {
+	case IssueServiceAccount, "":
+		subject, user, err = issueServiceAccount(ctx, cs, opts)
+	case IssueCertificate:
+		subject, user, err = issueCertificate(ctx, cs, opts)
+	default:
+		return nil, fmt.Errorf("unknown issue method %s", opts.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bindRole(ctx, cs, opts, subject); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s@%s", opts.User, opts.ClusterName)
+	config := clientcmdapi.NewConfig()
+	config.Clusters[opts.ClusterName] = &clientcmdapi.Cluster{
+		Server:                   cluster.Server,
+		CertificateAuthorityData: cluster.CertificateAuthorityData,
+		InsecureSkipTLSVerify:    cluster.InsecureSkipTLSVerify,
+	}
+	config.AuthInfos[name] = user
+	config.Contexts[name] = &clientcmdapi.Context{
+		Cluster:   opts.ClusterName,
+		AuthInfo:  name,
+		Namespace: opts.Namespace,
+	}
+	config.CurrentContext = name
+	return config, nil
+}
+
+func issueServiceAccount(ctx context.Context, cs kubernetes.Interface, opts IssueOptions) (rbacv1.Subject, *clientcmdapi.AuthInfo, error) {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = v1.NamespaceDefault
+	}
+
+	sa := &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.User,
+			Namespace: namespace,
+			Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+		},
+	}
+	_, err := cs.CoreV1().ServiceAccounts(namespace).Create(ctx, sa, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to create service account %s/%s \n %w", namespace, opts.User, err)
+	}
+
+	seconds := int64(opts.TTL.Seconds())
+	tr, err := cs.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, opts.User, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to create token for %s/%s \n %w", namespace, opts.User, err)
+	}
+
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: opts.User, Namespace: namespace}
+	return subject, &clientcmdapi.AuthInfo{Token: tr.Status.Token}, nil
+}
+
+func issueCertificate(ctx context.Context, cs kubernetes.Interface, opts IssueOptions) (rbacv1.Subject, *clientcmdapi.AuthInfo, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return rbacv1.Subject{}, nil, err
+	}
+	csrDer, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: opts.User, Organization: opts.Groups},
+	}, key)
+	if err != nil {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to create certificate request \n %w", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return rbacv1.Subject{}, nil, err
+	}
+
+	seconds := int32(opts.TTL.Seconds())
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("hotpot-%s-", opts.User),
+			Labels:       map[string]string{ManagedByLabel: ManagedByValue},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:           pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDer}),
+			SignerName:        certificatesv1.KubeAPIServerClientSignerName,
+			ExpirationSeconds: &seconds,
+			Usages:            []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+	csr, err = cs.CertificatesV1().CertificateSigningRequests().Create(ctx, csr, metav1.CreateOptions{})
+	if err != nil {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to create certificate signing request \n %w", err)
+	}
+
+	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:           certificatesv1.CertificateApproved,
+		Status:         v1.ConditionTrue,
+		Reason:         "HotpotIssue",
+		Message:        "approved by hotpot kc issue",
+		LastUpdateTime: metav1.Now(),
+	})
+	_, err = cs.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+	if err != nil {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to approve certificate signing request %s \n %w", csr.Name, err)
+	}
+
+	var cert []byte
+	err = wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		c, err := cs.CertificatesV1().CertificateSigningRequests().Get(ctx, csr.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cond := range c.Status.Conditions {
+			if cond.Type == certificatesv1.CertificateDenied || cond.Type == certificatesv1.CertificateFailed {
+				return false, fmt.Errorf("certificate signing request %s %s: %s", csr.Name, cond.Type, cond.Message)
+			}
+		}
+		cert = c.Status.Certificate
+		return len(cert) > 0, nil
+	})
+	if err != nil {
+		return rbacv1.Subject{}, nil, fmt.Errorf("failed to get signed certificate for %s \n %w", csr.Name, err)
+	}
+
+	subject := rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: opts.User}
+	return subject, &clientcmdapi.AuthInfo{
+		ClientCertificateData: cert,
+		ClientKeyData:         pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}, nil
+}
+
+// bindRole binds the ClusterRole to the subject, in the namespace when set
+// or cluster wide otherwise
+func bindRole(ctx context.Context, cs kubernetes.Interface, opts IssueOptions, subject rbacv1.Subject) error {
+	meta := metav1.ObjectMeta{
+		Name:   fmt.Sprintf("hotpot:%s:%s", opts.User, opts.Role),
+		Labels: map[string]string{ManagedByLabel: ManagedByValue},
+	}
+	roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: opts.Role}
+	subjects := []rbacv1.Subject{subject}
+
+	if opts.Namespace == "" {
+		crb := &rbacv1.ClusterRoleBinding{ObjectMeta: meta, RoleRef: roleRef, Subjects: subjects}
+		_, err := cs.RbacV1().ClusterRoleBindings().Create(ctx, crb, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = cs.RbacV1().ClusterRoleBindings().Update(ctx, crb, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to bind cluster role %s \n %w", opts.Role, err)
+		}
+		return nil
+	}
+
+	meta.Namespace = opts.Namespace
+	rb := &rbacv1.RoleBinding{ObjectMeta: meta, RoleRef: roleRef, Subjects: subjects}
+	_, err := cs.RbacV1().RoleBindings(opts.Namespace).Create(ctx, rb, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = cs.RbacV1().RoleBindings(opts.Namespace).Update(ctx, rb, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind role %s in %s \n %w", opts.Role, opts.Namespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+// issueClientSet returns a fake cluster with the edit ClusterRole, which
+// answers token requests and signs the approved certificate signing requests
+func issueClientSet(t *testing.T) *fake.Clientset {
+	t.Helper()
+	cs := fake.NewClientset(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}})
+
+	cs.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+		tr.Status.Token = "token-" + action.(k8stesting.CreateActionImpl).Name
+		return true, tr, nil
+	})
+	// the fake tracker neither generates names nor signs certificates
+	cs.PrependReactor("create", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if csr.Name == "" {
+			csr.Name = csr.GenerateName + "x7k2p"
+		}
+		return false, nil, nil
+	})
+	cs.PrependReactor("update", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if action.GetSubresource() == "approval" && isApproved(csr) {
+			csr.Status.Certificate = []byte("signed " + csr.Name)
+		}
+		return false, nil, nil
+	})
+	return cs
+}
+
+func isApproved(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificatesv1.CertificateApproved && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIssueServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	cs := issueClientSet(t)
+	admin := writeKubeconfig(t, kubeconfig("https://127.0.0.1:6443", "default"))
+
+	config, err := issueKubeconfig(ctx, cs, admin, IssueOptions{
+		User:      "alice",
+		Namespace: "hub",
+		Role:      "edit",
+		TTL:       2 * time.Hour,
+		Server:    "https://k3s.example.com:6443",
+	})
+	if err != nil {
+		t.Fatalf("issueKubeconfig() error = %v", err)
+	}
+
+	if _, err := cs.CoreV1().ServiceAccounts("hub").Get(ctx, "alice", metav1.GetOptions{}); err != nil {
+		t.Errorf("service account hub/alice not created \n %v", err)
+	}
+	var tokenRequest *authenticationv1.TokenRequest
+	for _, a := range cs.Actions() {
+		if a.GetVerb() == "create" && a.GetSubresource() == "token" {
+			tokenRequest = a.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		}
+	}
+	if tokenRequest == nil || *tokenRequest.Spec.ExpirationSeconds != 7200 {
+		t.Errorf("token request = %v, want one expiring after the ttl", tokenRequest)
+	}
+
+	rb, err := cs.RbacV1().RoleBindings("hub").Get(ctx, "hotpot:alice:edit", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("role binding not created \n %v", err)
+	}
+	want := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "alice", Namespace: "hub"}
+	if len(rb.Subjects) != 1 || rb.Subjects[0] != want || rb.RoleRef.Name != "edit" {
+		t.Errorf("role binding = %v -> %v, want %v -> edit", rb.Subjects, rb.RoleRef, want)
+	}
+
+	context := config.Contexts[config.CurrentContext]
+	if config.CurrentContext != "alice@hotpot" || context.Namespace != "hub" {
+		t.Errorf("context = %s %+v, want alice@hotpot in hub", config.CurrentContext, context)
+	}
+	if got := config.AuthInfos[context.AuthInfo].Token; got != "token-alice" {
+		t.Errorf("token = %q, want the requested one", got)
+	}
+	if got := config.Clusters[context.Cluster].Server; got != "https://k3s.example.com:6443" {
+		t.Errorf("server = %q, want the override", got)
+	}
+}
+
+func TestIssueCertificate(t *testing.T) {
+	ctx := context.Background()
+	cs := issueClientSet(t)
+	admin := writeKubeconfig(t, kubeconfig("https://127.0.0.1:6443", "default"))
+
+	config, err := issueKubeconfig(ctx, cs, admin, IssueOptions{
+		User:   "ci",
+		Role:   "edit",
+		Groups: []string{"deployers"},
+		TTL:    24 * time.Hour,
+		Method: IssueCertificate,
+	})
+	if err != nil {
+		t.Fatalf("issueKubeconfig() error = %v", err)
+	}
+
+	csr, err := cs.CertificatesV1().CertificateSigningRequests().Get(ctx, "hotpot-ci-x7k2p", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("certificate signing request not created \n %v", err)
+	}
+	if !isApproved(csr) {
+		t.Error("certificate signing request not approved")
+	}
+	if csr.Spec.SignerName != certificatesv1.KubeAPIServerClientSignerName || *csr.Spec.ExpirationSeconds != 86400 {
+		t.Errorf("certificate signing request spec = %s %d, want a client certificate expiring after the ttl",
+			csr.Spec.SignerName, *csr.Spec.ExpirationSeconds)
+	}
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil {
+		t.Fatal("certificate request is not pem encoded")
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Subject.CommonName != "ci" || len(request.Subject.Organization) != 1 || request.Subject.Organization[0] != "deployers" {
+		t.Errorf("certificate subject = %v, want ci in deployers", request.Subject)
+	}
+
+	crb, err := cs.RbacV1().ClusterRoleBindings().Get(ctx, "hotpot:ci:edit", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cluster role binding not created \n %v", err)
+	}
+	want := rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "ci"}
+	if len(crb.Subjects) != 1 || crb.Subjects[0] != want || crb.RoleRef.Name != "edit" {
+		t.Errorf("cluster role binding = %v -> %v, want %v -> edit", crb.Subjects, crb.RoleRef, want)
+	}
+
+	user := config.AuthInfos[config.Contexts[config.CurrentContext].AuthInfo]
+	if string(user.ClientCertificateData) != "signed hotpot-ci-x7k2p" {
+		t.Errorf("client certificate = %q, want the signed one", user.ClientCertificateData)
+	}
+	if block, _ := pem.Decode(user.ClientKeyData); block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Error("client key is not a pem encoded ec key")
+	}
+}
+
+func TestIssueRebindsRole(t *testing.T) {
+	ctx := context.Background()
+	cs := issueClientSet(t)
+	admin := writeKubeconfig(t, kubeconfig("https://127.0.0.1:6443", "default"))
+	opts := IssueOptions{User: "alice", Role: "edit", TTL: time.Hour}
+
+	// issuing again reuses the service account and updates the binding
+	for i := 0; i < 2; i++ {
+		if _, err := issueKubeconfig(ctx, cs, admin, opts); err != nil {
+			t.Fatalf("issueKubeconfig() error = %v", err)
+		}
+	}
+	crb, err := cs.RbacV1().ClusterRoleBindings().Get(ctx, "hotpot:alice:edit", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "alice", Namespace: v1.NamespaceDefault}
+	if len(crb.Subjects) != 1 || crb.Subjects[0] != want {
+		t.Errorf("cluster role binding subjects = %v, want %v", crb.Subjects, want)
+	}
+
+	opts.Role = "missing"
+	if _, err := issueKubeconfig(ctx, cs, admin, opts); err == nil {
+		t.Error("issueKubeconfig() bound an unknown cluster role")
+	}
+}
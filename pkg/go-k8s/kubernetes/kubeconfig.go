@@ -0,0 +1,246 @@
+package kubernetes
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ExportOptions controls how a kubeconfig is rewritten for use on another machine
+type ExportOptions struct {
+	// Context to export, defaults to the current context
+	Context string
+	// Name replaces the context, cluster and user names when set
+	Name string
+	// Server replaces the cluster server url when set
+	Server string
+	// CaFile is embedded as the cluster certificate authority when set
+	CaFile string
+}
+
+// ExportKubeconfig loads the kubeconfig at path and returns a standalone
+// config holding only the selected context, with credentials embedded.
+func ExportKubeconfig(path string, opts ExportOptions) (*clientcmdapi.Config, error) {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s \n %w", path, err)
+	}
+
+	if opts.Context != "" {
+		config.CurrentContext = opts.Context
+	}
+	if _, ok := config.Contexts[config.CurrentContext]; !ok {
+		return nil, fmt.Errorf("context %q not found in %s", config.CurrentContext, path)
+	}
+
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to minify kubeconfig \n %w", err)
+	}
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to flatten kubeconfig \n %w", err)
+	}
+
+	context := config.Contexts[config.CurrentContext]
+	cluster := config.Clusters[context.Cluster]
+	user := config.AuthInfos[context.AuthInfo]
+
+	if opts.Server != "" {
+		cluster.Server = opts.Server
+	}
+	if opts.CaFile != "" {
+		ca, err := os.ReadFile(opts.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file %s \n %w", opts.CaFile, err)
+		}
+		cluster.CertificateAuthority = ""
+		cluster.CertificateAuthorityData = ca
+		cluster.InsecureSkipTLSVerify = false
+	}
+
+	if opts.Name == "" {
+		return config, nil
+	}
+
+	// rename the context and what it refers to
+	exported := clientcmdapi.NewConfig()
+	exported.Clusters[opts.Name] = cluster
+	if user != nil {
+		exported.AuthInfos[opts.Name] = user
+		context.AuthInfo = opts.Name
+	}
+	context.Cluster = opts.Name
+	exported.Contexts[opts.Name] = context
+	exported.CurrentContext = opts.Name
+	return exported, nil
+}
+
+// MergeKubeconfig merges src into the kubeconfig file at dest, creating it when missing.
+// Clusters, users and contexts that already exist with different content are
+// reported as conflicts unless overwrite is set. The current context of dest
+// is switched to the one of src when setCurrent is set.
+func MergeKubeconfig(src *clientcmdapi.Config, dest string, overwrite, setCurrent bool) error {
+	config, err := loadOrNew(dest)
+	if err != nil {
+		return err
+	}
+
+	var conflicts []string
+	for name, c := range src.Clusters {
+		if existing, ok := config.Clusters[name]; ok && !overwrite && !equal(existing, c) {
+			conflicts = append(conflicts, "cluster "+name)
+		}
+	}
+	for name, u := range src.AuthInfos {
+		if existing, ok := config.AuthInfos[name]; ok && !overwrite && !equal(existing, u) {
+			conflicts = append(conflicts, "user "+name)
+		}
+	}
+	for name, c := range src.Contexts {
+		if existing, ok := config.Contexts[name]; ok && !overwrite && !equal(existing, c) {
+			conflicts = append(conflicts, "context "+name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("kubeconfig %s already has a different %s, rename or overwrite", dest, strings.Join(conflicts, ", "))
+	}
+
+	for name, c := range src.Clusters {
+		config.Clusters[name] = c
+	}
+	for name, u := range src.AuthInfos {
+		config.AuthInfos[name] = u
+	}
+	for name, c := range src.Contexts {
+		config.Contexts[name] = c
+	}
+	if setCurrent || config.CurrentContext == "" {
+		config.CurrentContext = src.CurrentContext
+	}
+
+	return WriteKubeconfig(config, dest)
+}
+
+// UseContext sets the current context of the kubeconfig file at path
+func UseContext(path, context string) error {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig %s \n %w", path, err)
+	}
+	if _, ok := config.Contexts[context]; !ok {
+		return fmt.Errorf("context %q not found in %s", context, path)
+	}
+	config.CurrentContext = context
+	return WriteKubeconfig(config, path)
+}
+
+// ListContexts returns the context names of the kubeconfig file at path and the current one
+func ListContexts(path string) ([]string, string, error) {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig %s \n %w", path, err)
+	}
+	var names []string
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	return names, config.CurrentContext, nil
+}
+
+// DistributionKubeconfigs are written by the k3s and rke2 services, which own
+// their content and mode. They are never rewritten in place.
+var DistributionKubeconfigs = []string{"/etc/rancher/k3s/k3s.yaml", "/etc/rancher/rke2/rke2.yaml"}
+
+// WriteKubeconfig writes config to path with owner-only permissions
+func WriteKubeconfig(config *clientcmdapi.Config, path string) error {
+	if isDistributionKubeconfig(path) {
+		return fmt.Errorf("kubeconfig %s belongs to the distribution, merge it into another kubeconfig with hotpot kc merge", path)
+	}
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		return fmt.Errorf("failed to write kubeconfig %s \n %w", path, err)
+	}
+	return os.Chmod(path, 0600)
+}
+
+// SerializeKubeconfig returns config as yaml
+func SerializeKubeconfig(config *clientcmdapi.Config) ([]byte, error) {
+	return clientcmd.Write(*config)
+}
+
+// isDistributionKubeconfig reports whether path is, or links to, a distribution kubeconfig
+func isDistributionKubeconfig(path string) bool {
+	for _, kc := range DistributionKubeconfigs {
+		if SamePath(kc, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// SamePath reports whether a and b name the same file once made absolute and
+// their symlinks resolved
+func SamePath(a, b string) bool {
+	return resolvePath(a) == resolvePath(b)
+}
+
+func resolvePath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		return real
+	}
+	// a file that doesn't exist yet, e.g. a new merge target
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+		return filepath.Join(dir, filepath.Base(p))
+	}
+	return p
+}
+
+func loadOrNew(path string) (*clientcmdapi.Config, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return clientcmdapi.NewConfig(), nil
+	}
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s \n %w", path, err)
+	}
+	return config, nil
+}
+
+// equal ignores LocationOfOrigin which is set on load and never serialized,
+// and tells no difference between empty and missing extensions
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case *clientcmdapi.Cluster:
+		y := *b.(*clientcmdapi.Cluster)
+		c := *x
+		c.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		c.Extensions, y.Extensions = nilIfEmpty(c.Extensions), nilIfEmpty(y.Extensions)
+		return reflect.DeepEqual(c, y)
+	case *clientcmdapi.AuthInfo:
+		y := *b.(*clientcmdapi.AuthInfo)
+		u := *x
+		u.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		u.Extensions, y.Extensions = nilIfEmpty(u.Extensions), nilIfEmpty(y.Extensions)
+		return reflect.DeepEqual(u, y)
+	case *clientcmdapi.Context:
+		y := *b.(*clientcmdapi.Context)
+		c := *x
+		c.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		c.Extensions, y.Extensions = nilIfEmpty(c.Extensions), nilIfEmpty(y.Extensions)
+		return reflect.DeepEqual(c, y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func nilIfEmpty(m map[string]runtime.Object) map[string]runtime.Object {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,250 @@
+package kubernetes
+
+import (
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// kubeconfig returns a config with one context per name, each with its own cluster and user
+func kubeconfig(server string, names ...string) *clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+	for _, name := range names {
+		config.Clusters[name] = &clientcmdapi.Cluster{Server: server, CertificateAuthorityData: []byte("ca")}
+		config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: "token-" + name}
+		config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	}
+	if len(names) > 0 {
+		config.CurrentContext = names[0]
+	}
+	return config
+}
+
+func writeKubeconfig(t *testing.T, config *clientcmdapi.Config) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExportKubeconfig(t *testing.T) {
+	path := writeKubeconfig(t, kubeconfig("https://127.0.0.1:6443", "default", "other"))
+
+	config, err := ExportKubeconfig(path, ExportOptions{Context: "other", Name: "lab", Server: "https://10.0.0.1:6443"})
+	if err != nil {
+		t.Fatalf("ExportKubeconfig() error = %v", err)
+	}
+	if len(config.Contexts) != 1 || config.CurrentContext != "lab" {
+		t.Fatalf("ExportKubeconfig() contexts = %v, current %q, want only lab", config.Contexts, config.CurrentContext)
+	}
+	if got := config.Clusters["lab"].Server; got != "https://10.0.0.1:6443" {
+		t.Errorf("server = %q, want the override", got)
+	}
+	if got := config.AuthInfos["lab"].Token; got != "token-other" {
+		t.Errorf("token = %q, want the one of the exported context", got)
+	}
+
+	if _, err := ExportKubeconfig(path, ExportOptions{Context: "missing"}); err == nil {
+		t.Error("ExportKubeconfig() exported an unknown context")
+	}
+}
+
+func TestMergeKubeconfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		dest        *clientcmdapi.Config
+		src         *clientcmdapi.Config
+		overwrite   bool
+		setCurrent  bool
+		wantErr     string
+		wantCurrent string
+		wantServer  string
+	}{
+		{
+			name:        "new file",
+			src:         kubeconfig("https://lab:6443", "lab"),
+			wantCurrent: "lab",
+			wantServer:  "https://lab:6443",
+		},
+		{
+			name:        "keeps the current context",
+			dest:        kubeconfig("https://prod:6443", "prod"),
+			src:         kubeconfig("https://lab:6443", "lab"),
+			wantCurrent: "prod",
+			wantServer:  "https://lab:6443",
+		},
+		{
+			name:        "sets the current context",
+			dest:        kubeconfig("https://prod:6443", "prod"),
+			src:         kubeconfig("https://lab:6443", "lab"),
+			setCurrent:  true,
+			wantCurrent: "lab",
+			wantServer:  "https://lab:6443",
+		},
+		{
+			name:        "identical entries",
+			dest:        kubeconfig("https://lab:6443", "lab"),
+			src:         kubeconfig("https://lab:6443", "lab"),
+			wantCurrent: "lab",
+			wantServer:  "https://lab:6443",
+		},
+		{
+			name:    "conflict",
+			dest:    kubeconfig("https://old:6443", "lab"),
+			src:     kubeconfig("https://lab:6443", "lab"),
+			wantErr: "already has a different cluster lab",
+		},
+		{
+			name:        "overwrite",
+			dest:        kubeconfig("https://old:6443", "lab"),
+			src:         kubeconfig("https://lab:6443", "lab"),
+			overwrite:   true,
+			wantCurrent: "lab",
+			wantServer:  "https://lab:6443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "config")
+			if tt.dest != nil {
+				dest = writeKubeconfig(t, tt.dest)
+			}
+
+			err := MergeKubeconfig(tt.src, dest, tt.overwrite, tt.setCurrent)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MergeKubeconfig() error = %v, want %q", err, tt.wantErr)
+				}
+				if got, _ := clientcmd.LoadFromFile(dest); got.Clusters["lab"].Server != tt.dest.Clusters["lab"].Server {
+					t.Error("MergeKubeconfig() changed the kubeconfig on conflict")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeKubeconfig() error = %v", err)
+			}
+
+			got, err := clientcmd.LoadFromFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.CurrentContext != tt.wantCurrent {
+				t.Errorf("current context = %q, want %q", got.CurrentContext, tt.wantCurrent)
+			}
+			if got.Clusters["lab"].Server != tt.wantServer {
+				t.Errorf("server = %q, want %q", got.Clusters["lab"].Server, tt.wantServer)
+			}
+			if tt.dest == nil {
+				return
+			}
+			for name := range tt.dest.Contexts {
+				if _, ok := got.Contexts[name]; !ok {
+					t.Errorf("MergeKubeconfig() dropped context %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestUseContext(t *testing.T) {
+	path := writeKubeconfig(t, kubeconfig("https://127.0.0.1:6443", "default", "other"))
+
+	if err := UseContext(path, "other"); err != nil {
+		t.Fatalf("UseContext() error = %v", err)
+	}
+	names, current, err := ListContexts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != "other" || len(names) != 2 {
+		t.Errorf("ListContexts() = %v, %q, want other to be current", names, current)
+	}
+
+	if err := UseContext(path, "missing"); err == nil {
+		t.Error("UseContext() switched to an unknown context")
+	}
+}
+
+func TestWriteKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKubeconfig(kubeconfig("https://127.0.0.1:6443", "default"), path); err != nil {
+		t.Fatalf("WriteKubeconfig() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("mode = %o, want 600", mode)
+	}
+}
+
+func TestWriteDistributionKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	distribution := filepath.Join(dir, "k3s.yaml")
+	original := kubeconfig("https://127.0.0.1:6443", "default")
+	if err := clientcmd.WriteToFile(*original, distribution); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config")
+	if err := os.Symlink(distribution, link); err != nil {
+		t.Fatal(err)
+	}
+	defer func(paths []string) { DistributionKubeconfigs = paths }(DistributionKubeconfigs)
+	DistributionKubeconfigs = []string{distribution}
+
+	for _, path := range []string{distribution, link} {
+		if err := UseContext(path, "default"); err == nil {
+			t.Errorf("UseContext(%s) rewrote the distribution kubeconfig", path)
+		}
+		if err := MergeKubeconfig(kubeconfig("https://lab:6443", "lab"), path, false, false); err == nil {
+			t.Errorf("MergeKubeconfig(%s) rewrote the distribution kubeconfig", path)
+		}
+	}
+	got, err := clientcmd.LoadFromFile(distribution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Contexts["lab"]; ok {
+		t.Error("the distribution kubeconfig was changed")
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	if err := os.WriteFile(config, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(config, link); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: config, b: config, want: true},
+		{a: config, b: link, want: true},
+		{a: config, b: "config", want: true},
+		{a: config, b: filepath.Join(dir, ".", "config"), want: true},
+		{a: config, b: filepath.Join(dir, "other"), want: false},
+		// a target that doesn't exist yet
+		{a: filepath.Join(dir, "new"), b: "new", want: true},
+	}
+	for _, tt := range tests {
+		if got := SamePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("SamePath(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
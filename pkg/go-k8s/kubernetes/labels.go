@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "hotpot"
+	// RecipeLabel identifies the recipe an object was created by
+	RecipeLabel = "hotpot.zcubbs.dev/recipe"
+	// RevisionLabel identifies the cook run that last applied an object
+	RevisionLabel = "hotpot.zcubbs.dev/revision"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// ManagedLabels returns the labels of the objects hotpot creates for recipe
+// during the cook run revision. Empty values are left out.
+func ManagedLabels(recipe, revision string) map[string]string {
+	labels := map[string]string{ManagedByLabel: ManagedByValue}
+	if v := LabelValue(recipe); v != "" {
+		labels[RecipeLabel] = v
+	}
+	if v := LabelValue(revision); v != "" {
+		labels[RevisionLabel] = v
+	}
+	return labels
+}
+
+// LabelValue turns s into a valid label value: lowercase alphanumerics, '-',
+// '_' and '.', at most 63 characters, starting and ending alphanumeric
+func LabelValue(s string) string {
+	v := invalidLabelChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(v) > 63 {
+		v = v[:63]
+	}
+	return strings.Trim(v, "-_.")
+}
+
+// MergeLabels returns labels with extra added, extra taking precedence
+func MergeLabels(labels, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(labels)+len(extra))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
@@ -3,10 +3,21 @@ package kubernetes
 import (
 	"context"
 	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	errosv1 "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// QuotaName, LimitRangeName and DefaultDenyName name the policies ApplyNamespace creates
+	QuotaName       = "hotpot-quota"
+	LimitRangeName  = "hotpot-limits"
+	DefaultDenyName = "default-deny"
+)
+
+// PodSecurityLevels are the Pod Security Admission levels
+var PodSecurityLevels = []string{"privileged", "baseline", "restricted"}
+
 func CreateNamespace(kubeconfig string, namespace []string) error {
 	cs, err := GetClientSet(kubeconfig)
 	if err != nil {
@@ -35,3 +46,77 @@ func CreateNamespace(kubeconfig string, namespace []string) error {
 
 	return nil
 }
+
+// NamespaceSpec is a namespace with its baseline policies
+type NamespaceSpec struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// PodSecurity is the Pod Security Admission level enforced, audited and warned about
+	PodSecurity string
+	// Quota holds the hard limits of a ResourceQuota
+	Quota apiv1.ResourceList
+	// LimitRange holds the container defaults and bounds of a LimitRange
+	LimitRange apiv1.LimitRangeItem
+	// DefaultDeny denies all ingress traffic to the pods of the namespace
+	DefaultDeny bool
+}
+
+// ApplyNamespace creates or updates the namespace of spec, like CreateNamespace,
+// together with its quota, limit range and default deny network policy.
+// labels are added to every object.
+func ApplyNamespace(ctx context.Context, kubeconfig string, spec NamespaceSpec, labels map[string]string, debug bool) error {
+	manifest, err := NamespaceManifest(spec)
+	if err != nil {
+		return err
+	}
+	_, err = Apply(ctx, kubeconfig, manifest, labels, debug)
+	return err
+}
+
+// NamespaceManifest returns the multi-document manifest of spec
+func NamespaceManifest(spec NamespaceSpec) ([]byte, error) {
+	nsLabels := MergeLabels(map[string]string{"name": spec.Name}, spec.Labels)
+	if spec.PodSecurity != "" {
+		nsLabels = MergeLabels(nsLabels, map[string]string{
+			"pod-security.kubernetes.io/enforce": spec.PodSecurity,
+			"pod-security.kubernetes.io/audit":   spec.PodSecurity,
+			"pod-security.kubernetes.io/warn":    spec.PodSecurity,
+		})
+	}
+	objects := []interface{}{&apiv1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Labels: nsLabels, Annotations: spec.Annotations},
+	}}
+
+	if len(spec.Quota) > 0 {
+		objects = append(objects, &apiv1.ResourceQuota{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+			ObjectMeta: metav1.ObjectMeta{Name: QuotaName, Namespace: spec.Name},
+			Spec:       apiv1.ResourceQuotaSpec{Hard: spec.Quota},
+		})
+	}
+
+	l := spec.LimitRange
+	if len(l.Default)+len(l.DefaultRequest)+len(l.Max)+len(l.Min) > 0 {
+		l.Type = apiv1.LimitTypeContainer
+		objects = append(objects, &apiv1.LimitRange{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "LimitRange"},
+			ObjectMeta: metav1.ObjectMeta{Name: LimitRangeName, Namespace: spec.Name},
+			Spec:       apiv1.LimitRangeSpec{Limits: []apiv1.LimitRangeItem{l}},
+		})
+	}
+
+	if spec.DefaultDeny {
+		objects = append(objects, &networkingv1.NetworkPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultDenyName, Namespace: spec.Name},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		})
+	}
+
+	return Manifest(objects...)
+}
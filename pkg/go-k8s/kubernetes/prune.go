@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	applicationsResource = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+	projectsResource     = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "appprojects"}
+	secretsResource      = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+// ManagedResources are the resources hotpot creates objects of outside helm
+// releases, in prune order: applications before the projects they belong to
+// and bindings before their roles. Namespaces are labelled but never pruned.
+var ManagedResources = []schema.GroupVersionResource{
+	applicationsResource,
+	projectsResource,
+	{Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"},
+	{Group: "traefik.io", Version: "v1alpha1", Resource: "tlsstores"},
+	{Group: "traefik.io", Version: "v1alpha1", Resource: "tlsoptions"},
+	secretsResource,
+	{Version: "v1", Resource: "resourcequotas"},
+	{Version: "v1", Resource: "limitranges"},
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+	{Version: "v1", Resource: "serviceaccounts"},
+}
+
+// ArgoCDResources are the resources of the ArgoCD applications and projects,
+// applications first
+var ArgoCDResources = []schema.GroupVersionResource{applicationsResource, projectsResource}
+
+// SecretResources are the resources of secrets
+var SecretResources = []schema.GroupVersionResource{secretsResource}
+
+// ObjectRef names an object of the cluster
+type ObjectRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (o ObjectRef) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// Prune deletes the objects of resources, in every namespace, that match the
+// label selector and returns them. Resources the cluster does not serve, e.g.
+// when ArgoCD is not installed, are skipped.
+func Prune(ctx context.Context, kubeconfig, selector string, resources []schema.GroupVersionResource, debug bool) ([]ObjectRef, error) {
+	client, err := GetDynamicClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []ObjectRef
+	var errs []error
+	for _, gvr := range resources {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %w", gvr.Resource, err))
+			continue
+		}
+
+		for _, obj := range list.Items {
+			ref := ObjectRef{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			err := client.Resource(gvr).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %w", ref, err))
+				continue
+			}
+			if debug {
+				fmt.Printf("Pruned %s\n", ref)
+			}
+			pruned = append(pruned, ref)
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
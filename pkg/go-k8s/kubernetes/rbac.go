@@ -0,0 +1,78 @@
+package kubernetes
+
+import (
+	"context"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PresetRoles map the preset role names to the built-in aggregated ClusterRoles
+// they bind, which also cover the custom resources that opt into them
+var PresetRoles = map[string]string{
+	"namespace-admin": "admin",
+	"editor":          "edit",
+	"read-only":       "view",
+}
+
+// RoleSpec is a Role, or a ClusterRole when Namespace is empty
+type RoleSpec struct {
+	Name      string
+	Namespace string
+	Rules     []rbacv1.PolicyRule
+}
+
+// BindingSpec is a RoleBinding, or a ClusterRoleBinding when Namespace is empty
+type BindingSpec struct {
+	Name      string
+	Namespace string
+	RoleRef   rbacv1.RoleRef
+	Subjects  []rbacv1.Subject
+}
+
+// ApplyRBAC creates or updates roles and then bindings, with labels added to every object
+func ApplyRBAC(ctx context.Context, kubeconfig string, roles []RoleSpec, bindings []BindingSpec, labels map[string]string, debug bool) error {
+	manifest, err := RBACManifest(roles, bindings)
+	if err != nil {
+		return err
+	}
+	_, err = Apply(ctx, kubeconfig, manifest, labels, debug)
+	return err
+}
+
+// RBACManifest returns the multi-document manifest of roles and bindings
+func RBACManifest(roles []RoleSpec, bindings []BindingSpec) ([]byte, error) {
+	var objects []interface{}
+	for _, r := range roles {
+		if r.Namespace == "" {
+			objects = append(objects, &rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: r.Name},
+				Rules:      r.Rules,
+			})
+			continue
+		}
+		objects = append(objects, &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: r.Name, Namespace: r.Namespace},
+			Rules:      r.Rules,
+		})
+	}
+	for _, b := range bindings {
+		if b.Namespace == "" {
+			objects = append(objects, &rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: b.Name},
+				RoleRef:    b.RoleRef,
+				Subjects:   b.Subjects,
+			})
+			continue
+		}
+		objects = append(objects, &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: b.Name, Namespace: b.Namespace},
+			RoleRef:    b.RoleRef,
+			Subjects:   b.Subjects,
+		})
+	}
+	return Manifest(objects...)
+}
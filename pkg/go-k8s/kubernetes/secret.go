@@ -6,6 +6,7 @@ import (
 	"fmt"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"strings"
 )
 
@@ -15,6 +16,7 @@ type ContainerRegistrySecret struct {
 	Username string
 	Password string
 	Email    string
+	Labels   map[string]string
 }
 
 func CreateContainerRegistrySecret(
@@ -43,7 +45,8 @@ func CreateContainerRegistrySecret(
 
 	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: secretConfig.Name,
+			Name:   secretConfig.Name,
+			Labels: secretConfig.Labels,
 		},
 		Data: data,
 		Type: v1.SecretTypeDockerConfigJson,
@@ -113,6 +116,21 @@ func GetSecret(kubeconfig, namespace, secretName string) (*v1.Secret, error) {
 	return secret, nil
 }
 
+// GetSecretFromContext retrieves a Kubernetes Secret from the kubeContext cluster
+// of kubeconfig, or its current context when empty. An empty kubeconfig is
+// resolved like kubectl does.
+func GetSecretFromContext(ctx context.Context, kubeconfig, kubeContext, namespace, secretName string) (*v1.Secret, error) {
+	config, err := contextRestConfig(kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return cs.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+}
+
 // GetSecretByName retrieves a Kubernetes Secret by its name.
 func GetSecretByName(kubeconfig, namespace, secretName string) (*v1.Secret, error) {
 	cs, err := GetClientSet(kubeconfig)
@@ -2,81 +2,111 @@ package kubernetes
 
 import (
 	"context"
-	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"time"
 )
 
-// IsDeploymentReady checks if a kubernetes deployment is ready
+// IsDeploymentReady waits for the deployments called deploymentNames to be ready.
+//
+// Deprecated: use Wait, which also covers other workloads and custom resources.
 func IsDeploymentReady(ctx context.Context,
 	kubeconfig, namespace string,
 	deploymentNames []string,
 	debug bool) error {
+	return Wait(ctx, kubeconfig, debug, Deployments(namespace, deploymentNames...)...)
+}
 
+// IsClusterReady checks if a kubernetes cluster is ready
+func IsClusterReady(ctx context.Context, kubeconfig string) (bool, error) {
 	cs, err := GetClientSet(kubeconfig)
 	if err != nil {
-		return err
+		return false, err
 	}
-	for _, deploymentName := range deploymentNames {
-		err := isDeploymentUnitReady(ctx, namespace, deploymentName, cs, debug)
-		if err != nil {
-			return err
-		}
+	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, err
 	}
 
-	return nil
-}
-
-func isDeploymentUnitReady(ctx context.Context,
-	namespace, deploymentName string,
-	clientSet *kubernetes.Clientset,
-	debug bool) error {
-	for {
-		deployment, err := clientSet.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
-		if err != nil {
-			return err
+	for _, node := range nodes.Items {
+		isNodeReady := false
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				isNodeReady = true
+			}
 		}
 
-		if deployment.Status.ReadyReplicas == *deployment.Spec.Replicas {
-			if debug {
-				fmt.Printf("Deployment %s is ready\n", deploymentName)
-			}
-			break
-		} else {
-			if debug {
-				fmt.Printf("Deployment %s is not ready yet, ready replicas: %v\n", deploymentName, deployment.Status.ReadyReplicas)
-			}
-			time.Sleep(1 * time.Second)
+		if !isNodeReady {
+			return false, nil
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
-// IsClusterReady checks if a kubernetes cluster is ready
-func IsClusterReady(ctx context.Context, kubeconfig string) (bool, error) {
+// NodeStatus is the readiness and kubelet version of a node
+type NodeStatus struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Version string `json:"version"`
+}
+
+// GetNodeStatuses returns the status of every node of the cluster
+func GetNodeStatuses(ctx context.Context, kubeconfig string) ([]NodeStatus, error) {
 	cs, err := GetClientSet(kubeconfig)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
+	statuses := make([]NodeStatus, 0, len(nodes.Items))
 	for _, node := range nodes.Items {
-		isNodeReady := false
+		status := NodeStatus{Name: node.Name, Version: node.Status.NodeInfo.KubeletVersion}
 		for _, condition := range node.Status.Conditions {
 			if condition.Type == "Ready" && condition.Status == "True" {
-				isNodeReady = true
+				status.Ready = true
 			}
 		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
 
-		if !isNodeReady {
-			return false, nil
-		}
+// DeploymentStatus is the number of ready replicas of a deployment
+type DeploymentStatus struct {
+	Name     string `json:"name"`
+	Ready    int32  `json:"ready"`
+	Replicas int32  `json:"replicas"`
+}
+
+// IsReady reports whether all the desired replicas are ready
+func (d DeploymentStatus) IsReady() bool {
+	return d.Ready >= d.Replicas
+}
+
+// GetDeploymentStatuses returns the status of every deployment in namespace
+func GetDeploymentStatuses(ctx context.Context, kubeconfig, namespace string) ([]DeploymentStatus, error) {
+	cs, err := GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := cs.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	statuses := make([]DeploymentStatus, 0, len(deployments.Items))
+	for _, d := range deployments.Items {
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		statuses = append(statuses, DeploymentStatus{
+			Name:     d.Name,
+			Ready:    d.Status.ReadyReplicas,
+			Replicas: replicas,
+		})
+	}
+	return statuses, nil
 }
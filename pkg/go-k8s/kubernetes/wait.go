@@ -0,0 +1,402 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"strings"
+	"time"
+)
+
+// Kinds Wait knows how to judge the readiness of
+const (
+	KindDeployment   = "Deployment"
+	KindStatefulSet  = "StatefulSet"
+	KindDaemonSet    = "DaemonSet"
+	KindJob          = "Job"
+	KindCRD          = "CustomResourceDefinition"
+	KindCertificate  = "Certificate"
+	KindIssuer       = "ClusterIssuer"
+	KindArgoCDApp    = "Application"
+	explainLogLines  = 20
+	explainPodsLimit = 3
+	// explainTimeout bounds the pod and log lookups explaining a failed wait
+	explainTimeout = 30 * time.Second
+)
+
+var waitResources = map[string]schema.GroupVersionResource{
+	KindDeployment:  {Group: "apps", Version: "v1", Resource: "deployments"},
+	KindStatefulSet: {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	KindDaemonSet:   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	KindJob:         {Group: "batch", Version: "v1", Resource: "jobs"},
+	KindCRD:         {Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"},
+	KindCertificate: {Group: "cert-manager.io", Version: "v1", Resource: "certificates"},
+	KindIssuer:      {Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"},
+	KindArgoCDApp:   {Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+}
+
+// WaitTarget is an object to wait for. Namespace is ignored for cluster scoped kinds.
+type WaitTarget struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (t WaitTarget) String() string {
+	if t.Namespace == "" || t.Kind == KindCRD || t.Kind == KindIssuer {
+		return fmt.Sprintf("%s %s", t.Kind, t.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", t.Kind, t.Namespace, t.Name)
+}
+
+// Deployments returns the wait targets of the deployments called names in namespace
+func Deployments(namespace string, names ...string) []WaitTarget {
+	return targets(KindDeployment, namespace, names)
+}
+
+// StatefulSets returns the wait targets of the statefulsets called names in namespace
+func StatefulSets(namespace string, names ...string) []WaitTarget {
+	return targets(KindStatefulSet, namespace, names)
+}
+
+// ClusterIssuers returns the wait targets of the cert-manager cluster issuers called names
+func ClusterIssuers(names ...string) []WaitTarget {
+	return targets(KindIssuer, "", names)
+}
+
+// Applications returns the wait targets of the argocd applications called names in namespace
+func Applications(namespace string, names ...string) []WaitTarget {
+	return targets(KindArgoCDApp, namespace, names)
+}
+
+func targets(kind, namespace string, names []string) []WaitTarget {
+	targets := make([]WaitTarget, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, WaitTarget{Kind: kind, Namespace: namespace, Name: name})
+	}
+	return targets
+}
+
+// Wait watches every target until it is ready, fails or ctx is done. When a
+// workload is not ready the error explains why, e.g. the pods crash looping
+// and their last log lines.
+func Wait(ctx context.Context, kubeconfig string, debug bool, targets ...WaitTarget) error {
+	config, err := restConfig(kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := waitFor(ctx, client, cs, target, debug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitFor(ctx context.Context, client dynamic.Interface, cs kubernetes.Interface, target WaitTarget, debug bool) error {
+	gvr, ok := waitResources[target.Kind]
+	if !ok {
+		return fmt.Errorf("waiting for %s is not supported", target.Kind)
+	}
+	var resource dynamic.ResourceInterface = client.Resource(gvr)
+	if target.Kind != KindCRD && target.Kind != KindIssuer {
+		resource = client.Resource(gvr).Namespace(target.Namespace)
+	}
+
+	selector := fields.OneTermEqualSelector("metadata.name", target.Name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return resource.List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return resource.Watch(ctx, opts)
+		},
+	}
+
+	reason := "not found"
+	var last *unstructured.Unstructured
+	_, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, nil, func(e watch.Event) (bool, error) {
+		obj, ok := e.Object.(*unstructured.Unstructured)
+		if !ok {
+			return false, nil
+		}
+		if e.Type == watch.Deleted {
+			last, reason = nil, "deleted"
+			return false, nil
+		}
+		last = obj
+
+		ready, why, err := isReady(target.Kind, obj)
+		if err != nil {
+			return false, err
+		}
+		if debug && why != reason {
+			if ready {
+				fmt.Printf("%s is ready\n", target)
+			} else {
+				fmt.Printf("%s is not ready yet: %s\n", target, why)
+			}
+		}
+		reason = why
+		return ready, nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	// a timeout is explained by the last reason, a failure by itself
+	explanation := reason
+	if ctx.Err() == nil {
+		explanation = err.Error()
+	}
+	if last != nil {
+		if pods := explainPods(ctx, cs, target.Kind, last); pods != "" {
+			explanation += "\n" + pods
+		}
+	}
+	return fmt.Errorf("%s is not ready: %s", target, explanation)
+}
+
+// isReady judges obj by the rules of kind. It returns why obj is not ready,
+// and an error when it never will be, e.g. a failed job.
+func isReady(kind string, obj *unstructured.Unstructured) (bool, string, error) {
+	switch kind {
+	case KindDeployment:
+		var d appsv1.Deployment
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &d); err != nil {
+			return false, "", err
+		}
+		replicas := replicasOrDefault(d.Spec.Replicas)
+		if d.Status.ObservedGeneration < d.Generation {
+			return false, "rollout not observed yet", nil
+		}
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+				return false, "", errors.New(c.Message)
+			}
+		}
+		if d.Status.UpdatedReplicas < replicas || d.Status.AvailableReplicas < replicas {
+			return false, fmt.Sprintf("%d/%d replicas updated, %d available", d.Status.UpdatedReplicas, replicas, d.Status.AvailableReplicas), nil
+		}
+		return true, "ready", nil
+	case KindStatefulSet:
+		var s appsv1.StatefulSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &s); err != nil {
+			return false, "", err
+		}
+		replicas := replicasOrDefault(s.Spec.Replicas)
+		if s.Status.ObservedGeneration < s.Generation {
+			return false, "rollout not observed yet", nil
+		}
+		if s.Status.ReadyReplicas < replicas || s.Status.UpdatedReplicas < replicas {
+			return false, fmt.Sprintf("%d/%d replicas ready, %d updated", s.Status.ReadyReplicas, replicas, s.Status.UpdatedReplicas), nil
+		}
+		return true, "ready", nil
+	case KindDaemonSet:
+		var d appsv1.DaemonSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &d); err != nil {
+			return false, "", err
+		}
+		desired := d.Status.DesiredNumberScheduled
+		if d.Status.ObservedGeneration < d.Generation {
+			return false, "rollout not observed yet", nil
+		}
+		if d.Status.NumberReady < desired || d.Status.UpdatedNumberScheduled < desired {
+			return false, fmt.Sprintf("%d/%d pods ready, %d updated", d.Status.NumberReady, desired, d.Status.UpdatedNumberScheduled), nil
+		}
+		return true, "ready", nil
+	case KindJob:
+		var j batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &j); err != nil {
+			return false, "", err
+		}
+		for _, c := range j.Status.Conditions {
+			if c.Status != v1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				return true, "complete", nil
+			case batchv1.JobFailed:
+				return false, "", fmt.Errorf("job failed: %s", c.Message)
+			}
+		}
+		return false, fmt.Sprintf("%d active, %d succeeded, %d failed", j.Status.Active, j.Status.Succeeded, j.Status.Failed), nil
+	case KindCRD:
+		if c := condition(obj, "NamesAccepted"); c != nil && c["status"] == "False" {
+			return false, "", fmt.Errorf("names not accepted: %v", c["message"])
+		}
+		return conditionReady(obj, "Established")
+	case KindCertificate, KindIssuer:
+		return conditionReady(obj, "Ready")
+	case KindArgoCDApp:
+		sync, _, _ := unstructured.NestedString(obj.Object, "status", "sync", "status")
+		health, _, _ := unstructured.NestedString(obj.Object, "status", "health", "status")
+		if sync == "Synced" && health == "Healthy" {
+			return true, "synced and healthy", nil
+		}
+		if sync == "" {
+			sync = "Unknown"
+		}
+		if health == "" {
+			health = "Unknown"
+		}
+		why := fmt.Sprintf("sync is %s, health is %s", sync, health)
+		if message, _, _ := unstructured.NestedString(obj.Object, "status", "health", "message"); message != "" {
+			why += ": " + message
+		}
+		if message, _, _ := unstructured.NestedString(obj.Object, "status", "operationState", "message"); message != "" {
+			why += ", last operation: " + message
+		}
+		return false, why, nil
+	}
+	return false, "", fmt.Errorf("waiting for %s is not supported", kind)
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// condition returns the status condition of obj with type conditionType
+func condition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if c, ok := c.(map[string]interface{}); ok && c["type"] == conditionType {
+			return c
+		}
+	}
+	return nil
+}
+
+func conditionReady(obj *unstructured.Unstructured, conditionType string) (bool, string, error) {
+	c := condition(obj, conditionType)
+	if c == nil {
+		return false, fmt.Sprintf("no %s condition yet", conditionType), nil
+	}
+	if c["status"] == "True" {
+		return true, conditionType, nil
+	}
+	why := fmt.Sprintf("%s is %v", conditionType, c["status"])
+	if reason, ok := c["reason"].(string); ok && reason != "" {
+		why += " (" + reason + ")"
+	}
+	if message, ok := c["message"].(string); ok && message != "" {
+		why += ": " + message
+	}
+	return false, why, nil
+}
+
+// explainPods describes the failing pods of a workload with their last log lines
+func explainPods(ctx context.Context, cs kubernetes.Interface, kind string, obj *unstructured.Unstructured) string {
+	switch kind {
+	case KindDeployment, KindStatefulSet, KindDaemonSet, KindJob:
+	default:
+		return ""
+	}
+	m, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+	if !found {
+		return ""
+	}
+	var ls metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &ls); err != nil {
+		return ""
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&ls)
+	if err != nil {
+		return ""
+	}
+
+	// ctx may be done already, the explanation gets its own
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), explainTimeout)
+	defer cancel()
+	pods, err := cs.CoreV1().Pods(obj.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Sprintf("failed to list pods: %s", err)
+	}
+
+	var sb strings.Builder
+	explained := 0
+	for _, pod := range pods.Items {
+		if explained == explainPodsLimit {
+			break
+		}
+		if problem := explainPod(ctx, cs, pod); problem != "" {
+			sb.WriteString(problem)
+			explained++
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func explainPod(ctx context.Context, cs kubernetes.Interface, pod v1.Pod) string {
+	var sb strings.Builder
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse {
+			sb.WriteString(fmt.Sprintf("pod %s is unschedulable: %s\n", pod.Name, c.Message))
+		}
+	}
+
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		var problem string
+		switch {
+		case s.State.Waiting != nil && s.State.Waiting.Reason != "ContainerCreating" && s.State.Waiting.Reason != "PodInitializing":
+			problem = fmt.Sprintf("pod %s container %s is %s", pod.Name, s.Name, s.State.Waiting.Reason)
+			if s.State.Waiting.Message != "" {
+				problem += ": " + s.State.Waiting.Message
+			}
+		case s.State.Terminated != nil && s.State.Terminated.ExitCode != 0:
+			problem = fmt.Sprintf("pod %s container %s exited with code %d (%s)", pod.Name, s.Name, s.State.Terminated.ExitCode, s.State.Terminated.Reason)
+		default:
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s, %d restarts\n", problem, s.RestartCount))
+
+		// the logs of the crashed container rather than of the one waiting to restart
+		previous := s.State.Waiting != nil && s.RestartCount > 0
+		if s.State.Waiting != nil && !previous {
+			continue
+		}
+		tail := int64(explainLogLines)
+		logs, err := cs.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: s.Name,
+			Previous:  previous,
+			TailLines: &tail,
+		}).DoRaw(ctx)
+		if err != nil || len(logs) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("last %d log lines of %s/%s:\n", explainLogLines, pod.Name, s.Name))
+		for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,228 @@
+package kubernetes
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+)
+
+func object(status map[string]interface{}, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "app", "namespace": "apps", "generation": int64(2)},
+		"status":   status,
+	}}
+	for k, v := range fields {
+		obj.Object[k] = v
+	}
+	return obj
+}
+
+func conditions(c ...map[string]interface{}) []interface{} {
+	list := make([]interface{}, 0, len(c))
+	for _, condition := range c {
+		list = append(list, condition)
+	}
+	return list
+}
+
+func TestIsReady(t *testing.T) {
+	replicas := map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2)}}
+
+	tests := []struct {
+		name      string
+		kind      string
+		obj       *unstructured.Unstructured
+		wantReady bool
+		wantWhy   string
+		wantErr   string
+	}{
+		{
+			name:      "deployment available",
+			kind:      KindDeployment,
+			obj:       object(map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)}, replicas),
+			wantReady: true,
+		},
+		{
+			name:    "deployment rolling out",
+			kind:    KindDeployment,
+			obj:     object(map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(1)}, replicas),
+			wantWhy: "2/2 replicas updated, 1 available",
+		},
+		{
+			name:    "deployment not observed",
+			kind:    KindDeployment,
+			obj:     object(map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(2), "availableReplicas": int64(2)}, replicas),
+			wantWhy: "rollout not observed yet",
+		},
+		{
+			name: "deployment past its deadline",
+			kind: KindDeployment,
+			obj: object(map[string]interface{}{
+				"observedGeneration": int64(2),
+				"conditions":         conditions(map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded", "message": "timed out"}),
+			}, replicas),
+			wantErr: "timed out",
+		},
+		{
+			name:      "statefulset ready",
+			kind:      KindStatefulSet,
+			obj:       object(map[string]interface{}{"observedGeneration": int64(2), "readyReplicas": int64(2), "updatedReplicas": int64(2)}, replicas),
+			wantReady: true,
+		},
+		{
+			name:    "statefulset starting",
+			kind:    KindStatefulSet,
+			obj:     object(map[string]interface{}{"observedGeneration": int64(2), "readyReplicas": int64(1), "updatedReplicas": int64(2)}, replicas),
+			wantWhy: "1/2 replicas ready, 2 updated",
+		},
+		{
+			name:      "daemonset ready",
+			kind:      KindDaemonSet,
+			obj:       object(map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "numberReady": int64(3), "updatedNumberScheduled": int64(3)}, nil),
+			wantReady: true,
+		},
+		{
+			name:    "daemonset updating",
+			kind:    KindDaemonSet,
+			obj:     object(map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "numberReady": int64(3), "updatedNumberScheduled": int64(1)}, nil),
+			wantWhy: "3/3 pods ready, 1 updated",
+		},
+		{
+			name:      "job complete",
+			kind:      KindJob,
+			obj:       object(map[string]interface{}{"succeeded": int64(1), "conditions": conditions(map[string]interface{}{"type": "Complete", "status": "True"})}, nil),
+			wantReady: true,
+		},
+		{
+			name:    "job running",
+			kind:    KindJob,
+			obj:     object(map[string]interface{}{"active": int64(1)}, nil),
+			wantWhy: "1 active, 0 succeeded, 0 failed",
+		},
+		{
+			name:    "job failed",
+			kind:    KindJob,
+			obj:     object(map[string]interface{}{"failed": int64(6), "conditions": conditions(map[string]interface{}{"type": "Failed", "status": "True", "message": "backoff limit exceeded"})}, nil),
+			wantErr: "backoff limit exceeded",
+		},
+		{
+			name:      "crd established",
+			kind:      KindCRD,
+			obj:       object(map[string]interface{}{"conditions": conditions(map[string]interface{}{"type": "Established", "status": "True"})}, nil),
+			wantReady: true,
+		},
+		{
+			name:    "crd names rejected",
+			kind:    KindCRD,
+			obj:     object(map[string]interface{}{"conditions": conditions(map[string]interface{}{"type": "NamesAccepted", "status": "False", "message": "plural is taken"})}, nil),
+			wantErr: "plural is taken",
+		},
+		{
+			name:      "cluster issuer ready",
+			kind:      KindIssuer,
+			obj:       object(map[string]interface{}{"conditions": conditions(map[string]interface{}{"type": "Ready", "status": "True"})}, nil),
+			wantReady: true,
+		},
+		{
+			name: "cluster issuer not registered",
+			kind: KindIssuer,
+			obj: object(map[string]interface{}{
+				"conditions": conditions(map[string]interface{}{"type": "Ready", "status": "False", "reason": "ErrRegisterACMEAccount", "message": "connection refused"}),
+			}, nil),
+			wantWhy: "Ready is False (ErrRegisterACMEAccount): connection refused",
+		},
+		{
+			name:    "certificate without conditions",
+			kind:    KindCertificate,
+			obj:     object(map[string]interface{}{}, nil),
+			wantWhy: "no Ready condition yet",
+		},
+		{
+			name: "application synced and healthy",
+			kind: KindArgoCDApp,
+			obj: object(map[string]interface{}{
+				"sync":   map[string]interface{}{"status": "Synced"},
+				"health": map[string]interface{}{"status": "Healthy"},
+			}, nil),
+			wantReady: true,
+		},
+		{
+			name: "application healthy but out of sync",
+			kind: KindArgoCDApp,
+			obj: object(map[string]interface{}{
+				"sync":           map[string]interface{}{"status": "OutOfSync"},
+				"health":         map[string]interface{}{"status": "Healthy"},
+				"operationState": map[string]interface{}{"message": "one or more objects failed to apply"},
+			}, nil),
+			wantWhy: "sync is OutOfSync, health is Healthy, last operation: one or more objects failed to apply",
+		},
+		{
+			name:    "application without status",
+			kind:    KindArgoCDApp,
+			obj:     object(map[string]interface{}{}, nil),
+			wantWhy: "sync is Unknown, health is Unknown",
+		},
+		{
+			name:    "unsupported kind",
+			kind:    "ConfigMap",
+			obj:     object(map[string]interface{}{}, nil),
+			wantErr: "not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, why, err := isReady(tt.kind, tt.obj)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("isReady() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("isReady() error = %v", err)
+			}
+			if ready != tt.wantReady {
+				t.Errorf("isReady() = %v (%s), want %v", ready, why, tt.wantReady)
+			}
+			if tt.wantWhy != "" && why != tt.wantWhy {
+				t.Errorf("isReady() why = %q, want %q", why, tt.wantWhy)
+			}
+		})
+	}
+}
+
+func TestExplainPods(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-7d9f", Namespace: "apps", Labels: map[string]string{"app": "app"}},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:         "app",
+				RestartCount: 4,
+				State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			}},
+		},
+	}
+	cs := fake.NewClientset(pod)
+	obj := object(map[string]interface{}{}, map[string]interface{}{
+		"spec": map[string]interface{}{"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "app"}}},
+	})
+
+	// the wait timed out, the explanation still gets its own time
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got := explainPods(ctx, cs, KindDeployment, obj)
+	if !strings.Contains(got, "pod app-7d9f container app is CrashLoopBackOff, 4 restarts") {
+		t.Errorf("explainPods() = %q, want the crash looping container", got)
+	}
+	if !strings.Contains(got, "last 20 log lines of app-7d9f/app") {
+		t.Errorf("explainPods() = %q, want the logs of the crashed container", got)
+	}
+	if got := explainPods(ctx, cs, KindIssuer, obj); got != "" {
+		t.Errorf("explainPods() = %q, want nothing for kinds without pods", got)
+	}
+}
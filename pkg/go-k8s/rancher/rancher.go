@@ -19,9 +19,19 @@ const (
 	defaultValuesFile = "values.yaml"
 )
 
+// ReleaseName and Namespace locate the rancher helm release
+const (
+	ReleaseName = defaultChartName
+	Namespace   = defaultNamespace
+)
+
 type Values struct {
 	Version  string
 	Hostname string
+	// Chart overrides the rancher chart of the public repo
+	Chart helm.Source
+	// Overrides are merged over the values hotpot generates for the rancher chart
+	Overrides helm.Overrides
 }
 
 func Install(values *Values, kubeconfig string, debug bool) error {
@@ -30,43 +40,12 @@ func Install(values *Values, kubeconfig string, debug bool) error {
 		return err
 	}
 
-	// create values file
-	valuesFileData, err := yaml.ApplyTmpl(
-		valuesTmpl,
-		values,
-		debug,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to parse values template file: %w", err)
-	}
-
-	valuesFilePath := fmt.Sprintf("%s/%s", os.TempDir(), defaultValuesFile)
-	// write values file
-	err = os.WriteFile(valuesFilePath, valuesFileData, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to write values file: %w", err)
-	}
-
-	helmClient := helm.NewClient()
-	helmClient.Settings.KubeConfig = kubeconfig
-	helmClient.Settings.SetNamespace(defaultNamespace)
-	helmClient.Settings.Debug = debug
-
-	err = helmClient.RepoAddAndUpdate(helmRepoName, helmRepoURL)
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
 	if err != nil {
-		return fmt.Errorf("failed to add helm repo: %w", err)
+		return err
 	}
 
-	err = helmClient.InstallChart(helm.Chart{
-		ChartName:       defaultChartName,
-		ReleaseName:     defaultChartName,
-		RepoName:        helmRepoName,
-		Values:          nil,
-		ValuesFiles:     []string{valuesFilePath},
-		Debug:           debug,
-		CreateNamespace: true,
-		Upgrade:         true,
-	})
+	err = helmClient.InstallChart(chart)
 	if err != nil {
 		return fmt.Errorf("failed to install helm chart: %w", err)
 	}
@@ -75,15 +54,8 @@ func Install(values *Values, kubeconfig string, debug bool) error {
 	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	err = kubernetes.IsDeploymentReady(
-		ctxWithTimeout,
-		kubeconfig,
-		defaultNamespace,
-		[]string{
-			"rancher",
-		},
-		debug,
-	)
+	err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug,
+		kubernetes.Deployments(defaultNamespace, "rancher")...)
 	if err != nil {
 		return fmt.Errorf("failed to wait for rancher server to be ready \n %w", err)
 	}
@@ -113,6 +85,66 @@ tls: external
 `
 
 // Uninstall uninstalls rancher
+// prepareChart writes the rancher values file and returns the chart to install
+func prepareChart(values *Values, kubeconfig string, debug bool) (*helm.Client, helm.Chart, error) {
+	// create values file
+	valuesFileData, err := yaml.ApplyTmpl(
+		valuesTmpl,
+		values,
+		debug,
+	)
+	if err != nil {
+		return nil, helm.Chart{}, fmt.Errorf("failed to parse values template file: %w", err)
+	}
+
+	valuesFilePath := fmt.Sprintf("%s/%s", os.TempDir(), defaultValuesFile)
+	// write values file
+	err = os.WriteFile(valuesFilePath, valuesFileData, 0600)
+	if err != nil {
+		return nil, helm.Chart{}, fmt.Errorf("failed to write values file: %w", err)
+	}
+
+	helmClient := helm.NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.SetNamespace(defaultNamespace)
+	helmClient.Settings.Debug = debug
+
+	chart := values.Overrides.Apply(values.Chart.Apply(helm.Chart{
+		ChartName:       defaultChartName,
+		ReleaseName:     defaultChartName,
+		RepoName:        helmRepoName,
+		Values:          nil,
+		ValuesFiles:     []string{valuesFilePath},
+		Debug:           debug,
+		CreateNamespace: true,
+		Upgrade:         true,
+		Atomic:          true,
+	}))
+
+	if chart.Ref == "" {
+		repo := values.Chart.RepositoryOr(helmRepoName, helmRepoURL)
+		err = helmClient.RepoAddAndUpdate(repo.Name, repo.Url, repo.Options)
+		if err != nil {
+			return nil, helm.Chart{}, fmt.Errorf("failed to add helm repo: %w", err)
+		}
+	}
+
+	return helmClient, chart, nil
+}
+
+// Plan returns the diff of the rancher release against the cluster without changing it
+func Plan(values *Values, kubeconfig string, debug bool) (string, error) {
+	if err := validateValues(values); err != nil {
+		return "", err
+	}
+
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
+	if err != nil {
+		return "", err
+	}
+	return helmClient.DiffChart(chart)
+}
+
 func Uninstall(kubeconfig string, debug bool) error {
 	helmClient := helm.NewClient()
 	helmClient.Settings.KubeConfig = kubeconfig
@@ -0,0 +1,261 @@
+// Package rke2 installs RKE2, Rancher's hardened Kubernetes distribution
+package rke2
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+	"github.com/zcubbs/hotpot/pkg/x/bash"
+	"github.com/zcubbs/hotpot/pkg/x/download"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const (
+	ConfigFileLocation = "/etc/rancher/rke2"
+	KubeconfigPath     = ConfigFileLocation + "/rke2.yaml"
+	RegistriesFile     = ConfigFileLocation + "/registries.yaml"
+	InstallScript      = "/tmp/rke2-install.sh"
+	ServiceName        = "rke2-server"
+
+	// DefaultVersion is the rke2 release installed when none is pinned
+	DefaultVersion = "v1.31.4+rke2r1"
+
+	// ProfileCIS enables the CIS hardening profile
+	ProfileCIS = "cis"
+
+	// IngressNginx is the packaged ingress controller, disabled when traefik is installed
+	IngressNginx = "rke2-ingress-nginx"
+
+	scriptUrl = "https://raw.githubusercontent.com/rancher/rke2"
+)
+
+// uninstallScripts are the tarball and rpm install locations
+var uninstallScripts = []string{"/usr/local/bin/rke2-uninstall.sh", "/usr/bin/rke2-uninstall.sh"}
+
+// cisSysctlFiles are the tarball and rpm locations of the CIS kernel parameters
+var cisSysctlFiles = []string{"/usr/local/share/rke2/rke2-cis-sysctl.conf", "/usr/share/rke2/rke2-cis-sysctl.conf"}
+
+type Config struct {
+	Version             string
+	Disable             []string
+	TlsSan              []string
+	DataDir             string
+	WriteKubeconfigMode string
+	ResolvConfPath      string
+	Profile             string
+	Registries          k3s.Registries
+	// InstallScriptSha256 pins the install script of a version missing from InstallScriptSums
+	InstallScriptSha256 string
+}
+
+// InstallScriptSums pins the sha256 of the install script of each rke2 release.
+// The script runs as root and is not part of the release checksum manifests,
+// so versions without a sum here need Config.InstallScriptSha256.
+var InstallScriptSums = map[string]string{}
+
+// InstallScriptSum returns the pinned sha256 of the install script of version,
+// sum when set
+func InstallScriptSum(version, sum string) (string, error) {
+	if sum != "" {
+		return sum, nil
+	}
+	if sum, ok := InstallScriptSums[version]; ok {
+		return sum, nil
+	}
+	return "", fmt.Errorf("no sha256 pinned for the rke2 %s install script, set rke2.installScriptSha256 to the sha256sum of %s", version, scriptURL(version))
+}
+
+func scriptURL(version string) string {
+	return fmt.Sprintf("%s/%s/install.sh", scriptUrl, strings.ReplaceAll(version, "+", "%2B"))
+}
+
+var configTmpl = `---
+{{- if .Disable }}
+disable:
+{{- range $val := $.Disable }}
+  - {{ $val }}
+{{- end }}
+{{- end }}
+{{- if .TlsSan }}
+tls-san:
+{{- range $val := $.TlsSan }}
+  - {{ $val }}
+{{- end }}
+{{- end }}
+{{- if .DataDir }}
+data-dir: {{ .DataDir }}
+{{- end }}
+{{- if .WriteKubeconfigMode }}
+write-kubeconfig-mode: {{ .WriteKubeconfigMode }}
+{{- end }}
+{{- if .Profile }}
+profile: {{ .Profile }}
+{{- end }}
+{{- if .ResolvConfPath }}
+kubelet-arg:
+  - "resolv-conf={{ .ResolvConfPath }}"
+{{- end }}
+`
+
+// Install writes the rke2 config, installs the pinned release and starts the server.
+// The install script is verified against its pinned sum, and verifies the
+// release tarball against its published checksums.
+func Install(config Config, debug bool) error {
+	if config.Version == "" || config.Version == "latest" {
+		config.Version = DefaultVersion
+	}
+	if debug {
+		fmt.Printf("%+v\n", config)
+	}
+
+	// the install script runs as root, refuse to go on without its sum
+	scriptSum, err := InstallScriptSum(config.Version, config.InstallScriptSha256)
+	if err != nil {
+		return err
+	}
+
+	err = osx.CreateDirIfNotExist(ConfigFileLocation)
+	if err != nil {
+		return err
+	}
+	b, err := RenderConfig(config)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(ConfigFileLocation+"/config.yaml", b, 0600)
+	if err != nil {
+		return err
+	}
+
+	// registries.yaml must be in place before rke2 starts
+	err = ConfigureRegistries(config.Registries, debug)
+	if err != nil {
+		return fmt.Errorf("failed to configure registries \n %w", err)
+	}
+
+	script, err := download.Fetch(download.Artifact{
+		Name:    "rke2",
+		Version: config.Version,
+		URL:     scriptURL(config.Version),
+		Sha256:  scriptSum,
+	}, debug)
+	if err != nil {
+		return fmt.Errorf("failed to download rke2 install script \n %w", err)
+	}
+	err = download.Install(script, InstallScript, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.Setenv("INSTALL_RKE2_VERSION", config.Version)
+	if err != nil {
+		return fmt.Errorf("error while setting env var %s \n%v", "INSTALL_RKE2_VERSION", err)
+	}
+
+	ok, err := bash.ExecuteScript(InstallScript, debug, InstallScript)
+	if !ok && err != nil {
+		return fmt.Errorf("error while running %s \n%v", InstallScript, err)
+	}
+
+	if config.Profile != "" {
+		if err := prepareProfile(debug); err != nil {
+			return err
+		}
+	}
+
+	err = bash.ExecuteCmd("systemctl", debug, "enable", "--now", ServiceName)
+	if err != nil {
+		return fmt.Errorf("failed to start %s \n %w", ServiceName, err)
+	}
+
+	return nil
+}
+
+// ConfigureRegistries writes the rke2 registries.yaml, or removes it when no
+// registry is configured, and restarts rke2 when the file changed
+func ConfigureRegistries(registries k3s.Registries, debug bool) error {
+	var changed bool
+	var err error
+	if len(registries.Mirrors) == 0 && len(registries.Configs) == 0 {
+		changed, err = k3s.RemoveRegistriesFile(RegistriesFile, debug)
+	} else {
+		changed, err = k3s.WriteRegistriesFile(RegistriesFile, registries, debug)
+	}
+	if err != nil {
+		return err
+	}
+
+	if changed && IsInstalled() {
+		return osx.RestartSystemdService(ServiceName, debug)
+	}
+	return nil
+}
+
+// prepareProfile sets up the host requirements of the CIS profile:
+// an etcd system user and the hardened kernel parameters
+func prepareProfile(debug bool) error {
+	if _, err := bash.ExecuteCmdWithOutput("id", "etcd"); err != nil {
+		err := bash.ExecuteCmd("useradd", debug, "-r", "-c", "etcd user", "-s", "/sbin/nologin", "-M", "etcd", "-U")
+		if err != nil {
+			return fmt.Errorf("failed to create etcd user \n %w", err)
+		}
+	}
+
+	for _, f := range cisSysctlFiles {
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		if err := osx.CopyFileToDestination(f, "/etc/sysctl.d/60-rke2-cis.conf"); err != nil {
+			return fmt.Errorf("failed to install %s \n %w", f, err)
+		}
+		return osx.RestartSystemdService("systemd-sysctl", debug)
+	}
+	return fmt.Errorf("rke2 cis sysctl file not found in %v", cisSysctlFiles)
+}
+
+// RenderConfig returns the content of /etc/rancher/rke2/config.yaml for config
+func RenderConfig(config Config) ([]byte, error) {
+	tmpl, err := template.New("config").Parse(configTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Uninstall runs the uninstall script left by the install script
+func Uninstall(debug bool) error {
+	for _, script := range uninstallScripts {
+		if _, err := os.Stat(script); err != nil {
+			continue
+		}
+		_, err := bash.ExecuteScript(script, debug, script)
+		return err
+	}
+	return fmt.Errorf("rke2 uninstall script not found: no such file or directory")
+}
+
+// IsInstalled reports whether rke2 was installed by the install script
+func IsInstalled() bool {
+	for _, script := range uninstallScripts {
+		if _, err := os.Stat(script); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InstalledVersion returns the version of the installed rke2 binary
+func InstalledVersion() (string, error) {
+	out, err := bash.ExecuteCmdWithOutput("rke2", "--version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get rke2 version \n %w", err)
+	}
+	return k3s.ParseVersion(out), nil
+}
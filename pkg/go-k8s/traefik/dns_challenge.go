@@ -107,6 +107,7 @@ func configureAzure(values Values, kubeconfig string, debug bool) error {
 			"AZURE_TENANT_ID":       []byte(azureTenantID),
 			"TZ":                    []byte(values.DnsTZ),
 		},
+		values.Labels,
 		kubeconfig,
 		debug,
 	)
@@ -154,19 +155,21 @@ func configureOVH(values Values, kubeconfig string, debug bool) error {
 			"OVH_CONSUMER_KEY":       []byte(ovhConsumerKey),
 			"TZ":                     []byte(values.DnsTZ),
 		},
+		values.Labels,
 		kubeconfig,
 		debug,
 	)
 }
 
-func createSecret(data map[string][]byte, kubeconfig string, debug bool) error {
+func createSecret(data map[string][]byte, labels map[string]string, kubeconfig string, debug bool) error {
 	// create secret
 	err := kubernetes.CreateGenericSecret(
 		context.Background(),
 		kubeconfig,
 		v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: traefikProviderCredentialsSecretName,
+				Name:   traefikProviderCredentialsSecretName,
+				Labels: labels,
 			},
 			Data: data,
 		},
@@ -27,6 +27,12 @@ const (
 	traefikDnsTZ = "Europe/Paris"
 )
 
+// ReleaseName and Namespace locate the traefik helm release
+const (
+	ReleaseName = traefikChartName
+	Namespace   = traefikNamespace
+)
+
 func Install(values Values, kubeconfig string, debug bool) error {
 	if err := validateValues(&values); err != nil {
 		return err
@@ -38,34 +44,64 @@ func Install(values Values, kubeconfig string, debug bool) error {
 		}
 	}
 
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
+	if err != nil {
+		return err
+	}
+
+	// install traefik
+	err = helmClient.InstallChart(chart)
+	if err != nil {
+		return fmt.Errorf("failed to install traefik \n %w", err)
+	}
+
+	// wait for traefik deployment to be ready
+	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	err = kubernetes.Wait(ctxWithTimeout, kubeconfig, debug,
+		kubernetes.Deployments(traefikNamespace, "traefik")...)
+	if err != nil {
+		return fmt.Errorf("failed to wait for traefik deployment to be ready \n %w", err)
+	}
+
+	// prepare default certificate secret
+	if values.DefaultCertificateEnabled {
+		err := createDefaultCertificateSecret(&values, kubeconfig, debug)
+		if err != nil {
+			return fmt.Errorf("failed to create default certificate secret \n %w", err)
+		}
+
+		// restart traefik
+		err = kubernetes.RestartPods(kubeconfig, traefikNamespace, []string{"traefik"}, debug)
+		if err != nil {
+			return fmt.Errorf("failed to restart traefik \n %w", err)
+		}
+	}
+	return nil
+}
+
+// prepareChart writes the traefik values file and returns the chart to install
+func prepareChart(values Values, kubeconfig string, debug bool) (*helm.Client, helm.Chart, error) {
 	valuesPath := getTmpFilePath("values")
 
 	// create traefik values.yaml from template
 	configFileContent, err := yaml.ApplyTmpl(traefikValuesTmpl, values, debug)
 	if err != nil {
-		return fmt.Errorf("failed to apply template \n %w", err)
+		return nil, helm.Chart{}, fmt.Errorf("failed to apply template \n %w", err)
 	}
 
 	// write tmp manifest
 	err = os.WriteFile(valuesPath, configFileContent, 0600)
 	if err != nil {
-		return fmt.Errorf("failed to write traefik values.yaml \n %w", err)
+		return nil, helm.Chart{}, fmt.Errorf("failed to write traefik values.yaml \n %w", err)
 	}
 
-	// helm install traefik
 	helmClient := helm.NewClient()
 	helmClient.Settings.KubeConfig = kubeconfig
 	helmClient.Settings.SetNamespace(traefikNamespace)
 	helmClient.Settings.Debug = debug
 
-	// add traefik helm repo
-	err = helmClient.RepoAddAndUpdate(traefikHelmRepoName, traefikHelmRepoUrl)
-	if err != nil {
-		return fmt.Errorf("failed to add helm repo: %w", err)
-	}
-
-	// install traefik
-	err = helmClient.InstallChart(helm.Chart{
+	chart := values.Overrides.Apply(values.Chart.Apply(helm.Chart{
 		ChartName:       traefikChartName,
 		ReleaseName:     traefikChartName,
 		RepoName:        traefikHelmRepoName,
@@ -74,39 +110,32 @@ func Install(values Values, kubeconfig string, debug bool) error {
 		Debug:           debug,
 		CreateNamespace: true,
 		Upgrade:         true,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to install traefik \n %w", err)
-	}
-
-	// wait for traefik deployment to be ready
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
-	err = kubernetes.IsDeploymentReady(
-		ctxWithTimeout,
-		kubeconfig,
-		traefikNamespace,
-		[]string{"traefik"},
-		debug,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to wait for traefik deployment to be ready \n %w", err)
-	}
+		Atomic:          true,
+	}))
 
-	// prepare default certificate secret
-	if values.DefaultCertificateEnabled {
-		err := createDefaultCertificateSecret(&values, kubeconfig, debug)
+	// add traefik helm repo unless the chart comes from elsewhere
+	if chart.Ref == "" {
+		repo := values.Chart.RepositoryOr(traefikHelmRepoName, traefikHelmRepoUrl)
+		err = helmClient.RepoAddAndUpdate(repo.Name, repo.Url, repo.Options)
 		if err != nil {
-			return fmt.Errorf("failed to create default certificate secret \n %w", err)
+			return nil, helm.Chart{}, fmt.Errorf("failed to add helm repo: %w", err)
 		}
+	}
 
-		// restart traefik
-		err = kubernetes.RestartPods(kubeconfig, traefikNamespace, []string{"traefik"}, debug)
-		if err != nil {
-			return fmt.Errorf("failed to restart traefik \n %w", err)
-		}
+	return helmClient, chart, nil
+}
+
+// Plan returns the diff of the traefik release against the cluster without changing it
+func Plan(values Values, kubeconfig string, debug bool) (string, error) {
+	if err := validateValues(&values); err != nil {
+		return "", err
 	}
-	return nil
+
+	helmClient, chart, err := prepareChart(values, kubeconfig, debug)
+	if err != nil {
+		return "", err
+	}
+	return helmClient.DiffChart(chart)
 }
 
 func Uninstall(kubeconfig string, debug bool) error {
@@ -150,15 +179,17 @@ func createDefaultCertificateSecret(values *Values, kubeconfig string, debug boo
 	return nil
 }
 
-func applyDefaultCertificateSecret(values Values, _ string, debug bool) error {
+func applyDefaultCertificateSecret(values Values, kubeconfig string, debug bool) error {
 	// apply default TLS store
-	err := kubernetes.ApplyManifest(
+	err := kubernetes.ApplyManifestWithKc(
 		defaultTlsStoreTmpl,
 		struct {
 			Namespace string
 		}{
 			Namespace: traefikNamespace,
 		},
+		kubeconfig,
+		values.Labels,
 		debug,
 	)
 	if err != nil {
@@ -167,7 +198,7 @@ func applyDefaultCertificateSecret(values Values, _ string, debug bool) error {
 
 	// apply default TLS option
 	if values.DefaultCertificateTlsOptionEnabled {
-		err = kubernetes.ApplyManifest(
+		err = kubernetes.ApplyManifestWithKc(
 			defaultTlsOptionTmpl,
 			struct {
 				Namespace    string
@@ -176,6 +207,8 @@ func applyDefaultCertificateSecret(values Values, _ string, debug bool) error {
 				Namespace:    traefikNamespace,
 				TlsStrictSNI: values.TlsStrictSNI,
 			},
+			kubeconfig,
+			values.Labels,
 			debug,
 		)
 		if err != nil {
@@ -198,7 +231,7 @@ func applyDefaultCertificateSecret(values Values, _ string, debug bool) error {
 
 	// Add Default Certificate Secret
 	if values.DefaultCertificateEnabled {
-		err = kubernetes.ApplyManifest(
+		err = kubernetes.ApplyManifestWithKc(
 			defaultCertificateSecretTmpl,
 			DefaultCertificateValues{
 				Enabled: values.DefaultCertificateEnabled,
@@ -210,7 +243,7 @@ func applyDefaultCertificateSecret(values Values, _ string, debug bool) error {
 					Key: key,
 				},
 				Namespace: traefikNamespace,
-			}, debug)
+			}, kubeconfig, values.Labels, debug)
 		if err != nil {
 			return fmt.Errorf("failed to apply default certificate secret \n %w", err)
 		}
@@ -315,6 +348,13 @@ type Values struct {
 	DefaultCertificateTlsOptionEnabled bool
 	DefaultCertificateCert             string
 	DefaultCertificateKey              string
+
+	// Chart overrides the traefik chart of the public repo
+	Chart helm.Source
+	// Overrides are merged over the values hotpot generates for the traefik chart
+	Overrides helm.Overrides
+	// Labels are added to the TLS store, TLS option and secrets hotpot creates
+	Labels map[string]string
 }
 
 var traefikValuesTmpl = `
@@ -0,0 +1,298 @@
+package recipe
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"github.com/zcubbs/hotpot/pkg/secret"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	bundleLogLines     = 1000
+	bundleJournalLines = "5000"
+	bundleEventsSince  = 24 * time.Hour
+	redacted           = "REDACTED"
+)
+
+// secretKey matches the recipe keys whose values are redacted from support bundles,
+// value being the data of generic secrets and auth the k3s registry credentials
+var secretKey = regexp.MustCompile(`(?i)(password|secret|token|key|credential|^auth$|^value$)`)
+
+// bundle writes files into a gzipped tarball under a common root directory
+type bundle struct {
+	root   string
+	file   *os.File
+	gz     *gzip.Writer
+	tar    *tar.Writer
+	errors []string
+}
+
+func newBundle(path string) (*bundle, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create support bundle %s \n %w", path, err)
+	}
+	gz := gzip.NewWriter(f)
+	return &bundle{
+		root: strings.TrimSuffix(filepath.Base(path), ".tar.gz"),
+		file: f,
+		gz:   gz,
+		tar:  tar.NewWriter(gz),
+	}, nil
+}
+
+// add writes data to name in the bundle
+func (b *bundle) add(name string, data []byte) error {
+	err := b.tar.WriteHeader(&tar.Header{
+		Name:    filepath.Join(b.root, name),
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = b.tar.Write(data)
+	return err
+}
+
+// collect adds the output of f to name, or records why it could not be collected
+func (b *bundle) collect(name string, f func() ([]byte, error)) {
+	data, err := f()
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %s", name, err))
+		if len(data) == 0 {
+			return
+		}
+	}
+	if err := b.add(name, data); err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %s", name, err))
+	}
+}
+
+func (b *bundle) close() error {
+	if len(b.errors) > 0 {
+		if err := b.add("errors.txt", []byte(strings.Join(b.errors, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	if err := b.tar.Close(); err != nil {
+		return err
+	}
+	if err := b.gz.Close(); err != nil {
+		return err
+	}
+	return b.file.Close()
+}
+
+// SupportBundle gathers the redacted recipe, distribution config and logs,
+// cluster state, helm release history, component logs, prerequisite results
+// and host facts into a tarball at output
+func SupportBundle(recipePath, output string, deps Dependencies) error {
+	r, err := Load(recipePath)
+	if err != nil {
+		return err
+	}
+	if err := selectDistribution(r, &deps); err != nil {
+		return err
+	}
+
+	b, err := newBundle(output)
+	if err != nil {
+		return err
+	}
+
+	// recipe and node
+	b.collect("recipe.yaml", func() ([]byte, error) { return redactFile(r.Path) })
+	b.collect("host/facts.txt", hostFacts)
+	b.collect("host/prerequisites.txt", func() ([]byte, error) {
+		return []byte(prerequisiteResults(r, deps.SystemInfo)), nil
+	})
+
+	// distribution
+	dist := deps.Distribution
+	b.collect(dist.Name()+"/version.txt", func() ([]byte, error) {
+		v, err := dist.InstalledVersion()
+		return []byte(v), err
+	})
+	for _, f := range []string{"config.yaml", "registries.yaml"} {
+		path := filepath.Join(dist.ConfigDir(), f)
+		if _, err := os.Stat(path); err == nil {
+			b.collect(dist.Name()+"/"+f, func() ([]byte, error) { return redactFile(path) })
+		}
+	}
+	b.collect(dist.Name()+"/journal.log", func() ([]byte, error) {
+		return exec.Command("journalctl", "-u", dist.ServiceName(), "--no-pager", "-n", bundleJournalLines).Output()
+	})
+
+	// cluster
+	ctx := context.Background()
+	b.collect("cluster/nodes.yaml", func() ([]byte, error) {
+		nodes, err := kubernetes.GetNodes(ctx, r.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(nodes)
+	})
+	b.collect("cluster/pods.txt", func() ([]byte, error) {
+		pods, err := kubernetes.DescribePods(ctx, r.Kubeconfig)
+		return []byte(pods), err
+	})
+	b.collect("cluster/events.txt", func() ([]byte, error) {
+		events, err := kubernetes.DescribeEvents(ctx, r.Kubeconfig, bundleEventsSince)
+		return []byte(events), err
+	})
+
+	// components
+	for _, c := range components(r) {
+		b.collect(fmt.Sprintf("helm/%s-history.txt", c.release), func() ([]byte, error) {
+			return releaseHistory(c.release, c.namespace, r.Kubeconfig)
+		})
+		logs, err := kubernetes.GetPodLogs(ctx, r.Kubeconfig, c.namespace, bundleLogLines)
+		if err != nil {
+			b.errors = append(b.errors, fmt.Sprintf("logs/%s: %s", c.namespace, err))
+			continue
+		}
+		for name, log := range logs {
+			if err := b.add(fmt.Sprintf("logs/%s/%s.log", c.namespace, strings.ReplaceAll(name, "/", "_")), log); err != nil {
+				b.errors = append(b.errors, fmt.Sprintf("logs/%s/%s: %s", c.namespace, name, err))
+			}
+		}
+	}
+
+	return b.close()
+}
+
+// redactFile returns the yaml file at path with the values of secret-looking keys redacted
+func redactFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(redact(doc))
+}
+
+// redact replaces the literal values of secret-looking keys. Secret provider
+// references, e.g. env.REGISTRY_PASSWORD, and file paths are kept.
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			s, ok := val.(string)
+			if ok && isSecretKey(k) && s != "" && !secret.IsReference(s) {
+				v[k] = redacted
+				continue
+			}
+			// the data map of generic secrets has arbitrary keys
+			if data, ok := val.(map[string]interface{}); ok && k == "data" {
+				for dk, dv := range data {
+					if s, ok := dv.(string); ok && s != "" && !secret.IsReference(s) {
+						data[dk] = redacted
+					}
+				}
+				continue
+			}
+			v[k] = redact(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return v
+}
+
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	// e.g. the key of a toleration or a node selector
+	if lower == "key" {
+		return false
+	}
+	if strings.HasSuffix(lower, "file") || strings.HasSuffix(lower, "path") {
+		return false
+	}
+	return secretKey.MatchString(key)
+}
+
+// prerequisiteResults runs every prerequisite check and reports each result
+func prerequisiteResults(r *Recipe, sysInfo SystemInfo) string {
+	var sb strings.Builder
+	for _, c := range prerequisites(r, sysInfo) {
+		if err := c.run(); err != nil {
+			sb.WriteString(fmt.Sprintf("%s: failed: %s\n", c.name, err))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: ok\n", c.name))
+	}
+	return sb.String()
+}
+
+func hostFacts() ([]byte, error) {
+	facts := map[string]func() (string, error){
+		"hostname": os.Hostname,
+		"os":       osx.GetOS,
+		"distro":   osx.GetDistro,
+		"arch":     osx.GetArch,
+		"cpu": func() (string, error) {
+			n, err := osx.GetCPU()
+			return fmt.Sprintf("%d", n), err
+		},
+		"ram": func() (string, error) {
+			n, err := osx.GetRAM()
+			return osx.BytesToString(n), err
+		},
+		"disk": func() (string, error) {
+			n, err := osx.GetDiskSpace()
+			return osx.BytesToString(n), err
+		},
+	}
+	names := make([]string, 0, len(facts))
+	for name := range facts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		v, err := facts[name]()
+		if err != nil {
+			v = fmt.Sprintf("unknown (%s)", err)
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", name, v))
+	}
+	return []byte(sb.String()), nil
+}
+
+func releaseHistory(name, namespace, kubeconfig string) ([]byte, error) {
+	helmClient := helm.NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.SetNamespace(namespace)
+	h, err := helmClient.History(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for _, rel := range h {
+		sb.WriteString(fmt.Sprintf("%d\t%s\t%s\t%s-%s\t%s\n",
+			rel.Version, rel.Info.LastDeployed.Format(time.RFC3339), rel.Info.Status,
+			rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, rel.Info.Description))
+	}
+	return []byte(sb.String()), nil
+}
@@ -1,5 +1,10 @@
 package recipe
 
+import (
+	"strconv"
+	"time"
+)
+
 const (
 	// CertResolver is the name of the cert-manager resolver
 	CertResolver = "certResolver"
@@ -20,8 +25,14 @@ func Cook(recipePath string, deps Dependencies, hooks ...Hooks) error {
 		return err
 	}
 
+	// select the distribution
+	if err := selectDistribution(recipe, &deps); err != nil {
+		return err
+	}
+
 	// Set dependencies on the recipe object
 	recipe.Dependencies = &deps
+	recipe.Revision = strconv.FormatInt(time.Now().Unix(), 10)
 
 	// debug recipe
 	if recipe.Debug {
@@ -43,14 +54,20 @@ func Cook(recipePath string, deps Dependencies, hooks ...Hooks) error {
 	// add steps
 	if err := add(recipe,
 		step{f: func(r *Recipe) error { return checkPrerequisites(r, deps.SystemInfo) }, c: recipe.Node.Check},
-		step{f: func(r *Recipe) error { return installK3s(r, deps.K3s, deps.Helm, deps.FileSystem) }, c: recipe.K3s.Enabled},
+		step{f: func(r *Recipe) error { return installDistribution(r, deps.Distribution, deps.Helm, deps.FileSystem) }, c: distributionEnabled(recipe)},
+		step{f: scheduleSnapshots, c: true},
 		step{f: func(r *Recipe) error { return installK9s(r, deps.K9s) }, c: recipe.K9s.Enabled},
+		step{f: createNamespaces, c: len(recipe.Namespaces) > 0},
+		step{f: applyRBAC, c: len(recipe.RBAC.Roles)+len(recipe.RBAC.Bindings) > 0},
 		step{f: createSecrets, c: recipe.Secrets.Enabled},
-		step{f: func(r *Recipe) error { return installCertManager(r, deps.CertManager) }, c: recipe.CertManager.Enabled},
-		step{f: func(r *Recipe) error { return installTraefik(r, deps.Traefik) }, c: recipe.Traefik.Enabled},
-		step{f: func(r *Recipe) error { return installRancher(r, deps.Rancher) }, c: recipe.Rancher.Enabled},
-		step{f: func(r *Recipe) error { return installArgocd(r, deps.ArgoCD) }, c: recipe.ArgoCD.Enabled},
+		step{f: planned("cert-manager", planCertManager(deps.CertManager), func(r *Recipe) error { return installCertManager(r, deps.CertManager) }), c: recipe.CertManager.Enabled, p: true},
+		step{f: planned("traefik", planTraefik(deps.Traefik), func(r *Recipe) error { return installTraefik(r, deps.Traefik) }), c: recipe.Traefik.Enabled, p: true},
+		step{f: planned("rancher", planRancher(deps.Rancher), func(r *Recipe) error { return installRancher(r, deps.Rancher) }), c: recipe.Rancher.Enabled, p: true},
+		step{f: planned("argocd", planArgocd(deps.ArgoCD), func(r *Recipe) error { return installArgocd(r, deps.ArgoCD) }), c: recipe.ArgoCD.Enabled, p: true},
+		step{f: func(r *Recipe) error { return purgeGitops(r, deps.Pruner) }, c: recipe.Gitops.Enabled && recipe.Gitops.PurgeExisting},
 		step{f: configureGitopsProjects, c: recipe.Gitops.Enabled},
+		step{f: func(r *Recipe) error { return installCharts(r, deps.Helm) }, c: len(recipe.Charts) > 0, p: true},
+		step{f: func(r *Recipe) error { return pruneObjects(r, deps.Pruner) }, c: recipe.Prune},
 		step{f: printKubeconfig, c: recipe.Debug},
 	); err != nil {
 		return err
@@ -68,7 +85,7 @@ func Cook(recipePath string, deps Dependencies, hooks ...Hooks) error {
 
 func add(r *Recipe, steps ...step) error {
 	for _, step := range steps {
-		if !step.c {
+		if !step.c || (r.Plan && !step.p) {
 			continue
 		}
 		if err := step.f(r); err != nil {
@@ -1,14 +1,17 @@
 package recipe
 
 import (
+	"context"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
-	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/k9s"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/rancher"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/traefik"
+	"github.com/zcubbs/hotpot/pkg/syncd/service"
 	"github.com/zcubbs/hotpot/pkg/x/host"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"os"
 )
 
@@ -16,7 +19,6 @@ import (
 func DefaultDependencies() Dependencies {
 	return Dependencies{
 		SystemInfo:  host.DefaultSystemInfo{},
-		K3s:         k3s.DefaultManager{},
 		Helm:        helm.DefaultManager{},
 		CertManager: certmanager.DefaultManager{},
 		Traefik:     traefik.DefaultManager{},
@@ -24,6 +26,8 @@ func DefaultDependencies() Dependencies {
 		Rancher:     rancher.DefaultManager{},
 		K9s:         k9s.DefaultManager{},
 		FileSystem:  defaultFileSystem{},
+		Inspector:   defaultInspector{},
+		Pruner:      defaultPruner{},
 	}
 }
 
@@ -32,3 +36,42 @@ type defaultFileSystem struct{}
 func (d defaultFileSystem) RemoveAll(path string) error {
 	return os.RemoveAll(path)
 }
+
+type defaultInspector struct{}
+
+func (d defaultInspector) ClusterReady(kubeconfig string) (bool, error) {
+	return kubernetes.IsClusterReady(context.Background(), kubeconfig)
+}
+
+func (d defaultInspector) Nodes(kubeconfig string) ([]kubernetes.NodeStatus, error) {
+	return kubernetes.GetNodeStatuses(context.Background(), kubeconfig)
+}
+
+func (d defaultInspector) Release(name, namespace, kubeconfig string) (*helm.ReleaseStatus, error) {
+	helmClient := helm.NewClient()
+	helmClient.Settings.KubeConfig = kubeconfig
+	helmClient.Settings.SetNamespace(namespace)
+	return helmClient.Status(name)
+}
+
+func (d defaultInspector) Deployments(namespace, kubeconfig string) ([]kubernetes.DeploymentStatus, error) {
+	return kubernetes.GetDeploymentStatuses(context.Background(), kubeconfig, namespace)
+}
+
+func (d defaultInspector) ClusterIssuers(kubeconfig string) ([]certmanager.ClusterIssuerStatus, error) {
+	return certmanager.GetClusterIssuerStatuses(context.Background(), kubeconfig)
+}
+
+func (d defaultInspector) Applications(kubeconfig string) ([]argocd.ApplicationStatus, error) {
+	return argocd.GetApplicationStatuses(context.Background(), kubeconfig)
+}
+
+func (d defaultInspector) SyncdState() (string, error) {
+	return service.State()
+}
+
+type defaultPruner struct{}
+
+func (d defaultPruner) Prune(kubeconfig, selector string, resources []schema.GroupVersionResource, debug bool) ([]kubernetes.ObjectRef, error) {
+	return kubernetes.Prune(context.Background(), kubeconfig, selector, resources, debug)
+}
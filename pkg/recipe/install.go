@@ -1,21 +1,62 @@
 package recipe
 
 import (
+	"fmt"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/rancher"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/traefik"
+	"path/filepath"
+	"strings"
 )
 
-func installK3s(r *Recipe, k3sMgr K3sManager, helmMgr HelmManager, fs FileSystem) error {
-	if r.K3s.PurgeExisting {
-		if err := k3sMgr.Uninstall(r.Debug); err != nil {
-			return err
+// selectDistribution sets the distribution of the recipe on deps when missing,
+// and the recipe kubeconfig to the one it writes when unset
+func selectDistribution(r *Recipe, deps *Dependencies) error {
+	if deps.Distribution == nil {
+		if deps.K3s != nil && (r.Distribution == distribution.K3s || r.Distribution == "") {
+			deps.Distribution = distribution.WrapK3s(deps.K3s)
+		} else {
+			dist, err := distribution.Get(r.Distribution)
+			if err != nil {
+				return err
+			}
+			deps.Distribution = dist
 		}
 	}
+	if r.Kubeconfig == "" {
+		r.Kubeconfig = deps.Distribution.KubeconfigPath()
+	}
+	return nil
+}
+
+// distributionEnabled reports whether the section of the recipe distribution is enabled
+func distributionEnabled(r *Recipe) bool {
+	if r.Distribution == distribution.RKE2 {
+		return r.Rke2.Enabled
+	}
+	return r.K3s.Enabled
+}
 
-	err := k3sMgr.Install(k3s.Config{
+// distributionConfig maps the section of the recipe distribution
+func distributionConfig(r *Recipe) distribution.Config {
+	if r.Distribution == distribution.RKE2 {
+		return distribution.Config{
+			Disable:             r.Rke2.Disable,
+			Version:             r.Rke2.Version,
+			TlsSan:              r.Rke2.TlsSan,
+			DataDir:             r.Rke2.DataDir,
+			WriteKubeconfigMode: r.Rke2.WriteKubeconfigMode,
+			ResolvConfPath:      r.Rke2.ResolvConfPath,
+			Profile:             r.Rke2.Profile,
+			Registries:          k3sRegistries(r.Rke2.Registries),
+			InstallScriptSha256: r.Rke2.InstallScriptSha256,
+		}
+	}
+	return distribution.Config{
 		Disable:                 r.K3s.Disable,
 		Version:                 r.K3s.Version,
 		TlsSan:                  r.K3s.TlsSan,
@@ -24,7 +65,23 @@ func installK3s(r *Recipe, k3sMgr K3sManager, helmMgr HelmManager, fs FileSystem
 		WriteKubeconfigMode:     r.K3s.WriteKubeconfigMode,
 		ResolvConfPath:          r.K3s.ResolvConfPath,
 		HttpsListenPort:         r.K3s.HttpsListenPort,
-	}, r.Debug)
+		Registries:              k3sRegistries(r.K3s.Registries),
+		InstallScriptSha256:     r.K3s.InstallScriptSha256,
+	}
+}
+
+func installDistribution(r *Recipe, dist distribution.Distribution, helmMgr HelmManager, fs FileSystem) error {
+	purge := r.K3s.PurgeExisting
+	if r.Distribution == distribution.RKE2 {
+		purge = r.Rke2.PurgeExisting
+	}
+	if purge {
+		if err := dist.Uninstall(r.Debug); err != nil {
+			return err
+		}
+	}
+
+	err := dist.Install(distributionConfig(r), r.Debug)
 	if err != nil {
 		return err
 	}
@@ -37,14 +94,57 @@ func installK3s(r *Recipe, k3sMgr K3sManager, helmMgr HelmManager, fs FileSystem
 
 	// Install helm if not already installed
 	if !installed {
-		return helmMgr.InstallCli(r.Debug)
+		return helmMgr.InstallCli(r.Helm.Version, r.Debug)
 	}
 
+	// reinstall helm when it doesn't match the pinned version
+	if r.Helm.Version == "" || r.Helm.Version == "latest" {
+		return nil
+	}
+	current, err := helmMgr.HelmVersion()
+	if err != nil {
+		return err
+	}
+	if strings.TrimPrefix(current, "v") != strings.TrimPrefix(r.Helm.Version, "v") {
+		fmt.Printf("🔨 Replacing helm %s with the pinned %s...\n", current, r.Helm.Version)
+		return helmMgr.InstallCli(r.Helm.Version, r.Debug)
+	}
 	return nil
 }
 
+func k3sRegistries(r K3sRegistries) k3s.Registries {
+	var registries k3s.Registries
+	for _, m := range r.Mirrors {
+		var rewrite map[string]string
+		if len(m.Rewrites) > 0 {
+			rewrite = make(map[string]string, len(m.Rewrites))
+			for _, rw := range m.Rewrites {
+				rewrite[rw.Pattern] = rw.Replacement
+			}
+		}
+		registries.Mirrors = append(registries.Mirrors, k3s.Mirror{
+			Registry:  m.Registry,
+			Endpoints: m.Endpoints,
+			Rewrite:   rewrite,
+		})
+	}
+	for _, c := range r.Configs {
+		registries.Configs = append(registries.Configs, k3s.RegistryConfig{
+			Registry:           c.Registry,
+			Username:           c.Auth.Username,
+			Password:           c.Auth.Password,
+			Token:              c.Auth.Token,
+			CaFile:             c.Tls.CaFile,
+			CertFile:           c.Tls.CertFile,
+			KeyFile:            c.Tls.KeyFile,
+			InsecureSkipVerify: c.Tls.InsecureSkipVerify,
+		})
+	}
+	return registries
+}
+
 func installK9s(r *Recipe, k9sMgr K9sManager) error {
-	return k9sMgr.Install(r.Debug)
+	return k9sMgr.Install(r.K9s.Version, r.Debug)
 }
 
 func installCertManager(r *Recipe, certMgr CertManager) error {
@@ -54,7 +154,11 @@ func installCertManager(r *Recipe, certMgr CertManager) error {
 		}
 	}
 
-	return certMgr.Install(certmanager.Values{
+	return certMgr.Install(certManagerValues(r), r.Kubeconfig, r.Debug)
+}
+
+func certManagerValues(r *Recipe) certmanager.Values {
+	return certmanager.Values{
 		Version:                     r.CertManager.Version,
 		LetsencryptIssuerEnabled:    r.CertManager.LetsencryptIssuerEnabled,
 		LetsencryptIssuerEmail:      r.CertManager.LetsencryptIssuerEmail,
@@ -74,31 +178,190 @@ func installCertManager(r *Recipe, certMgr CertManager) error {
 		DnsOvhApplicationSecret:     r.CertManager.DnsOvhApplicationSecret,
 		DnsOvhConsumerKey:           r.CertManager.DnsOvhConsumerKey,
 		DnsOvhZone:                  r.CertManager.DnsOvhZone,
-	}, r.Kubeconfig, r.Debug)
+		Chart:                       helmSource(r, r.CertManager.Chart),
+		Overrides:                   helmOverrides(r, r.CertManager.Values, r.CertManager.ValuesFiles),
+		Labels:                      managedLabels(r),
+	}
 }
 
 func installTraefik(r *Recipe, traefikMgr TraefikManager) error {
-	return traefikMgr.Install(traefik.Values{
+	return traefikMgr.Install(traefikValues(r), r.Kubeconfig, r.Debug)
+}
+
+func traefikValues(r *Recipe) traefik.Values {
+	return traefik.Values{
 		AdditionalArguments: []string{},
 		IngressProvider:     r.Traefik.IngressProvider,
 		TlsStrictSNI:        false,
-	}, r.Kubeconfig, r.Debug)
+		Chart:               helmSource(r, r.Traefik.Chart),
+		Overrides:           helmOverrides(r, r.Traefik.Values, r.Traefik.ValuesFiles),
+		Labels:              managedLabels(r),
+	}
 }
 
 func installRancher(r *Recipe, rancherMgr RancherManager) error {
-	return rancherMgr.Install(rancher.Values{
-		Version:  r.Rancher.Version,
-		Hostname: r.Rancher.Hostname,
-	}, r.Kubeconfig, r.Debug)
+	return rancherMgr.Install(rancherValues(r), r.Kubeconfig, r.Debug)
+}
+
+func rancherValues(r *Recipe) rancher.Values {
+	return rancher.Values{
+		Version:   r.Rancher.Version,
+		Hostname:  r.Rancher.Hostname,
+		Chart:     helmSource(r, r.Rancher.Chart),
+		Overrides: helmOverrides(r, r.Rancher.Values, r.Rancher.ValuesFiles),
+	}
 }
 
 func installArgocd(r *Recipe, argocdMgr ArgoCDManager) error {
 	if r.ArgoCD.Enabled {
-		return argocdMgr.Install(argocd.Values{
-			Insecure:      r.ArgoCD.Insecure,
-			ChartVersion:  r.ArgoCD.ChartVersion,
-			AdminPassword: r.ArgoCD.AdminPassword,
-		}, r.Kubeconfig, r.Debug)
+		return argocdMgr.Install(argocdValues(r), r.Kubeconfig, r.Debug)
 	}
 	return nil
 }
+
+func argocdValues(r *Recipe) argocd.Values {
+	return argocd.Values{
+		Insecure:      r.ArgoCD.Insecure,
+		ChartVersion:  r.ArgoCD.ChartVersion,
+		AdminPassword: r.ArgoCD.AdminPassword,
+		Chart:         helmSource(r, r.ArgoCD.Chart),
+		Overrides:     helmOverrides(r, r.ArgoCD.Values, r.ArgoCD.ValuesFiles),
+	}
+}
+
+func installCharts(r *Recipe, helmMgr HelmManager) error {
+	for _, c := range r.Charts {
+		chart, repo, c := helmChart(r, c), chartRepository(r, c), c
+		install := planned(c.Name,
+			func(r *Recipe) (string, error) {
+				return helmMgr.DiffChart(chart, repo, c.Namespace, r.Kubeconfig)
+			},
+			func(r *Recipe) error {
+				return helmMgr.InstallChart(chart, repo, c.Namespace, r.Kubeconfig)
+			},
+		)
+		if err := install(r); err != nil {
+			return fmt.Errorf("failed to install chart %s \n %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func helmChart(r *Recipe, c ChartConfig) helm.Chart {
+	chart := helmOverrides(r, c.Values, c.ValuesFiles).Apply(helm.Chart{
+		ChartName:       c.Chart,
+		ReleaseName:     c.Name,
+		RepoName:        c.RepoName,
+		Version:         c.Version,
+		Registry:        helmRegistry(c.Registry),
+		Debug:           r.Debug,
+		CreateNamespace: true,
+		Upgrade:         true,
+		Atomic:          c.Atomic,
+		Wait:            c.Wait,
+		Timeout:         c.Timeout,
+	})
+	if helm.IsOCI(c.Chart) {
+		chart.Ref = c.Chart
+	} else if chart.RepoName == "" {
+		chart.RepoName = c.Name
+	}
+	return chart
+}
+
+func helmSource(r *Recipe, s ChartSource) helm.Source {
+	source := helm.Source{
+		Ref:      s.Ref,
+		Version:  s.Version,
+		Registry: helmRegistry(s.Registry),
+	}
+	if repo := findRepository(r, s.Repository); repo != nil {
+		source.Repository = helmRepository(*repo)
+	}
+	return source
+}
+
+// chartRepository returns the repository c is installed from, a recipe
+// repository when c.RepoName names one and no repoUrl is set
+func chartRepository(r *Recipe, c ChartConfig) helm.Repository {
+	if repo := findRepository(r, c.RepoName); repo != nil && c.RepoUrl == "" {
+		return *helmRepository(*repo)
+	}
+	return helm.Repository{Name: helmChart(r, c).RepoName, Url: c.RepoUrl}
+}
+
+// findRepository returns the recipe repository called name, nil when there is none
+func findRepository(r *Recipe, name string) *RepositoryConfig {
+	for i := range r.Repositories {
+		if name != "" && r.Repositories[i].Name == name {
+			return &r.Repositories[i]
+		}
+	}
+	return nil
+}
+
+func helmRepository(repo RepositoryConfig) *helm.Repository {
+	return &helm.Repository{
+		Name: repo.Name,
+		Url:  repo.Url,
+		Options: helm.RepoOptions{
+			Username:           repo.Username,
+			Password:           repo.Password,
+			CaFile:             repo.Tls.CaFile,
+			CertFile:           repo.Tls.CertFile,
+			KeyFile:            repo.Tls.KeyFile,
+			InsecureSkipVerify: repo.Tls.InsecureSkipVerify,
+			PassCredentialsAll: repo.PassCredentialsAll,
+		},
+	}
+}
+
+// helmOverrides resolves relative values files against the recipe directory
+func helmOverrides(r *Recipe, values map[string]interface{}, valuesFiles []string) helm.Overrides {
+	files := make([]string, 0, len(valuesFiles))
+	for _, f := range valuesFiles {
+		if !filepath.IsAbs(f) && r.Path != "" {
+			f = filepath.Join(filepath.Dir(r.Path), f)
+		}
+		files = append(files, f)
+	}
+	return helm.Overrides{
+		Values:      values,
+		ValuesFiles: files,
+	}
+}
+
+func helmRegistry(r ChartRegistry) helm.RegistryOptions {
+	return helm.RegistryOptions{
+		Username:           r.Username,
+		Password:           r.Password,
+		CaFile:             r.Tls.CaFile,
+		CertFile:           r.Tls.CertFile,
+		KeyFile:            r.Tls.KeyFile,
+		InsecureSkipVerify: r.Tls.InsecureSkipVerify,
+		PlainHttp:          r.PlainHttp,
+	}
+}
+
+// K3sSnapshotConfig maps the recipe snapshots block to the k3s snapshot config
+func K3sSnapshotConfig(r *Recipe) k3s.SnapshotConfig {
+	s := r.K3s.Snapshots
+	return k3s.SnapshotConfig{
+		DataDir:   r.K3s.DataDir,
+		Dir:       s.Dir,
+		Name:      s.Name,
+		Retention: s.Retention,
+		S3: k3s.SnapshotS3Config{
+			Enabled:       s.S3.Enabled,
+			Endpoint:      s.S3.Endpoint,
+			EndpointCA:    s.S3.EndpointCA,
+			Bucket:        s.S3.Bucket,
+			Folder:        s.S3.Folder,
+			Region:        s.S3.Region,
+			AccessKey:     s.S3.AccessKey,
+			SecretKey:     s.S3.SecretKey,
+			Insecure:      s.S3.Insecure,
+			SkipSSLVerify: s.S3.SkipSSLVerify,
+		},
+	}
+}
@@ -3,9 +3,14 @@ package recipe
 import (
 	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/rancher"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/traefik"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"time"
 )
 
 // SystemInfo provides system-related operations
@@ -19,6 +24,8 @@ type SystemInfo interface {
 }
 
 // K3sManager handles K3s operations
+//
+// Deprecated: set Dependencies.Distribution instead.
 type K3sManager interface {
 	Install(cfg k3s.Config, debug bool) error
 	Uninstall(debug bool) error
@@ -27,39 +34,65 @@ type K3sManager interface {
 // HelmManager handles Helm operations
 type HelmManager interface {
 	IsHelmInstalled() (bool, error)
-	InstallCli(debug bool) error
+	// HelmVersion returns the version of the installed helm cli, e.g. v3.16.4
+	HelmVersion() (string, error)
+	InstallCli(version string, debug bool) error
+	InstallChart(chart helm.Chart, repo helm.Repository, namespace, kubeconfig string) error
+	DiffChart(chart helm.Chart, repo helm.Repository, namespace, kubeconfig string) (string, error)
 }
 
 // CertManager handles cert-manager operations
 type CertManager interface {
 	Install(values certmanager.Values, kubeconfig string, debug bool) error
+	Plan(values certmanager.Values, kubeconfig string, debug bool) (string, error)
 	Uninstall(kubeconfig string, debug bool) error
 }
 
 // TraefikManager handles Traefik operations
 type TraefikManager interface {
 	Install(values traefik.Values, kubeconfig string, debug bool) error
+	Plan(values traefik.Values, kubeconfig string, debug bool) (string, error)
 	Uninstall(kubeconfig string, debug bool) error
 }
 
 // ArgoCDManager handles ArgoCD operations
 type ArgoCDManager interface {
 	Install(values argocd.Values, kubeconfig string, debug bool) error
+	Plan(values argocd.Values, kubeconfig string, debug bool) (string, error)
 	Uninstall(kubeconfig string, debug bool) error
 	CreateProject(project argocd.Project, kubeconfig string, debug bool) error
 	CreateApplication(app argocd.Application, kubeconfig string, debug bool) error
 	CreateRepository(repo argocd.Repository, kubeconfig string, debug bool) error
+	// WaitApplications waits for the applications to be synced and healthy
+	WaitApplications(namespace string, names []string, timeout time.Duration, kubeconfig string, debug bool) error
 }
 
 // RancherManager handles Rancher operations
 type RancherManager interface {
 	Install(values rancher.Values, kubeconfig string, debug bool) error
+	Plan(values rancher.Values, kubeconfig string, debug bool) (string, error)
 	Uninstall(kubeconfig string, debug bool) error
 }
 
 // K9sManager handles K9s operations
 type K9sManager interface {
-	Install(debug bool) error
+	Install(version string, debug bool) error
+}
+
+// Inspector reads the state of the cluster and of the node for status reports
+type Inspector interface {
+	ClusterReady(kubeconfig string) (bool, error)
+	Nodes(kubeconfig string) ([]kubernetes.NodeStatus, error)
+	Release(name, namespace, kubeconfig string) (*helm.ReleaseStatus, error)
+	Deployments(namespace, kubeconfig string) ([]kubernetes.DeploymentStatus, error)
+	ClusterIssuers(kubeconfig string) ([]certmanager.ClusterIssuerStatus, error)
+	Applications(kubeconfig string) ([]argocd.ApplicationStatus, error)
+	SyncdState() (string, error)
+}
+
+// Pruner deletes the objects hotpot created that match a label selector
+type Pruner interface {
+	Prune(kubeconfig, selector string, resources []schema.GroupVersionResource, debug bool) ([]kubernetes.ObjectRef, error)
 }
 
 // FileSystem handles file system operations
@@ -69,13 +102,20 @@ type FileSystem interface {
 
 // Dependencies holds all external dependencies
 type Dependencies struct {
-	SystemInfo  SystemInfo
-	K3s         K3sManager
-	Helm        HelmManager
-	CertManager CertManager
-	Traefik     TraefikManager
-	ArgoCD      ArgoCDManager
-	Rancher     RancherManager
-	K9s         K9sManager
-	FileSystem  FileSystem
+	SystemInfo SystemInfo
+	// Distribution is selected from the recipe when nil
+	Distribution distribution.Distribution
+	Helm         HelmManager
+	CertManager  CertManager
+	Traefik      TraefikManager
+	ArgoCD       ArgoCDManager
+	Rancher      RancherManager
+	K9s          K9sManager
+	FileSystem   FileSystem
+	Inspector    Inspector
+	Pruner       Pruner
+	// K3s installs the k3s distribution when Distribution is nil
+	//
+	// Deprecated: set Distribution instead.
+	K3s K3sManager
 }
@@ -1,18 +1,36 @@
 package recipe
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/viper"
+	"github.com/zcubbs/hotpot/pkg/crypto/sops"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/k9s"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/rke2"
+	"github.com/zcubbs/hotpot/pkg/secret"
+	"maps"
+	"os"
 	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"strings"
 )
 
 func Load(path string) (*Recipe, error) {
 	var recipe Recipe
 
+	data, err := readRecipe(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load recipe file path=%s err=%s", path, err)
+	}
+
 	initViperPresets(path)
 
-	err := viper.ReadInConfig()
+	err = viper.ReadConfig(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("unable to load recipe file path=%s err=%s", path, err)
 	}
@@ -21,9 +39,147 @@ func Load(path string) (*Recipe, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not decode recipe into struct err=%s", err)
 	}
+
+	err = loadRawSections(data, &recipe)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode chart values, namespaces and secret providers err=%s", err)
+	}
+
+	applyDistributionDefaults(&recipe)
+
+	recipe.Path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve recipe file path=%s err=%s", path, err)
+	}
+
+	err = configureSecretProviders(&recipe)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure secret providers err=%s", err)
+	}
 	return &recipe, nil
 }
 
+// readRecipe returns the recipe file, decrypted when it is a sops file. The
+// keys come from the sops age defaults, the GnuPG keyring or HOTPOT_SOPS_PGP_KEY_FILE.
+func readRecipe(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !sops.IsEncrypted(data) {
+		return data, nil
+	}
+
+	plaintext, err := sops.Decrypt(string(data), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops recipe \n %w", err)
+	}
+	return []byte(plaintext), nil
+}
+
+// configureSecretProviders hands the secretProviders section to the providers
+// resolving secret references, the entries of the registered providers by scheme
+func configureSecretProviders(r *Recipe) error {
+	v := r.SecretProviders.Vault
+	secret.ConfigureVault(secret.VaultConfig{
+		Address:    v.Address,
+		Namespace:  v.Namespace,
+		AuthMethod: v.AuthMethod,
+		AuthMount:  v.AuthMount,
+		Token:      v.Token,
+		RoleId:     v.RoleId,
+		SecretId:   v.SecretId,
+		Role:       v.Role,
+		JwtPath:    v.JwtPath,
+		KvVersion:  v.KvVersion,
+		CaCert:     v.CaCert,
+	})
+
+	k := r.SecretProviders.Kubernetes
+	if k.Kubeconfig == "" {
+		k.Kubeconfig = r.Kubeconfig
+	}
+	secret.ConfigureKubernetes(secret.KubernetesConfig{Kubeconfig: k.Kubeconfig, Context: k.Context})
+
+	a := r.SecretProviders.Aws
+	secret.ConfigureAws(secret.AwsConfig{Region: a.Region, Profile: a.Profile, Endpoint: a.Endpoint})
+
+	az := r.SecretProviders.Azure
+	secret.ConfigureAzure(secret.AzureConfig{
+		AuthMethod:   az.AuthMethod,
+		TenantId:     az.TenantId,
+		ClientId:     az.ClientId,
+		ClientSecret: az.ClientSecret,
+		Endpoint:     az.Endpoint,
+	})
+
+	g := r.SecretProviders.Gcp
+	secret.ConfigureGcp(secret.GcpConfig{AuthMethod: g.AuthMethod, CredentialsFile: g.CredentialsFile, Project: g.Project, Endpoint: g.Endpoint})
+
+	s := r.SecretProviders.Sops
+	secret.ConfigureSops(secret.SopsConfig{AgeKeyFile: s.AgeKeyFile, PgpKeyFile: s.PgpKeyFile, BaseDir: filepath.Dir(r.Path)})
+
+	for _, scheme := range slices.Sorted(maps.Keys(r.SecretProviders.Custom)) {
+		if err := secret.Configure(scheme, r.SecretProviders.Custom[scheme]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawSections mirrors the recipe chart values, namespaces and secret providers. They are read apart
+// from viper, which lowercases keys and splits them on dots, e.g. kubernetes.io/os.
+type rawSections struct {
+	CertManager componentValues   `json:"certManager"`
+	Traefik     componentValues   `json:"traefik"`
+	ArgoCD      componentValues   `json:"argocd"`
+	Rancher     componentValues   `json:"rancher"`
+	Charts      []componentValues `json:"charts"`
+	Namespaces  []NamespaceConfig `json:"namespaces"`
+	// SecretProviders holds the entries of registered providers besides the built-in ones
+	SecretProviders map[string]interface{} `json:"secretProviders"`
+}
+
+// builtinSecretProviders are the secretProviders entries of SecretProvidersConfig
+var builtinSecretProviders = []string{"vault", "kubernetes", "aws", "azure", "gcp", "sops"}
+
+type componentValues struct {
+	Values map[string]interface{} `json:"values"`
+}
+
+func loadRawSections(data []byte, recipe *Recipe) error {
+	var values rawSections
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	recipe.CertManager.Values = values.CertManager.Values
+	recipe.Traefik.Values = values.Traefik.Values
+	recipe.ArgoCD.Values = values.ArgoCD.Values
+	recipe.Rancher.Values = values.Rancher.Values
+	for i := range recipe.Charts {
+		if i < len(values.Charts) {
+			recipe.Charts[i].Values = values.Charts[i].Values
+		}
+	}
+	recipe.Namespaces = values.Namespaces
+
+	for scheme, config := range values.SecretProviders {
+		if slices.Contains(builtinSecretProviders, scheme) {
+			continue
+		}
+		entry, ok := config.(map[string]interface{})
+		if !ok && config != nil {
+			return fmt.Errorf("secretProviders.%s is not a map", scheme)
+		}
+		if recipe.SecretProviders.Custom == nil {
+			recipe.SecretProviders.Custom = make(map[string]map[string]interface{})
+		}
+		recipe.SecretProviders.Custom[scheme] = entry
+	}
+	return nil
+}
+
 func initViperPresets(path string) {
 	dir := filepath.Dir(path)
 	file := filepath.Base(path)
@@ -36,24 +192,119 @@ func initViperPresets(path string) {
 	}
 }
 
-func validate(_ *Recipe) error {
+// applyDistributionDefaults sets the defaults that depend on other sections.
+// The rke2 ingress controller is disabled next to traefik, both bind 80 and 443.
+func applyDistributionDefaults(r *Recipe) {
+	if r.Traefik.Enabled && !slices.Contains(r.Rke2.Disable, rke2.IngressNginx) {
+		r.Rke2.Disable = append(r.Rke2.Disable, rke2.IngressNginx)
+	}
+}
+
+func validate(r *Recipe) error {
+	if r.K3s.Snapshots.Enabled && r.Distribution != distribution.K3s {
+		return fmt.Errorf("k3s.snapshots is only supported with the k3s distribution")
+	}
+	if r.Distribution == distribution.RKE2 && r.K3s.Enabled {
+		return fmt.Errorf("k3s.enabled is set but the rke2 distribution is configured in the rke2 section")
+	}
+	if r.Distribution != distribution.RKE2 && r.Rke2.Enabled {
+		return fmt.Errorf("rke2.enabled is set but the distribution is %s, set distribution: rke2", r.Distribution)
+	}
+	namespaces := make(map[string]bool)
+	for i, ns := range r.Namespaces {
+		if ns.Name == "" {
+			return fmt.Errorf("namespaces[%d] requires a name", i)
+		}
+		if namespaces[ns.Name] {
+			return fmt.Errorf("namespace %s is declared twice", ns.Name)
+		}
+		namespaces[ns.Name] = true
+		if ns.PodSecurity != "" && !slices.Contains(kubernetes.PodSecurityLevels, ns.PodSecurity) {
+			return fmt.Errorf("namespace %s podSecurity %s must be one of %s", ns.Name, ns.PodSecurity, strings.Join(kubernetes.PodSecurityLevels, ", "))
+		}
+	}
+	if m := r.SecretProviders.Vault.AuthMethod; m != "" && !slices.Contains([]string{secret.VaultAuthToken, secret.VaultAuthAppRole, secret.VaultAuthKubernetes}, m) {
+		return fmt.Errorf("secretProviders.vault.authMethod %s must be token, approle or kubernetes", m)
+	}
+	if v := r.SecretProviders.Vault.KvVersion; v != 0 && v != 1 && v != 2 {
+		return fmt.Errorf("secretProviders.vault.kvVersion must be 1 or 2")
+	}
+	if m := r.SecretProviders.Azure.AuthMethod; m != "" && !slices.Contains([]string{secret.AzureAuthServicePrincipal, secret.AzureAuthManagedIdentity, secret.AzureAuthNone}, m) {
+		return fmt.Errorf("secretProviders.azure.authMethod %s must be servicePrincipal, managedIdentity or none", m)
+	}
+	if m := r.SecretProviders.Gcp.AuthMethod; m != "" && !slices.Contains([]string{secret.GcpAuthServiceAccount, secret.GcpAuthNone}, m) {
+		return fmt.Errorf("secretProviders.gcp.authMethod %s must be serviceAccount or none", m)
+	}
+	if err := validateRBAC(r.RBAC); err != nil {
+		return err
+	}
+	for i, repo := range r.Repositories {
+		if repo.Name == "" || repo.Url == "" {
+			return fmt.Errorf("repositories[%d] requires a name and a url", i)
+		}
+	}
+	sources := []struct {
+		component string
+		source    ChartSource
+	}{
+		{"certManager", r.CertManager.Chart},
+		{"traefik", r.Traefik.Chart},
+		{"rancher", r.Rancher.Chart},
+		{"argocd", r.ArgoCD.Chart},
+	}
+	for _, s := range sources {
+		if s.source.Repository != "" && findRepository(r, s.source.Repository) == nil {
+			return fmt.Errorf("%s.chart.repository %s is not a recipe repository", s.component, s.source.Repository)
+		}
+	}
+	for i, c := range r.Charts {
+		if c.Name == "" || c.Chart == "" {
+			return fmt.Errorf("charts[%d] requires a name and a chart", i)
+		}
+		if c.Namespace == "" {
+			return fmt.Errorf("chart %s requires a namespace", c.Name)
+		}
+		if !helm.IsOCI(c.Chart) && c.RepoUrl == "" && findRepository(r, c.RepoName) == nil {
+			return fmt.Errorf("chart %s requires a repoUrl, a recipe repository or an oci:// chart reference", c.Name)
+		}
+	}
 	return nil
 }
 
+// printRecipe prints the recipe with its secrets redacted, sops recipes being decrypted on load
 func printRecipe(recipe *Recipe) {
-	jsonConfig, err := json.MarshalIndent(recipe, "", "  ")
+	jsonConfig, err := redactRecipe(recipe)
 	if err != nil {
 		fmt.Println("error:", err)
 		return
 	}
 
-	fmt.Printf("recipe path: %s\n", viper.ConfigFileUsed())
+	fmt.Printf("recipe path: %s\n", recipe.Path)
 	fmt.Printf("%v\n", string(jsonConfig))
 }
 
+// redactRecipe returns the recipe as indented json, redacted like the support bundle recipe
+func redactRecipe(recipe *Recipe) ([]byte, error) {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redact(doc), "", "  ")
+}
+
 var defaults = map[string]interface{}{
-	"kubeconfig":                           "/etc/rancher/k3s/k3s.yaml",
+	"distribution":                         distribution.K3s,
 	"k3s.disable":                          []string{"traefik"},
-	"k3s.version":                          "latest",
+	"helm.version":                         helm.DefaultVersion,
+	"k9s.version":                          k9s.DefaultVersion,
 	"certManager.letsencryptIssuerEnabled": true,
+	"k3s.snapshots.schedule":               "0 */12 * * *",
+	"k3s.snapshots.retention":              5,
+	"k3s.snapshots.dir":                    "/var/backups/hotpot/snapshots",
+	"gitops.wait":                          true,
+	"gitops.timeout":                       "5m",
 }
@@ -1,11 +1,18 @@
 package recipe
 
 import (
+	"fmt"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/k3s"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/rancher"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/traefik"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+	"time"
 )
 
 type mockSystemInfo struct {
@@ -25,23 +32,64 @@ func (m *mockSystemInfo) IsDiskSpaceEnough(_, _ string) error { return m.diskErr
 func (m *mockSystemInfo) IsCurlOK(_ []string) error           { return m.curlErr }
 
 type mockK3sManager struct {
+	installed k3s.Config
+}
+
+func (m *mockK3sManager) Install(cfg k3s.Config, _ bool) error {
+	m.installed = cfg
+	return nil
+}
+
+func (m *mockK3sManager) Uninstall(_ bool) error { return nil }
+
+type mockDistribution struct {
 	installErr   error
 	uninstallErr error
+	installed    distribution.Config
 }
 
-func (m *mockK3sManager) Install(_ k3s.Config, _ bool) error { return m.installErr }
-func (m *mockK3sManager) Uninstall(_ bool) error             { return m.uninstallErr }
+func (m *mockDistribution) Name() string { return "mock" }
+func (m *mockDistribution) Install(cfg distribution.Config, _ bool) error {
+	m.installed = cfg
+	return m.installErr
+}
+func (m *mockDistribution) Uninstall(_ bool) error                             { return m.uninstallErr }
+func (m *mockDistribution) IsInstalled() bool                                  { return false }
+func (m *mockDistribution) KubeconfigPath() string                             { return "/tmp/kubeconfig" }
+func (m *mockDistribution) ConfigDir() string                                  { return "/tmp/mock" }
+func (m *mockDistribution) ServiceName() string                                { return "mock" }
+func (m *mockDistribution) RenderConfig(_ distribution.Config) ([]byte, error) { return nil, nil }
+func (m *mockDistribution) InstalledVersion() (string, error)                  { return "", nil }
 
 type mockHelmManager struct {
 	isInstalledResult bool
 	isInstalledErr    error
 	installErr        error
+	installedVersion  string
+	currentVersion    string
+	charts            []helm.Chart
+	repos             []helm.Repository
+	diff              string
 }
 
 func (m *mockHelmManager) IsHelmInstalled() (bool, error) {
 	return m.isInstalledResult, m.isInstalledErr
 }
-func (m *mockHelmManager) InstallCli(_ bool) error { return m.installErr }
+func (m *mockHelmManager) HelmVersion() (string, error) {
+	return m.currentVersion, nil
+}
+func (m *mockHelmManager) InstallCli(version string, _ bool) error {
+	m.installedVersion = version
+	return m.installErr
+}
+func (m *mockHelmManager) InstallChart(chart helm.Chart, repo helm.Repository, _, _ string) error {
+	m.charts = append(m.charts, chart)
+	m.repos = append(m.repos, repo)
+	return m.installErr
+}
+func (m *mockHelmManager) DiffChart(_ helm.Chart, _ helm.Repository, _, _ string) (string, error) {
+	return m.diff, nil
+}
 
 type mockCertManager struct {
 	installErr   error
@@ -50,14 +98,24 @@ type mockCertManager struct {
 
 func (m *mockCertManager) Install(_ certmanager.Values, _ string, _ bool) error { return m.installErr }
 func (m *mockCertManager) Uninstall(_ string, _ bool) error                     { return m.uninstallErr }
+func (m *mockCertManager) Plan(_ certmanager.Values, _ string, _ bool) (string, error) {
+	return "", nil
+}
 
 type mockTraefikManager struct {
 	installErr   error
 	uninstallErr error
+	installed    traefik.Values
 }
 
-func (m *mockTraefikManager) Install(_ traefik.Values, _ string, _ bool) error { return m.installErr }
-func (m *mockTraefikManager) Uninstall(_ string, _ bool) error                 { return m.uninstallErr }
+func (m *mockTraefikManager) Install(values traefik.Values, _ string, _ bool) error {
+	m.installed = values
+	return m.installErr
+}
+func (m *mockTraefikManager) Uninstall(_ string, _ bool) error { return m.uninstallErr }
+func (m *mockTraefikManager) Plan(_ traefik.Values, _ string, _ bool) (string, error) {
+	return "", nil
+}
 
 type mockArgoCDManager struct {
 	installErr    error
@@ -65,19 +123,30 @@ type mockArgoCDManager struct {
 	createProjErr error
 	createAppErr  error
 	createRepoErr error
+	waitErr       error
+	awaited       []string
+	repositories  []argocd.Repository
 }
 
 func (m *mockArgoCDManager) Install(_ argocd.Values, _ string, _ bool) error { return m.installErr }
 func (m *mockArgoCDManager) Uninstall(_ string, _ bool) error                { return m.uninstallErr }
+func (m *mockArgoCDManager) Plan(_ argocd.Values, _ string, _ bool) (string, error) {
+	return "", nil
+}
 func (m *mockArgoCDManager) CreateProject(_ argocd.Project, _ string, _ bool) error {
 	return m.createProjErr
 }
 func (m *mockArgoCDManager) CreateApplication(_ argocd.Application, _ string, _ bool) error {
 	return m.createAppErr
 }
-func (m *mockArgoCDManager) CreateRepository(_ argocd.Repository, _ string, _ bool) error {
+func (m *mockArgoCDManager) CreateRepository(repo argocd.Repository, _ string, _ bool) error {
+	m.repositories = append(m.repositories, repo)
 	return m.createRepoErr
 }
+func (m *mockArgoCDManager) WaitApplications(_ string, names []string, _ time.Duration, _ string, _ bool) error {
+	m.awaited = append(m.awaited, names...)
+	return m.waitErr
+}
 
 type mockRancherManager struct {
 	installErr   error
@@ -86,15 +155,77 @@ type mockRancherManager struct {
 
 func (m *mockRancherManager) Install(_ rancher.Values, _ string, _ bool) error { return m.installErr }
 func (m *mockRancherManager) Uninstall(_ string, _ bool) error                 { return m.uninstallErr }
+func (m *mockRancherManager) Plan(_ rancher.Values, _ string, _ bool) (string, error) {
+	return "", nil
+}
 
 type mockK9sManager struct {
-	installErr error
+	installErr       error
+	installedVersion string
 }
 
-func (m *mockK9sManager) Install(_ bool) error { return m.installErr }
+func (m *mockK9sManager) Install(version string, _ bool) error {
+	m.installedVersion = version
+	return m.installErr
+}
 
 type mockFileSystem struct {
 	removeAllErr error
 }
 
 func (m *mockFileSystem) RemoveAll(_ string) error { return m.removeAllErr }
+
+type mockInspector struct {
+	ready        bool
+	releases     map[string]*helm.ReleaseStatus
+	deployments  map[string][]kubernetes.DeploymentStatus
+	issuers      []certmanager.ClusterIssuerStatus
+	applications []argocd.ApplicationStatus
+}
+
+func (m *mockInspector) ClusterReady(_ string) (bool, error) { return m.ready, nil }
+func (m *mockInspector) Nodes(_ string) ([]kubernetes.NodeStatus, error) {
+	return []kubernetes.NodeStatus{{Name: "node-1", Ready: m.ready, Version: "v1.31.4+k3s1"}}, nil
+}
+func (m *mockInspector) Release(name, _, _ string) (*helm.ReleaseStatus, error) {
+	return m.releases[name], nil
+}
+func (m *mockInspector) Deployments(namespace, _ string) ([]kubernetes.DeploymentStatus, error) {
+	return m.deployments[namespace], nil
+}
+func (m *mockInspector) ClusterIssuers(_ string) ([]certmanager.ClusterIssuerStatus, error) {
+	return m.issuers, nil
+}
+func (m *mockInspector) Applications(_ string) ([]argocd.ApplicationStatus, error) {
+	return m.applications, nil
+}
+func (m *mockInspector) SyncdState() (string, error) { return "active", nil }
+
+type mockPruner struct {
+	selectors []string
+	pruned    []kubernetes.ObjectRef
+}
+
+func (m *mockPruner) Prune(_, selector string, _ []schema.GroupVersionResource, _ bool) ([]kubernetes.ObjectRef, error) {
+	m.selectors = append(m.selectors, selector)
+	return m.pruned, nil
+}
+
+// mockSecretProvider stands in for a custom provider, e.g. an in-house credential broker
+type mockSecretProvider struct {
+	config map[string]interface{}
+	reads  int
+}
+
+func (m *mockSecretProvider) Configure(config map[string]interface{}) error {
+	if config["url"] == nil {
+		return fmt.Errorf("url is required")
+	}
+	m.config = config
+	return nil
+}
+
+func (m *mockSecretProvider) Provide(key string, _ ...interface{}) (string, error) {
+	m.reads++
+	return fmt.Sprintf("%s@%s", strings.TrimPrefix(key, "broker."), m.config["url"]), nil
+}
@@ -0,0 +1,64 @@
+package recipe
+
+import "fmt"
+
+// ConfirmFunc is asked before a helm release changes, with its diff.
+// The release is left as is when it returns false.
+type ConfirmFunc func(release, diff string) bool
+
+// planned wraps the install of a helm release. When the recipe is planned the
+// diff is printed instead, and when confirmation is required the install
+// only runs once the change is accepted. Releases without changes are
+// reinstalled without asking, to run what comes after the chart.
+func planned(name string, plan func(*Recipe) (string, error), install func(*Recipe) error) func(*Recipe) error {
+	return func(r *Recipe) error {
+		if !r.Plan && r.Confirm == nil {
+			return install(r)
+		}
+
+		diff, err := plan(r)
+		if err != nil {
+			return fmt.Errorf("failed to plan %s \n %w", name, err)
+		}
+
+		if r.Plan {
+			if diff == "" {
+				fmt.Printf("📋 %s: no changes\n", name)
+			} else {
+				fmt.Printf("📋 %s:\n%s", name, diff)
+			}
+			return nil
+		}
+
+		if diff != "" && !r.Confirm(name, diff) {
+			fmt.Printf("⏭️  %s: skipped\n", name)
+			r.skipped = true
+			return nil
+		}
+		return install(r)
+	}
+}
+
+func planCertManager(certMgr CertManager) func(*Recipe) (string, error) {
+	return func(r *Recipe) (string, error) {
+		return certMgr.Plan(certManagerValues(r), r.Kubeconfig, r.Debug)
+	}
+}
+
+func planTraefik(traefikMgr TraefikManager) func(*Recipe) (string, error) {
+	return func(r *Recipe) (string, error) {
+		return traefikMgr.Plan(traefikValues(r), r.Kubeconfig, r.Debug)
+	}
+}
+
+func planRancher(rancherMgr RancherManager) func(*Recipe) (string, error) {
+	return func(r *Recipe) (string, error) {
+		return rancherMgr.Plan(rancherValues(r), r.Kubeconfig, r.Debug)
+	}
+}
+
+func planArgocd(argocdMgr ArgoCDManager) func(*Recipe) (string, error) {
+	return func(r *Recipe) (string, error) {
+		return argocdMgr.Plan(argocdValues(r), r.Kubeconfig, r.Debug)
+	}
+}
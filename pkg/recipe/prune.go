@@ -0,0 +1,74 @@
+package recipe
+
+import (
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"path/filepath"
+	"strings"
+)
+
+// argocdSecretTypeLabel marks the repository and cluster secrets of ArgoCD
+const argocdSecretTypeLabel = "argocd.argoproj.io/secret-type"
+
+// recipeID identifies r in the labels of the objects it creates: its name,
+// or the name of its file
+func recipeID(r *Recipe) string {
+	if r.Name != "" {
+		return kubernetes.LabelValue(r.Name)
+	}
+	base := filepath.Base(r.Path)
+	return kubernetes.LabelValue(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// managedLabels returns the labels of the objects hotpot creates for r
+func managedLabels(r *Recipe) map[string]string {
+	return kubernetes.ManagedLabels(recipeID(r), r.Revision)
+}
+
+// managedSelector selects the objects hotpot created for r in any cook run
+func managedSelector(r *Recipe) string {
+	return fmt.Sprintf("%s=%s,%s=%s", kubernetes.ManagedByLabel, kubernetes.ManagedByValue, kubernetes.RecipeLabel, recipeID(r))
+}
+
+// pruneSelector selects the objects hotpot created for r that the current
+// cook run did not apply, so that r no longer declares
+func pruneSelector(r *Recipe) string {
+	return fmt.Sprintf("%s,%s!=%s", managedSelector(r), kubernetes.RevisionLabel, kubernetes.LabelValue(r.Revision))
+}
+
+func pruneObjects(r *Recipe, pruner Pruner) error {
+	fmt.Printf("🧹 Pruning objects no longer in the recipe... \n")
+	if r.skipped {
+		fmt.Printf("⏭️  prune: skipped, a release was left as is\n")
+		return nil
+	}
+
+	pruned, err := pruner.Prune(r.Kubeconfig, pruneSelector(r), kubernetes.ManagedResources, r.Debug)
+	for _, o := range pruned {
+		fmt.Printf("🗑️  %s\n", o)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune \n %w", err)
+	}
+	return nil
+}
+
+// purgeGitops deletes the ArgoCD applications, projects and repositories
+// created for r before they are configured again
+func purgeGitops(r *Recipe, pruner Pruner) error {
+	fmt.Printf("🧹 Purging existing gitops projects... \n")
+	selector := managedSelector(r)
+	pruned, err := pruner.Prune(r.Kubeconfig, selector, kubernetes.ArgoCDResources, r.Debug)
+	if err == nil {
+		var secrets []kubernetes.ObjectRef
+		secrets, err = pruner.Prune(r.Kubeconfig, selector+","+argocdSecretTypeLabel, kubernetes.SecretResources, r.Debug)
+		pruned = append(pruned, secrets...)
+	}
+	for _, o := range pruned {
+		fmt.Printf("🗑️  %s\n", o)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge gitops \n %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"strings"
+)
+
+func applyRBAC(r *Recipe) error {
+	fmt.Printf("🔑 Applying rbac... \n")
+	roles, bindings, err := rbacSpecs(r.RBAC)
+	if err != nil {
+		return err
+	}
+	if err := kubernetes.ApplyRBAC(context.Background(), r.Kubeconfig, roles, bindings, managedLabels(r), r.Debug); err != nil {
+		return fmt.Errorf("failed to apply rbac \n %w", err)
+	}
+	return nil
+}
+
+// rbacSpecs resolves the roles and bindings of the recipe rbac section
+func rbacSpecs(c RBACConfig) ([]kubernetes.RoleSpec, []kubernetes.BindingSpec, error) {
+	var roles []kubernetes.RoleSpec
+	for _, role := range c.Roles {
+		spec := kubernetes.RoleSpec{Name: role.Name, Namespace: role.Namespace}
+		for _, rule := range role.Rules {
+			spec.Rules = append(spec.Rules, rbacv1.PolicyRule{
+				APIGroups:       rule.ApiGroups,
+				Resources:       rule.Resources,
+				ResourceNames:   rule.ResourceNames,
+				NonResourceURLs: rule.NonResourceURLs,
+				Verbs:           rule.Verbs,
+			})
+		}
+		roles = append(roles, spec)
+	}
+
+	var bindings []kubernetes.BindingSpec
+	for _, b := range c.Bindings {
+		ref, err := roleRef(c.Roles, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		subjects, err := bindingSubjects(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		bindings = append(bindings, kubernetes.BindingSpec{Name: b.Name, Namespace: b.Namespace, RoleRef: ref, Subjects: subjects})
+	}
+	return roles, bindings, nil
+}
+
+// roleRef resolves the role of b to a preset, a role of the recipe or else an existing ClusterRole
+func roleRef(roles []RoleConfig, b BindingConfig) (rbacv1.RoleRef, error) {
+	ref := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: b.Role}
+	if preset, ok := kubernetes.PresetRoles[b.Role]; ok {
+		ref.Name = preset
+		return ref, nil
+	}
+
+	var namespaced bool
+	for _, role := range roles {
+		if role.Name != b.Role {
+			continue
+		}
+		if role.Namespace == "" {
+			return ref, nil
+		}
+		if role.Namespace == b.Namespace {
+			ref.Kind = "Role"
+			return ref, nil
+		}
+		namespaced = true
+	}
+	if namespaced {
+		return ref, fmt.Errorf("binding %s references role %s of another namespace", b.Name, b.Role)
+	}
+	return ref, nil
+}
+
+func bindingSubjects(b BindingConfig) ([]rbacv1.Subject, error) {
+	var subjects []rbacv1.Subject
+	for _, user := range b.Users {
+		subjects = append(subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: user})
+	}
+	for _, group := range b.Groups {
+		subjects = append(subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: group})
+	}
+	for _, sa := range b.ServiceAccounts {
+		namespace, name, found := strings.Cut(sa, "/")
+		if !found {
+			namespace, name = b.Namespace, sa
+		}
+		if namespace == "" || name == "" {
+			return nil, fmt.Errorf("binding %s service account %s requires a namespace/name", b.Name, sa)
+		}
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name})
+	}
+	return subjects, nil
+}
+
+func validateRBAC(c RBACConfig) error {
+	roles := make(map[string]bool)
+	for i, role := range c.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("rbac.roles[%d] requires a name", i)
+		}
+		if roles[role.Namespace+"/"+role.Name] {
+			return fmt.Errorf("rbac role %s is declared twice", role.Name)
+		}
+		roles[role.Namespace+"/"+role.Name] = true
+		if _, ok := kubernetes.PresetRoles[role.Name]; ok {
+			return fmt.Errorf("rbac role %s shadows a preset role", role.Name)
+		}
+		for _, rule := range role.Rules {
+			if len(rule.Verbs) == 0 {
+				return fmt.Errorf("rbac role %s has a rule without verbs", role.Name)
+			}
+		}
+	}
+	bindings := make(map[string]bool)
+	for i, b := range c.Bindings {
+		if b.Name == "" || b.Role == "" {
+			return fmt.Errorf("rbac.bindings[%d] requires a name and a role", i)
+		}
+		if bindings[b.Namespace+"/"+b.Name] {
+			return fmt.Errorf("rbac binding %s is declared twice", b.Name)
+		}
+		bindings[b.Namespace+"/"+b.Name] = true
+		if len(b.Users)+len(b.Groups)+len(b.ServiceAccounts) == 0 {
+			return fmt.Errorf("rbac binding %s requires users, groups or service accounts", b.Name)
+		}
+	}
+	_, _, err := rbacSpecs(c)
+	return err
+}
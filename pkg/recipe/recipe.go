@@ -1,5 +1,10 @@
 package recipe
 
+import (
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
 type ArgocdRepositoryType string
 
 const (
@@ -11,18 +16,169 @@ type Recipe struct {
 	Name       string `mapstructure:"name" json:"name" yaml:"name"`
 	Kubeconfig string `mapstructure:"kubeconfig" json:"kubeconfig" yaml:"kubeconfig"`
 	Debug      bool   `mapstructure:"debug" json:"debug" yaml:"debug"`
+	// Distribution is the Kubernetes distribution to install, k3s or rke2
+	Distribution string `mapstructure:"distribution" json:"distribution" yaml:"distribution"`
 
 	Node        Node              `mapstructure:"node" json:"node" yaml:"node"`
 	CertManager CertManagerConfig `mapstructure:"certManager" json:"certManager" yaml:"certManager"`
 	Traefik     TraefikConfig     `mapstructure:"traefik" json:"traefik" yaml:"traefik"`
 	K3s         K3sConfig         `mapstructure:"k3s" json:"k3s" yaml:"k3s"`
+	Rke2        Rke2Config        `mapstructure:"rke2" json:"rke2" yaml:"rke2"`
+	Helm        HelmConfig        `mapstructure:"helm" json:"helm" yaml:"helm"`
 	K9s         K9sConfig         `mapstructure:"k9s" json:"k9s" yaml:"k9s"`
 	Rancher     RancherConfig     `mapstructure:"rancher" json:"rancher" yaml:"rancher"`
 	ArgoCD      ArgoCDConfig      `mapstructure:"argocd" json:"argocd" yaml:"argocd"`
 	Secrets     SecretsConfig     `mapstructure:"secrets" json:"secrets" yaml:"secrets"`
 	Gitops      GitopsConfig      `mapstructure:"gitops" json:"gitops" yaml:"gitops"`
+	// Charts are additional helm charts installed after the components
+	Charts []ChartConfig `mapstructure:"charts" json:"charts" yaml:"charts"`
+	// Repositories are chart repositories referenced by name from components and charts
+	Repositories []RepositoryConfig `mapstructure:"repositories" json:"repositories" yaml:"repositories"`
+	// Namespaces are created with their baseline policies before secrets and components.
+	// They are read apart from viper, like chart values, as label keys hold dots.
+	Namespaces []NamespaceConfig `mapstructure:"-" json:"namespaces" yaml:"namespaces"`
+	// RBAC roles and bindings are applied after the namespaces
+	RBAC RBACConfig `mapstructure:"rbac" json:"rbac" yaml:"rbac"`
+	// SecretProviders configure the providers of secret references, e.g. hcv.secret/registry#password
+	SecretProviders SecretProvidersConfig `mapstructure:"secretProviders" json:"secretProviders" yaml:"secretProviders"`
+	// Prune deletes the objects hotpot created for the recipe that it no longer declares
+	Prune bool `mapstructure:"prune" json:"prune" yaml:"prune"`
 
+	Path         string        `mapstructure:"-" json:"-" yaml:"-"`
 	Dependencies *Dependencies `mapstructure:"-" json:"-" yaml:"-"`
+	// Plan prints the diff of every helm release instead of cooking
+	Plan bool `mapstructure:"-" json:"-" yaml:"-"`
+	// Confirm is asked before each helm release is installed or upgraded
+	Confirm ConfirmFunc `mapstructure:"-" json:"-" yaml:"-"`
+	// Revision identifies the cook run in the labels of the objects it applies
+	Revision string `mapstructure:"-" json:"-" yaml:"-"`
+
+	// skipped is set when a release is left as is, the objects of its
+	// component are not applied again and must not be pruned
+	skipped bool
+}
+
+type NamespaceConfig struct {
+	Name        string            `mapstructure:"name" json:"name" yaml:"name"`
+	Labels      map[string]string `mapstructure:"labels" json:"labels" yaml:"labels"`
+	Annotations map[string]string `mapstructure:"annotations" json:"annotations" yaml:"annotations"`
+	// PodSecurity is the Pod Security Admission level: privileged, baseline or restricted
+	PodSecurity string `mapstructure:"podSecurity" json:"podSecurity" yaml:"podSecurity"`
+	// Quota holds the hard limits of the namespace ResourceQuota, e.g. requests.cpu: "4"
+	Quota      v1.ResourceList  `mapstructure:"quota" json:"quota" yaml:"quota"`
+	LimitRange LimitRangeConfig `mapstructure:"limitRange" json:"limitRange" yaml:"limitRange"`
+	// DefaultDeny adds a NetworkPolicy denying all ingress traffic to the namespace
+	DefaultDeny bool `mapstructure:"defaultDeny" json:"defaultDeny" yaml:"defaultDeny"`
+}
+
+// LimitRangeConfig holds the container resource defaults and bounds of a namespace
+type LimitRangeConfig struct {
+	Default        v1.ResourceList `mapstructure:"default" json:"default" yaml:"default"`
+	DefaultRequest v1.ResourceList `mapstructure:"defaultRequest" json:"defaultRequest" yaml:"defaultRequest"`
+	Max            v1.ResourceList `mapstructure:"max" json:"max" yaml:"max"`
+	Min            v1.ResourceList `mapstructure:"min" json:"min" yaml:"min"`
+}
+
+type SecretProvidersConfig struct {
+	Vault      VaultProviderConfig      `mapstructure:"vault" json:"vault" yaml:"vault"`
+	Kubernetes KubernetesProviderConfig `mapstructure:"kubernetes" json:"kubernetes" yaml:"kubernetes"`
+	Aws        AwsProviderConfig        `mapstructure:"aws" json:"aws" yaml:"aws"`
+	Azure      AzureProviderConfig      `mapstructure:"azure" json:"azure" yaml:"azure"`
+	Gcp        GcpProviderConfig        `mapstructure:"gcp" json:"gcp" yaml:"gcp"`
+	Sops       SopsProviderConfig       `mapstructure:"sops" json:"sops" yaml:"sops"`
+	// Custom holds the entries of the providers added with secret.Register, by scheme
+	Custom map[string]map[string]interface{} `mapstructure:"-" json:"-" yaml:"-"`
+}
+
+// SopsProviderConfig configures the sops. provider, the default sops age identities and GnuPG keyring are also used
+type SopsProviderConfig struct {
+	AgeKeyFile string `mapstructure:"ageKeyFile" json:"ageKeyFile" yaml:"ageKeyFile"`
+	// PgpKeyFile holds armored or binary pgp private keys without passphrase
+	PgpKeyFile string `mapstructure:"pgpKeyFile" json:"pgpKeyFile" yaml:"pgpKeyFile"`
+}
+
+// AzureProviderConfig configures the azure. provider, the default azure credential chain is used without an authMethod
+type AzureProviderConfig struct {
+	// AuthMethod is servicePrincipal, managedIdentity or none for emulators
+	AuthMethod   string `mapstructure:"authMethod" json:"authMethod" yaml:"authMethod"`
+	TenantId     string `mapstructure:"tenantId" json:"tenantId" yaml:"tenantId"`
+	ClientId     string `mapstructure:"clientId" json:"clientId" yaml:"clientId"`
+	ClientSecret string `mapstructure:"clientSecret" json:"clientSecret" yaml:"clientSecret"`
+	// Endpoint replaces the key vault url, e.g. for an emulator
+	Endpoint string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+}
+
+// GcpProviderConfig configures the gcp. provider, application default credentials are used without an authMethod
+type GcpProviderConfig struct {
+	// AuthMethod is serviceAccount or none for emulators
+	AuthMethod      string `mapstructure:"authMethod" json:"authMethod" yaml:"authMethod"`
+	CredentialsFile string `mapstructure:"credentialsFile" json:"credentialsFile" yaml:"credentialsFile"`
+	Project         string `mapstructure:"project" json:"project" yaml:"project"`
+	// Endpoint replaces the secret manager url, e.g. for an emulator
+	Endpoint string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+}
+
+// AwsProviderConfig configures the aws. provider, credentials come from the standard aws chain
+type AwsProviderConfig struct {
+	Region  string `mapstructure:"region" json:"region" yaml:"region"`
+	Profile string `mapstructure:"profile" json:"profile" yaml:"profile"`
+	// Endpoint overrides the Secrets Manager and SSM endpoints, e.g. for LocalStack
+	Endpoint string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+}
+
+// KubernetesProviderConfig configures the k8s. provider, which reads the recipe cluster by default
+type KubernetesProviderConfig struct {
+	Kubeconfig string `mapstructure:"kubeconfig" json:"kubeconfig" yaml:"kubeconfig"`
+	Context    string `mapstructure:"context" json:"context" yaml:"context"`
+}
+
+// VaultProviderConfig configures the hcv. provider, empty fields fall back to the VAULT_* environment variables
+type VaultProviderConfig struct {
+	Address   string `mapstructure:"address" json:"address" yaml:"address"`
+	Namespace string `mapstructure:"namespace" json:"namespace" yaml:"namespace"`
+	// AuthMethod is token, approle or kubernetes
+	AuthMethod string `mapstructure:"authMethod" json:"authMethod" yaml:"authMethod"`
+	AuthMount  string `mapstructure:"authMount" json:"authMount" yaml:"authMount"`
+	Token      string `mapstructure:"token" json:"token" yaml:"token"`
+	RoleId     string `mapstructure:"roleId" json:"roleId" yaml:"roleId"`
+	SecretId   string `mapstructure:"secretId" json:"secretId" yaml:"secretId"`
+	Role       string `mapstructure:"role" json:"role" yaml:"role"`
+	JwtPath    string `mapstructure:"jwtPath" json:"jwtPath" yaml:"jwtPath"`
+	// KvVersion is 1 or 2, looked up for each mount when 0
+	KvVersion int    `mapstructure:"kvVersion" json:"kvVersion" yaml:"kvVersion"`
+	CaCert    string `mapstructure:"caCert" json:"caCert" yaml:"caCert"`
+}
+
+type RBACConfig struct {
+	Roles    []RoleConfig    `mapstructure:"roles" json:"roles" yaml:"roles"`
+	Bindings []BindingConfig `mapstructure:"bindings" json:"bindings" yaml:"bindings"`
+}
+
+// RoleConfig is a Role, or a ClusterRole when Namespace is empty
+type RoleConfig struct {
+	Name      string       `mapstructure:"name" json:"name" yaml:"name"`
+	Namespace string       `mapstructure:"namespace" json:"namespace" yaml:"namespace"`
+	Rules     []RuleConfig `mapstructure:"rules" json:"rules" yaml:"rules"`
+}
+
+type RuleConfig struct {
+	ApiGroups       []string `mapstructure:"apiGroups" json:"apiGroups" yaml:"apiGroups"`
+	Resources       []string `mapstructure:"resources" json:"resources" yaml:"resources"`
+	ResourceNames   []string `mapstructure:"resourceNames" json:"resourceNames" yaml:"resourceNames"`
+	NonResourceURLs []string `mapstructure:"nonResourceURLs" json:"nonResourceURLs" yaml:"nonResourceURLs"`
+	Verbs           []string `mapstructure:"verbs" json:"verbs" yaml:"verbs"`
+}
+
+// BindingConfig is a RoleBinding, or a ClusterRoleBinding when Namespace is empty
+type BindingConfig struct {
+	Name      string `mapstructure:"name" json:"name" yaml:"name"`
+	Namespace string `mapstructure:"namespace" json:"namespace" yaml:"namespace"`
+	// Role is a preset (namespace-admin, editor, read-only), a recipe role or an existing ClusterRole
+	Role   string   `mapstructure:"role" json:"role" yaml:"role"`
+	Users  []string `mapstructure:"users" json:"users" yaml:"users"`
+	Groups []string `mapstructure:"groups" json:"groups" yaml:"groups"`
+	// ServiceAccounts are namespace/name, or name in the binding namespace
+	ServiceAccounts []string `mapstructure:"serviceAccounts" json:"serviceAccounts" yaml:"serviceAccounts"`
 }
 
 type Node struct {
@@ -50,22 +206,100 @@ type Distro struct {
 }
 
 type K3sConfig struct {
-	Enabled                 bool     `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
-	Disable                 []string `mapstructure:"disable" json:"disable" yaml:"disable"`
-	Version                 string   `mapstructure:"version" json:"version" yaml:"version"`
-	TlsSan                  []string `mapstructure:"tlsSan" json:"tlsSan" yaml:"tlsSan"`
-	DataDir                 string   `mapstructure:"dataDir" json:"dataDir" yaml:"dataDir"`
-	DefaultLocalStoragePath string   `mapstructure:"defaultLocalStoragePath" json:"defaultLocalStoragePath" yaml:"defaultLocalStoragePath"`
-	WriteKubeconfigMode     string   `mapstructure:"writeKubeconfigMode" json:"writeKubeconfigMode" yaml:"writeKubeconfigMode"`
-	ResolvConfPath          string   `mapstructure:"resolvConfPath" json:"resolvConfPath" yaml:"resolvConfPath"`
-	IsHA                    bool     `mapstructure:"isHA" json:"isHA" yaml:"isHA"`
-	IsServer                bool     `mapstructure:"isServer" json:"isServer" yaml:"isServer"`
-	KubeApiAddress          string   `mapstructure:"kubeApiAddress" json:"kubeApiAddress" yaml:"kubeApiAddress"`
-	ClusterToken            string   `mapstructure:"clusterToken" json:"clusterToken" yaml:"clusterToken"`
-	HttpsListenPort         string   `mapstructure:"httpsListenPort" json:"httpsListenPort" yaml:"httpsListenPort"`
-	ExtraArgs               []string `mapstructure:"extraArgs" json:"extraArgs" yaml:"extraArgs"`
-	PurgeExisting           bool     `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
-	PurgeExtraDirs          []string `mapstructure:"purgeExtraDirs" json:"purgeExtraDirs" yaml:"purgeExtraDirs"`
+	Enabled                 bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Disable                 []string      `mapstructure:"disable" json:"disable" yaml:"disable"`
+	Version                 string        `mapstructure:"version" json:"version" yaml:"version"`
+	TlsSan                  []string      `mapstructure:"tlsSan" json:"tlsSan" yaml:"tlsSan"`
+	DataDir                 string        `mapstructure:"dataDir" json:"dataDir" yaml:"dataDir"`
+	DefaultLocalStoragePath string        `mapstructure:"defaultLocalStoragePath" json:"defaultLocalStoragePath" yaml:"defaultLocalStoragePath"`
+	WriteKubeconfigMode     string        `mapstructure:"writeKubeconfigMode" json:"writeKubeconfigMode" yaml:"writeKubeconfigMode"`
+	ResolvConfPath          string        `mapstructure:"resolvConfPath" json:"resolvConfPath" yaml:"resolvConfPath"`
+	IsHA                    bool          `mapstructure:"isHA" json:"isHA" yaml:"isHA"`
+	IsServer                bool          `mapstructure:"isServer" json:"isServer" yaml:"isServer"`
+	KubeApiAddress          string        `mapstructure:"kubeApiAddress" json:"kubeApiAddress" yaml:"kubeApiAddress"`
+	ClusterToken            string        `mapstructure:"clusterToken" json:"clusterToken" yaml:"clusterToken"`
+	HttpsListenPort         string        `mapstructure:"httpsListenPort" json:"httpsListenPort" yaml:"httpsListenPort"`
+	ExtraArgs               []string      `mapstructure:"extraArgs" json:"extraArgs" yaml:"extraArgs"`
+	PurgeExisting           bool          `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+	PurgeExtraDirs          []string      `mapstructure:"purgeExtraDirs" json:"purgeExtraDirs" yaml:"purgeExtraDirs"`
+	Registries              K3sRegistries `mapstructure:"registries" json:"registries" yaml:"registries"`
+	Snapshots               K3sSnapshots  `mapstructure:"snapshots" json:"snapshots" yaml:"snapshots"`
+	// InstallScriptSha256 pins the install script of a version hotpot has no sum for
+	InstallScriptSha256 string `mapstructure:"installScriptSha256" json:"installScriptSha256" yaml:"installScriptSha256"`
+}
+
+type K3sSnapshots struct {
+	Enabled   bool        `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Schedule  string      `mapstructure:"schedule" json:"schedule" yaml:"schedule"`
+	Retention int         `mapstructure:"retention" json:"retention" yaml:"retention"`
+	Dir       string      `mapstructure:"dir" json:"dir" yaml:"dir"`
+	Name      string      `mapstructure:"name" json:"name" yaml:"name"`
+	S3        SnapshotsS3 `mapstructure:"s3" json:"s3" yaml:"s3"`
+}
+
+type SnapshotsS3 struct {
+	Enabled       bool   `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Endpoint      string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+	EndpointCA    string `mapstructure:"endpointCA" json:"endpointCA" yaml:"endpointCA"`
+	Bucket        string `mapstructure:"bucket" json:"bucket" yaml:"bucket"`
+	Folder        string `mapstructure:"folder" json:"folder" yaml:"folder"`
+	Region        string `mapstructure:"region" json:"region" yaml:"region"`
+	AccessKey     string `mapstructure:"accessKey" json:"accessKey" yaml:"accessKey"`
+	SecretKey     string `mapstructure:"secretKey" json:"secretKey" yaml:"secretKey"`
+	Insecure      bool   `mapstructure:"insecure" json:"insecure" yaml:"insecure"`
+	SkipSSLVerify bool   `mapstructure:"skipSSLVerify" json:"skipSSLVerify" yaml:"skipSSLVerify"`
+}
+
+// Rke2Config holds the cluster settings of the rke2 distribution
+type Rke2Config struct {
+	Enabled             bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Disable             []string      `mapstructure:"disable" json:"disable" yaml:"disable"`
+	Version             string        `mapstructure:"version" json:"version" yaml:"version"`
+	TlsSan              []string      `mapstructure:"tlsSan" json:"tlsSan" yaml:"tlsSan"`
+	DataDir             string        `mapstructure:"dataDir" json:"dataDir" yaml:"dataDir"`
+	WriteKubeconfigMode string        `mapstructure:"writeKubeconfigMode" json:"writeKubeconfigMode" yaml:"writeKubeconfigMode"`
+	ResolvConfPath      string        `mapstructure:"resolvConfPath" json:"resolvConfPath" yaml:"resolvConfPath"`
+	PurgeExisting       bool          `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+	Registries          K3sRegistries `mapstructure:"registries" json:"registries" yaml:"registries"`
+	// Profile is the hardening profile, e.g. cis
+	Profile string `mapstructure:"profile" json:"profile" yaml:"profile"`
+	// InstallScriptSha256 pins the install script of a version hotpot has no sum for
+	InstallScriptSha256 string `mapstructure:"installScriptSha256" json:"installScriptSha256" yaml:"installScriptSha256"`
+}
+
+type K3sRegistries struct {
+	Mirrors []RegistryMirror `mapstructure:"mirrors" json:"mirrors" yaml:"mirrors"`
+	Configs []RegistryConfig `mapstructure:"configs" json:"configs" yaml:"configs"`
+}
+
+type RegistryMirror struct {
+	Registry  string            `mapstructure:"registry" json:"registry" yaml:"registry"`
+	Endpoints []string          `mapstructure:"endpoints" json:"endpoints" yaml:"endpoints"`
+	Rewrites  []RegistryRewrite `mapstructure:"rewrites" json:"rewrites" yaml:"rewrites"`
+}
+
+type RegistryRewrite struct {
+	Pattern     string `mapstructure:"pattern" json:"pattern" yaml:"pattern"`
+	Replacement string `mapstructure:"replacement" json:"replacement" yaml:"replacement"`
+}
+
+type RegistryConfig struct {
+	Registry string       `mapstructure:"registry" json:"registry" yaml:"registry"`
+	Auth     RegistryAuth `mapstructure:"auth" json:"auth" yaml:"auth"`
+	Tls      RegistryTls  `mapstructure:"tls" json:"tls" yaml:"tls"`
+}
+
+type RegistryAuth struct {
+	Username string `mapstructure:"username" json:"username" yaml:"username"`
+	Password string `mapstructure:"password" json:"password" yaml:"password"`
+	Token    string `mapstructure:"token" json:"token" yaml:"token"`
+}
+
+type RegistryTls struct {
+	CaFile             string `mapstructure:"caFile" json:"caFile" yaml:"caFile"`
+	CertFile           string `mapstructure:"certFile" json:"certFile" yaml:"certFile"`
+	KeyFile            string `mapstructure:"keyFile" json:"keyFile" yaml:"keyFile"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify" json:"insecureSkipVerify" yaml:"insecureSkipVerify"`
 }
 
 type CertManagerConfig struct {
@@ -92,7 +326,12 @@ type CertManagerConfig struct {
 	DnsOvhConsumerKey       string `mapstructure:"dnsOvhConsumerKey" json:"dnsOvhConsumerKey" yaml:"dnsOvhConsumerKey"`
 	DnsOvhZone              string `mapstructure:"dnsOvhZone" json:"dnsOvhZone" yaml:"dnsOvhZone"`
 
-	PurgeExisting bool `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+	Chart         ChartSource `mapstructure:"chart" json:"chart" yaml:"chart"`
+	PurgeExisting bool        `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+
+	// Values and ValuesFiles are deep-merged over the chart values hotpot generates
+	Values      map[string]interface{} `mapstructure:"-" json:"values" yaml:"values"`
+	ValuesFiles []string               `mapstructure:"valuesFiles" json:"valuesFiles" yaml:"valuesFiles"`
 }
 
 type TraefikConfig struct {
@@ -124,8 +363,13 @@ type TraefikConfig struct {
 	DefaultCertificateCert    string `mapstructure:"defaultCertificateCert" json:"defaultCertificateCert" yaml:"defaultCertificateCert"`
 	DefaultCertificateKey     string `mapstructure:"defaultCertificateKey" json:"defaultCertificateKey" yaml:"defaultCertificateKey"`
 
-	Debug         bool `mapstructure:"debug" json:"debug" yaml:"debug"`
-	PurgeExisting bool `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+	Chart         ChartSource `mapstructure:"chart" json:"chart" yaml:"chart"`
+	Debug         bool        `mapstructure:"debug" json:"debug" yaml:"debug"`
+	PurgeExisting bool        `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+
+	// Values and ValuesFiles are deep-merged over the chart values hotpot generates
+	Values      map[string]interface{} `mapstructure:"-" json:"values" yaml:"values"`
+	ValuesFiles []string               `mapstructure:"valuesFiles" json:"valuesFiles" yaml:"valuesFiles"`
 }
 
 type ArgoCDConfig struct {
@@ -135,6 +379,10 @@ type ArgoCDConfig struct {
 	AdminPassword       string `mapstructure:"adminPassword" json:"adminPassword" yaml:"adminPassword"`
 	AdminPasswordHashed bool   `mapstructure:"adminPasswordHashed" json:"adminPasswordHashed" yaml:"adminPasswordHashed"`
 	PurgeExisting       bool   `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
+
+	Chart       ChartSource            `mapstructure:"chart" json:"chart" yaml:"chart"`
+	Values      map[string]interface{} `mapstructure:"-" json:"values" yaml:"values"`
+	ValuesFiles []string               `mapstructure:"valuesFiles" json:"valuesFiles" yaml:"valuesFiles"`
 }
 
 type GitopsConfig struct {
@@ -142,6 +390,9 @@ type GitopsConfig struct {
 	PurgeExisting bool      `mapstructure:"purgeExisting" json:"purgeExisting" yaml:"purgeExisting"`
 	Projects      []Project `mapstructure:"projects" json:"projects" yaml:"projects"`
 	Clusters      []Cluster `mapstructure:"clusters" json:"clusters" yaml:"clusters"`
+	// Wait waits for the apps of each project to be synced and healthy, up to Timeout
+	Wait    bool          `mapstructure:"wait" json:"wait" yaml:"wait"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout" yaml:"timeout"`
 }
 
 type Project struct {
@@ -194,8 +445,9 @@ type ArgocdRepository struct {
 type ArgocdRepositoryCredentials struct {
 	Username string `mapstructure:"username" json:"username" yaml:"username"`
 	Password string `mapstructure:"password" json:"password" yaml:"password"`
-	UseVault bool   `mapstructure:"useVault" json:"useVault" yaml:"useVault"`
-	UseEnv   bool   `mapstructure:"useEnv" json:"useEnv" yaml:"useEnv"`
+	// UseVault reads the username and password from vault, given as paths without the hcv. prefix
+	UseVault bool `mapstructure:"useVault" json:"useVault" yaml:"useVault"`
+	UseEnv   bool `mapstructure:"useEnv" json:"useEnv" yaml:"useEnv"`
 }
 
 type SecretsConfig struct {
@@ -230,16 +482,76 @@ type ContainerRegistryCredentials struct {
 	Password   string   `mapstructure:"password" json:"password" yaml:"password"`
 	Url        string   `mapstructure:"url" json:"url" yaml:"url"`
 	Namespaces []string `mapstructure:"namespaces" json:"namespaces" yaml:"namespaces"`
-	UseVault   bool     `mapstructure:"useVault" json:"useVault" yaml:"useVault"`
-	UseEnv     bool     `mapstructure:"useEnv" json:"useEnv" yaml:"useEnv"`
+	// UseVault reads the username and password from vault, given as paths without the hcv. prefix
+	UseVault bool `mapstructure:"useVault" json:"useVault" yaml:"useVault"`
+	UseEnv   bool `mapstructure:"useEnv" json:"useEnv" yaml:"useEnv"`
 }
 
 type RancherConfig struct {
 	Enabled  bool   `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
 	Version  string `mapstructure:"version" json:"version" yaml:"version"`
 	Hostname string `mapstructure:"hostname" json:"hostname" yaml:"hostname"`
+
+	Chart       ChartSource            `mapstructure:"chart" json:"chart" yaml:"chart"`
+	Values      map[string]interface{} `mapstructure:"-" json:"values" yaml:"values"`
+	ValuesFiles []string               `mapstructure:"valuesFiles" json:"valuesFiles" yaml:"valuesFiles"`
+}
+
+// ChartSource pulls a component chart from ref, e.g. oci://registry.example.com/charts/traefik,
+// or from a mirror of its public helm repository
+type ChartSource struct {
+	Ref      string        `mapstructure:"ref" json:"ref" yaml:"ref"`
+	Version  string        `mapstructure:"version" json:"version" yaml:"version"`
+	Registry ChartRegistry `mapstructure:"registry" json:"registry" yaml:"registry"`
+	// Repository names the recipe repository to use when ref is empty
+	Repository string `mapstructure:"repository" json:"repository" yaml:"repository"`
+}
+
+// RepositoryConfig is a classic helm chart repository.
+// Username and password accept secret references.
+type RepositoryConfig struct {
+	Name               string      `mapstructure:"name" json:"name" yaml:"name"`
+	Url                string      `mapstructure:"url" json:"url" yaml:"url"`
+	Username           string      `mapstructure:"username" json:"username" yaml:"username"`
+	Password           string      `mapstructure:"password" json:"password" yaml:"password"`
+	Tls                RegistryTls `mapstructure:"tls" json:"tls" yaml:"tls"`
+	PassCredentialsAll bool        `mapstructure:"passCredentialsAll" json:"passCredentialsAll" yaml:"passCredentialsAll"`
+}
+
+// ChartRegistry holds the credentials of an OCI chart registry.
+// Username and password accept secret references.
+type ChartRegistry struct {
+	Username  string      `mapstructure:"username" json:"username" yaml:"username"`
+	Password  string      `mapstructure:"password" json:"password" yaml:"password"`
+	Tls       RegistryTls `mapstructure:"tls" json:"tls" yaml:"tls"`
+	PlainHttp bool        `mapstructure:"plainHttp" json:"plainHttp" yaml:"plainHttp"`
+}
+
+// ChartConfig is a helm chart installed from a classic repository
+// or, when chart starts with oci://, from an OCI registry.
+// RepoName may name a recipe repository, in which case repoUrl is not needed.
+type ChartConfig struct {
+	Name        string        `mapstructure:"name" json:"name" yaml:"name"`
+	Namespace   string        `mapstructure:"namespace" json:"namespace" yaml:"namespace"`
+	Chart       string        `mapstructure:"chart" json:"chart" yaml:"chart"`
+	Version     string        `mapstructure:"version" json:"version" yaml:"version"`
+	RepoName    string        `mapstructure:"repoName" json:"repoName" yaml:"repoName"`
+	RepoUrl     string        `mapstructure:"repoUrl" json:"repoUrl" yaml:"repoUrl"`
+	ValuesFiles []string      `mapstructure:"valuesFiles" json:"valuesFiles" yaml:"valuesFiles"`
+	Registry    ChartRegistry `mapstructure:"registry" json:"registry" yaml:"registry"`
+	// Values are deep-merged over the values files
+	Values map[string]interface{} `mapstructure:"-" json:"values" yaml:"values"`
+	// Atomic rolls a failed upgrade back to the last deployed revision, implies wait
+	Atomic  bool          `mapstructure:"atomic" json:"atomic" yaml:"atomic"`
+	Wait    bool          `mapstructure:"wait" json:"wait" yaml:"wait"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout" yaml:"timeout"`
 }
 
 type K9sConfig struct {
-	Enabled bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Enabled bool   `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Version string `mapstructure:"version" json:"version" yaml:"version"`
+}
+
+type HelmConfig struct {
+	Version string `mapstructure:"version" json:"version" yaml:"version"`
 }
@@ -2,7 +2,20 @@ package recipe
 
 import (
 	"errors"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"github.com/zcubbs/hotpot/pkg/secret"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCheckPrerequisites(t *testing.T) {
@@ -64,11 +77,11 @@ func TestCheckPrerequisites(t *testing.T) {
 	}
 }
 
-func TestInstallK3s(t *testing.T) {
+func TestInstallDistribution(t *testing.T) {
 	tests := []struct {
 		name    string
 		recipe  *Recipe
-		k3sMgr  *mockK3sManager
+		dist    *mockDistribution
 		helmMgr *mockHelmManager
 		fs      *mockFileSystem
 		wantErr bool
@@ -80,7 +93,7 @@ func TestInstallK3s(t *testing.T) {
 					Enabled: true,
 				},
 			},
-			k3sMgr:  &mockK3sManager{},
+			dist:    &mockDistribution{},
 			helmMgr: &mockHelmManager{},
 			fs:      &mockFileSystem{},
 			wantErr: false,
@@ -92,7 +105,7 @@ func TestInstallK3s(t *testing.T) {
 					Enabled: true,
 				},
 			},
-			k3sMgr:  &mockK3sManager{installErr: errors.New("install failed")},
+			dist:    &mockDistribution{installErr: errors.New("install failed")},
 			helmMgr: &mockHelmManager{},
 			fs:      &mockFileSystem{},
 			wantErr: true,
@@ -104,7 +117,7 @@ func TestInstallK3s(t *testing.T) {
 					Enabled: true,
 				},
 			},
-			k3sMgr:  &mockK3sManager{},
+			dist:    &mockDistribution{},
 			helmMgr: &mockHelmManager{isInstalledResult: false, installErr: errors.New("helm install failed")},
 			fs:      &mockFileSystem{},
 			wantErr: true,
@@ -113,9 +126,9 @@ func TestInstallK3s(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := installK3s(tt.recipe, tt.k3sMgr, tt.helmMgr, tt.fs)
+			err := installDistribution(tt.recipe, tt.dist, tt.helmMgr, tt.fs)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("installK3s() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("installDistribution() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -315,3 +328,1086 @@ func TestInstallK9s(t *testing.T) {
 		})
 	}
 }
+
+func TestPinnedVersions(t *testing.T) {
+	r := &Recipe{
+		K3s:  K3sConfig{Enabled: true},
+		Helm: HelmConfig{Version: "v3.15.0"},
+		K9s:  K9sConfig{Enabled: true, Version: "v0.32.5"},
+	}
+	helmMgr := &mockHelmManager{}
+	k9sMgr := &mockK9sManager{}
+
+	if err := installDistribution(r, &mockDistribution{}, helmMgr, &mockFileSystem{}); err != nil {
+		t.Fatalf("installDistribution() error = %v", err)
+	}
+	if err := installK9s(r, k9sMgr); err != nil {
+		t.Fatalf("installK9s() error = %v", err)
+	}
+
+	if helmMgr.installedVersion != "v3.15.0" {
+		t.Errorf("helm version = %q, want %q", helmMgr.installedVersion, "v3.15.0")
+	}
+	if k9sMgr.installedVersion != "v0.32.5" {
+		t.Errorf("k9s version = %q, want %q", k9sMgr.installedVersion, "v0.32.5")
+	}
+
+	// an installed helm is replaced only when it differs from the pin
+	for current, want := range map[string]string{"v3.14.2": "v3.15.0", "v3.15.0": "", "3.15.0": ""} {
+		helmMgr := &mockHelmManager{isInstalledResult: true, currentVersion: current}
+		if err := installDistribution(r, &mockDistribution{}, helmMgr, &mockFileSystem{}); err != nil {
+			t.Fatalf("installDistribution() error = %v", err)
+		}
+		if helmMgr.installedVersion != want {
+			t.Errorf("helm %s: installed %q, want %q", current, helmMgr.installedVersion, want)
+		}
+	}
+}
+
+func TestValidateDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  *Recipe
+		wantErr bool
+	}{
+		{
+			name:    "k3s snapshots",
+			recipe:  &Recipe{Distribution: "k3s", K3s: K3sConfig{Snapshots: K3sSnapshots{Enabled: true}}},
+			wantErr: false,
+		},
+		{
+			name:    "rke2 without snapshots",
+			recipe:  &Recipe{Distribution: "rke2"},
+			wantErr: false,
+		},
+		{
+			name:    "rke2 configured in the k3s section",
+			recipe:  &Recipe{Distribution: "rke2", K3s: K3sConfig{Enabled: true}},
+			wantErr: true,
+		},
+		{
+			name:    "rke2 section without the rke2 distribution",
+			recipe:  &Recipe{Distribution: "k3s", Rke2: Rke2Config{Enabled: true}},
+			wantErr: true,
+		},
+		{
+			name:    "rke2 snapshots",
+			recipe:  &Recipe{Distribution: "rke2", K3s: K3sConfig{Snapshots: K3sSnapshots{Enabled: true}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.recipe)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRke2Config(t *testing.T) {
+	dist := &mockDistribution{}
+	r := &Recipe{
+		Distribution: "rke2",
+		Rke2:         Rke2Config{Enabled: true, Profile: "cis", TlsSan: []string{"k8s.example.com"}, InstallScriptSha256: "abc"},
+		K3s:          K3sConfig{TlsSan: []string{"k3s.example.com", "other.example.com"}, HttpsListenPort: "7443"},
+	}
+
+	if err := installDistribution(r, dist, &mockHelmManager{isInstalledResult: true}, &mockFileSystem{}); err != nil {
+		t.Fatalf("installDistribution() error = %v", err)
+	}
+	if dist.installed.Profile != "cis" || len(dist.installed.TlsSan) != 1 || dist.installed.InstallScriptSha256 != "abc" {
+		t.Errorf("installDistribution() config = %+v, want the rke2 section", dist.installed)
+	}
+	if dist.installed.HttpsListenPort != "" {
+		t.Errorf("installDistribution() config = %+v, read the k3s section", dist.installed)
+	}
+}
+
+func TestLoadRke2Defaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recipe.yaml")
+	content := `
+distribution: rke2
+rke2:
+  enabled: true
+  version: v1.31.4+rke2r1
+traefik:
+  enabled: true
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !distributionEnabled(r) {
+		t.Error("distributionEnabled() = false, want the rke2 section enabled")
+	}
+	if !slices.Contains(r.Rke2.Disable, "rke2-ingress-nginx") || slices.Contains(r.Rke2.Disable, "traefik") {
+		t.Errorf("rke2.disable = %v, want rke2-ingress-nginx disabled next to traefik", r.Rke2.Disable)
+	}
+	if err := validate(r); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+}
+
+func TestDeprecatedK3sManager(t *testing.T) {
+	k3sMgr := &mockK3sManager{}
+	deps := Dependencies{K3s: k3sMgr}
+	r := &Recipe{Distribution: "k3s", K3s: K3sConfig{Enabled: true, Version: "v1.31.4+k3s1"}}
+
+	if err := selectDistribution(r, &deps); err != nil {
+		t.Fatalf("selectDistribution() error = %v", err)
+	}
+	if deps.Distribution.Name() != "k3s" || r.Kubeconfig != "/etc/rancher/k3s/k3s.yaml" {
+		t.Errorf("selectDistribution() = %s %s, want k3s", deps.Distribution.Name(), r.Kubeconfig)
+	}
+	if err := installDistribution(r, deps.Distribution, &mockHelmManager{isInstalledResult: true}, &mockFileSystem{}); err != nil {
+		t.Fatalf("installDistribution() error = %v", err)
+	}
+	if k3sMgr.installed.Version != "v1.31.4+k3s1" {
+		t.Errorf("K3sManager.Install() config = %+v", k3sMgr.installed)
+	}
+
+	// the k3s manager is left out of other distributions
+	deps = Dependencies{K3s: k3sMgr}
+	if err := selectDistribution(&Recipe{Distribution: "rke2"}, &deps); err != nil {
+		t.Fatalf("selectDistribution() error = %v", err)
+	}
+	if deps.Distribution.Name() != "rke2" {
+		t.Errorf("selectDistribution() = %s, want rke2", deps.Distribution.Name())
+	}
+}
+
+func TestGitopsWait(t *testing.T) {
+	apps := []App{
+		{Name: "hub", Namespace: "hub", Repo: "https://git.example.com/hub.git", Path: "."},
+		{Name: "skipped", Namespace: "hub"},
+		{Name: "tools", Namespace: "tools", Repo: "https://git.example.com/tools.git", Path: "."},
+	}
+
+	for _, wait := range []bool{true, false} {
+		argo := &mockArgoCDManager{}
+		r := &Recipe{
+			Gitops:       GitopsConfig{Enabled: true, Wait: wait, Timeout: time.Minute},
+			Dependencies: &Dependencies{ArgoCD: argo},
+		}
+		if err := configureGitopsApps(r, "default", "argocd", apps); err != nil {
+			t.Fatalf("configureGitopsApps() error = %v", err)
+		}
+		want := []string(nil)
+		if wait {
+			want = []string{"hub", "tools"}
+		}
+		if !slices.Equal(argo.awaited, want) {
+			t.Errorf("wait %v: awaited %v, want %v", wait, argo.awaited, want)
+		}
+	}
+
+	argo := &mockArgoCDManager{waitErr: errors.New("app hub is degraded")}
+	r := &Recipe{Gitops: GitopsConfig{Wait: true}, Dependencies: &Dependencies{ArgoCD: argo}}
+	if err := configureGitopsApps(r, "default", "argocd", apps); err == nil {
+		t.Error("configureGitopsApps() succeeded with unhealthy apps")
+	}
+}
+
+func TestGitopsRepositories(t *testing.T) {
+	// vault serves the git credentials at secret/git of a kv v2 mount
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.URL.Path != "/v1/secret/data/git" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"username":"git-robot","password":"git-p4ss"}}}`))
+	}))
+	defer vault.Close()
+	secret.ConfigureVault(secret.VaultConfig{Address: vault.URL, Token: "root", KvVersion: 2})
+	defer secret.ConfigureVault(secret.VaultConfig{})
+	t.Setenv("HOTPOT_GIT_TOKEN", "ghp")
+
+	argo := &mockArgoCDManager{}
+	r := &Recipe{Dependencies: &Dependencies{ArgoCD: argo}}
+	repos := []ArgocdRepository{
+		{Name: "vault", Url: "https://git.example.com/hub.git", Credentials: ArgocdRepositoryCredentials{
+			Username: "secret/git#username", Password: "secret/git#password", UseVault: true,
+		}},
+		{Name: "env", Url: "https://github.com/example/tools.git", Credentials: ArgocdRepositoryCredentials{
+			Username: "x-access-token", Password: "env.HOTPOT_GIT_TOKEN",
+		}},
+		{Name: "missing", Url: "https://git.example.com/missing.git", Credentials: ArgocdRepositoryCredentials{
+			Username: "secret/missing#username", Password: "secret/missing#password", UseVault: true,
+		}},
+		{Name: "public", Url: "https://github.com/example/public.git"},
+	}
+	if err := configureGitopsRepos(r, "argocd", repos); err != nil {
+		t.Fatalf("configureGitopsRepos() error = %v", err)
+	}
+
+	want := map[string][2]string{
+		"vault":  {"git-robot", "git-p4ss"},
+		"env":    {"x-access-token", "ghp"},
+		"public": {"", ""},
+	}
+	if len(argo.repositories) != len(want) {
+		t.Fatalf("repositories = %+v, want %d, the missing credentials skipped", argo.repositories, len(want))
+	}
+	for _, repo := range argo.repositories {
+		if got := [2]string{repo.Username, repo.Password}; got != want[repo.Name] {
+			t.Errorf("repository %s credentials = %v, want %v", repo.Name, got, want[repo.Name])
+		}
+	}
+}
+
+func TestK3sRegistries(t *testing.T) {
+	registries := k3sRegistries(K3sRegistries{
+		Mirrors: []RegistryMirror{
+			{
+				Registry:  "docker.io",
+				Endpoints: []string{"https://mirror.example.com"},
+				Rewrites:  []RegistryRewrite{{Pattern: "^rancher/(.*)", Replacement: "mirror/rancher/$1"}},
+			},
+		},
+		Configs: []RegistryConfig{
+			{
+				Registry: "mirror.example.com",
+				Auth:     RegistryAuth{Username: "user", Password: "pass"},
+				Tls:      RegistryTls{CaFile: "/etc/ssl/ca.pem"},
+			},
+		},
+	})
+
+	if len(registries.Mirrors) != 1 || registries.Mirrors[0].Rewrite["^rancher/(.*)"] != "mirror/rancher/$1" {
+		t.Errorf("k3sRegistries() mirrors = %+v", registries.Mirrors)
+	}
+	if len(registries.Configs) != 1 || registries.Configs[0].CaFile != "/etc/ssl/ca.pem" || registries.Configs[0].Password != "pass" {
+		t.Errorf("k3sRegistries() configs = %+v", registries.Configs)
+	}
+}
+
+func TestK3sSnapshotConfig(t *testing.T) {
+	cfg := K3sSnapshotConfig(&Recipe{
+		K3s: K3sConfig{
+			DataDir: "/data/k3s",
+			Snapshots: K3sSnapshots{
+				Retention: 3,
+				Dir:       "/backups",
+				S3:        SnapshotsS3{Enabled: true, Bucket: "snapshots", Endpoint: "http://127.0.0.1:9000"},
+			},
+		},
+	})
+
+	if cfg.DataDir != "/data/k3s" || cfg.Dir != "/backups" || cfg.Retention != 3 {
+		t.Errorf("K3sSnapshotConfig() = %+v", cfg)
+	}
+	if !cfg.S3.Enabled || cfg.S3.Bucket != "snapshots" || cfg.S3.Endpoint != "http://127.0.0.1:9000" {
+		t.Errorf("K3sSnapshotConfig() s3 = %+v", cfg.S3)
+	}
+}
+
+func TestSnapshotCronJob(t *testing.T) {
+	got := snapshotCronJob("0 */12 * * *", "/usr/local/bin/hotpot", "/etc/hotpot/recipe.yaml")
+	want := "0 */12 * * * '/usr/local/bin/hotpot' snapshot save -r '/etc/hotpot/recipe.yaml' --prune # hotpot-snapshot"
+	if got != want {
+		t.Errorf("snapshotCronJob() = %q, want %q", got, want)
+	}
+
+	got = snapshotCronJob("@daily", "/opt/my tools/hotpot", "/etc/hotpot/ops' 100%.yaml")
+	want = `@daily '/opt/my tools/hotpot' snapshot save -r '/etc/hotpot/ops'\'' 100\%.yaml' --prune # hotpot-snapshot`
+	if got != want {
+		t.Errorf("snapshotCronJob() = %q, want %q", got, want)
+	}
+}
+
+func TestChartSource(t *testing.T) {
+	traefikMgr := &mockTraefikManager{}
+	r := &Recipe{Traefik: TraefikConfig{Chart: ChartSource{
+		Ref:      "oci://registry.example.com/charts/traefik",
+		Version:  "33.2.1",
+		Registry: ChartRegistry{Username: "robot", Password: "env.REGISTRY_TOKEN", Tls: RegistryTls{CaFile: "/etc/ssl/ca.pem"}},
+	}}}
+
+	if err := installTraefik(r, traefikMgr); err != nil {
+		t.Fatalf("installTraefik() error = %v", err)
+	}
+
+	chart := traefikMgr.installed.Chart.Apply(helm.Chart{ChartName: "traefik", RepoName: "traefik"})
+	if chart.Ref != "oci://registry.example.com/charts/traefik" || chart.Version != "33.2.1" {
+		t.Errorf("chart = %+v", chart)
+	}
+	if chart.Registry.Password != "env.REGISTRY_TOKEN" || chart.Registry.CaFile != "/etc/ssl/ca.pem" {
+		t.Errorf("chart registry = %+v", chart.Registry)
+	}
+}
+
+func TestInstallCharts(t *testing.T) {
+	helmMgr := &mockHelmManager{}
+	r := &Recipe{Charts: []ChartConfig{
+		{Name: "internal-app", Namespace: "apps", Chart: "oci://registry.example.com/charts/internal-app", Version: "1.2.0"},
+		{Name: "podinfo", Namespace: "apps", Chart: "podinfo", RepoUrl: "https://stefanprodan.github.io/podinfo"},
+	}}
+
+	if err := installCharts(r, helmMgr); err != nil {
+		t.Fatalf("installCharts() error = %v", err)
+	}
+	if len(helmMgr.charts) != 2 {
+		t.Fatalf("installed %d charts, want 2", len(helmMgr.charts))
+	}
+	if oci := helmMgr.charts[0]; oci.Ref != "oci://registry.example.com/charts/internal-app" || oci.Version != "1.2.0" {
+		t.Errorf("oci chart = %+v", oci)
+	}
+	if repo := helmMgr.charts[1]; repo.Ref != "" || repo.RepoName != "podinfo" || helmMgr.repos[1].Url != "https://stefanprodan.github.io/podinfo" {
+		t.Errorf("repo chart = %+v", repo)
+	}
+}
+
+func TestChartValues(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "recipe.yaml")
+	content := `
+traefik:
+  enabled: true
+  valuesFiles:
+    - traefik-values.yaml
+  values:
+    deployment:
+      replicas: 2
+    nodeSelector:
+      kubernetes.io/os: linux
+charts:
+  - name: podinfo
+    namespace: apps
+    chart: podinfo
+    repoUrl: https://stefanprodan.github.io/podinfo
+    values:
+      replicaCount: 3
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	overrides := traefikValues(r).Overrides
+	if want := filepath.Join(dir, "traefik-values.yaml"); len(overrides.ValuesFiles) != 1 || overrides.ValuesFiles[0] != want {
+		t.Errorf("traefik values files = %v, want [%s]", overrides.ValuesFiles, want)
+	}
+	nodeSelector, _ := overrides.Values["nodeSelector"].(map[string]interface{})
+	if nodeSelector["kubernetes.io/os"] != "linux" {
+		t.Errorf("traefik values = %v, want nodeSelector kubernetes.io/os=linux", overrides.Values)
+	}
+
+	chart := overrides.Apply(helm.Chart{
+		ValuesFiles: []string{"generated.yaml"},
+		Overrides:   helm.Values{"deployment": map[string]interface{}{"replicas": 1, "kind": "Deployment"}},
+	})
+	if len(chart.ValuesFiles) != 2 || chart.ValuesFiles[0] != "generated.yaml" {
+		t.Errorf("chart values files = %v, want generated.yaml first", chart.ValuesFiles)
+	}
+	deployment, _ := chart.Overrides["deployment"].(map[string]interface{})
+	if deployment["replicas"] != float64(2) || deployment["kind"] != "Deployment" {
+		t.Errorf("merged deployment values = %v", deployment)
+	}
+
+	if got := helmChart(r, r.Charts[0]).Overrides["replicaCount"]; got != float64(3) {
+		t.Errorf("chart replicaCount = %v, want 3", got)
+	}
+}
+
+func TestChartRepositories(t *testing.T) {
+	helmMgr := &mockHelmManager{}
+	traefikMgr := &mockTraefikManager{}
+	r := &Recipe{
+		Repositories: []RepositoryConfig{{
+			Name:     "nexus-traefik",
+			Url:      "https://nexus.example.com/repository/traefik",
+			Username: "hotpot",
+			Password: "env.NEXUS_PASSWORD",
+			Tls:      RegistryTls{CaFile: "/etc/ssl/corp-ca.pem"},
+		}},
+		Traefik: TraefikConfig{Chart: ChartSource{Repository: "nexus-traefik", Version: "33.2.1"}},
+		Charts:  []ChartConfig{{Name: "traefik-extra", Namespace: "traefik", Chart: "traefik", RepoName: "nexus-traefik"}},
+	}
+
+	if err := validate(r); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if err := installTraefik(r, traefikMgr); err != nil {
+		t.Fatalf("installTraefik() error = %v", err)
+	}
+	source := traefikMgr.installed.Chart
+	chart := source.Apply(helm.Chart{ChartName: "traefik", RepoName: "traefik"})
+	if chart.RepoName != "nexus-traefik" || chart.Version != "33.2.1" {
+		t.Errorf("chart = %+v", chart)
+	}
+	if repo := source.RepositoryOr("traefik", "https://traefik.github.io/charts"); repo.Url != "https://nexus.example.com/repository/traefik" || repo.Options.Password != "env.NEXUS_PASSWORD" || repo.Options.CaFile != "/etc/ssl/corp-ca.pem" {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	if err := installCharts(r, helmMgr); err != nil {
+		t.Fatalf("installCharts() error = %v", err)
+	}
+	if repo := helmMgr.repos[0]; repo.Name != "nexus-traefik" || repo.Options.Username != "hotpot" {
+		t.Errorf("chart repository = %+v", repo)
+	}
+
+	r.Rancher.Chart.Repository = "rancher-mirror"
+	if err := validate(r); err == nil {
+		t.Error("validate() accepted an unknown component repository")
+	}
+}
+
+func TestValidateCharts(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   ChartConfig
+		wantErr bool
+	}{
+		{
+			name:    "oci chart",
+			chart:   ChartConfig{Name: "app", Namespace: "apps", Chart: "oci://registry.example.com/charts/app"},
+			wantErr: false,
+		},
+		{
+			name:    "repo chart",
+			chart:   ChartConfig{Name: "app", Namespace: "apps", Chart: "app", RepoUrl: "https://charts.example.com"},
+			wantErr: false,
+		},
+		{
+			name:    "repo chart without url",
+			chart:   ChartConfig{Name: "app", Namespace: "apps", Chart: "app"},
+			wantErr: true,
+		},
+		{
+			name:    "recipe repository chart",
+			chart:   ChartConfig{Name: "app", Namespace: "apps", Chart: "app", RepoName: "nexus"},
+			wantErr: false,
+		},
+		{
+			name:    "unknown repository chart",
+			chart:   ChartConfig{Name: "app", Namespace: "apps", Chart: "app", RepoName: "jetstack"},
+			wantErr: true,
+		},
+		{
+			name:    "missing namespace",
+			chart:   ChartConfig{Name: "app", Chart: "oci://registry.example.com/charts/app"},
+			wantErr: true,
+		},
+	}
+
+	repos := []RepositoryConfig{{Name: "nexus", Url: "https://nexus.example.com/repository/helm"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(&Recipe{Distribution: "k3s", Charts: []ChartConfig{tt.chart}, Repositories: repos})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanned(t *testing.T) {
+	tests := []struct {
+		name        string
+		plan        bool
+		confirm     *bool
+		diff        string
+		wantInstall bool
+		wantAsked   bool
+	}{
+		{name: "no plan", wantInstall: true},
+		{name: "plan", plan: true, diff: "~ Deployment traefik/traefik\n", wantInstall: false},
+		{name: "confirm accepted", confirm: ptr(true), diff: "~ Deployment traefik/traefik\n", wantInstall: true, wantAsked: true},
+		{name: "confirm declined", confirm: ptr(false), diff: "~ Deployment traefik/traefik\n", wantInstall: false, wantAsked: true},
+		{name: "confirm without changes", confirm: ptr(false), diff: "", wantInstall: true, wantAsked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installed, asked := false, false
+			r := &Recipe{Plan: tt.plan}
+			if tt.confirm != nil {
+				r.Confirm = func(_, _ string) bool {
+					asked = true
+					return *tt.confirm
+				}
+			}
+
+			err := planned("traefik",
+				func(*Recipe) (string, error) { return tt.diff, nil },
+				func(*Recipe) error { installed = true; return nil },
+			)(r)
+			if err != nil {
+				t.Fatalf("planned() error = %v", err)
+			}
+			if installed != tt.wantInstall || asked != tt.wantAsked {
+				t.Errorf("installed = %v, asked = %v, want %v, %v", installed, asked, tt.wantInstall, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestPlanSkipsSteps(t *testing.T) {
+	var ran []string
+	r := &Recipe{Plan: true}
+	err := add(r,
+		step{f: func(*Recipe) error { ran = append(ran, "k3s"); return nil }, c: true},
+		step{f: func(*Recipe) error { ran = append(ran, "traefik"); return nil }, c: true, p: true},
+	)
+	if err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "traefik" {
+		t.Errorf("ran = %v, want [traefik]", ran)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestStatus(t *testing.T) {
+	deployed := func(name string) *helm.ReleaseStatus {
+		return &helm.ReleaseStatus{Name: name, Status: "deployed", Revision: 1}
+	}
+	healthy := func() *mockInspector {
+		return &mockInspector{
+			ready: true,
+			releases: map[string]*helm.ReleaseStatus{
+				"cert-manager": deployed("cert-manager"),
+				"traefik":      deployed("traefik"),
+				"argo-cd":      deployed("argo-cd"),
+			},
+			deployments: map[string][]kubernetes.DeploymentStatus{
+				"traefik": {{Name: "traefik", Ready: 1, Replicas: 1}},
+			},
+			issuers:      []certmanager.ClusterIssuerStatus{{Name: "letsencrypt", Ready: true}},
+			applications: []argocd.ApplicationStatus{{Name: "hub", Sync: "Synced", Health: "Healthy"}},
+		}
+	}
+	r := &Recipe{
+		CertManager: CertManagerConfig{Enabled: true},
+		Traefik:     TraefikConfig{Enabled: true},
+		ArgoCD:      ArgoCDConfig{Enabled: true},
+		Gitops:      GitopsConfig{Enabled: true},
+	}
+
+	tests := []struct {
+		name      string
+		inspector func() *mockInspector
+		unhealthy string
+	}{
+		{
+			name:      "healthy",
+			inspector: healthy,
+		},
+		{
+			name: "failed release",
+			inspector: func() *mockInspector {
+				m := healthy()
+				m.releases["traefik"] = &helm.ReleaseStatus{Name: "traefik", Status: "failed"}
+				return m
+			},
+			unhealthy: "traefik",
+		},
+		{
+			name: "missing release",
+			inspector: func() *mockInspector {
+				m := healthy()
+				delete(m.releases, "argo-cd")
+				return m
+			},
+			unhealthy: "argocd",
+		},
+		{
+			name: "unready deployment",
+			inspector: func() *mockInspector {
+				m := healthy()
+				m.deployments["traefik"][0].Ready = 0
+				return m
+			},
+			unhealthy: "traefik",
+		},
+		{
+			name: "unready issuer",
+			inspector: func() *mockInspector {
+				m := healthy()
+				m.issuers[0].Ready = false
+				return m
+			},
+			unhealthy: "cert-manager",
+		},
+		{
+			name: "degraded application",
+			inspector: func() *mockInspector {
+				m := healthy()
+				m.applications[0].Health = "Degraded"
+				return m
+			},
+			unhealthy: "argocd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := inspect(r, &mockDistribution{}, tt.inspector())
+			if len(report.Components) != 3 || report.Syncd != "active" {
+				t.Fatalf("report = %+v", report)
+			}
+			for _, c := range report.Components {
+				if c.Healthy == (c.Name == tt.unhealthy) {
+					t.Errorf("%s healthy = %v", c.Name, c.Healthy)
+				}
+			}
+			if report.Healthy() != (tt.unhealthy == "") {
+				t.Errorf("Healthy() = %v", report.Healthy())
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recipe.yaml")
+	content := `
+certManager:
+  dnsOvhApplicationKey: abcdef
+  dnsOvhApplicationSecret: env.OVH_SECRET
+traefik:
+  defaultCertificateKey: ""
+  values:
+    tolerations:
+      - key: node-role.kubernetes.io/control-plane
+repositories:
+  - name: nexus
+    username: hotpot
+    password: hunter2
+    tls:
+      keyFile: /etc/hotpot/nexus.key
+k3s:
+  registries:
+    configs:
+      - host: registry.example.com
+        auth: aG90cG90Omh1bnRlcjI=
+secrets:
+  genericSecrets:
+    - name: db
+      data:
+        username: admin
+        password: env.DB_PASSWORD
+  genericKeyValueSecrets:
+    - name: app
+      data:
+        - key: apiKey
+          value: s3cr3t-value
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := redactFile(path)
+	if err != nil {
+		t.Fatalf("redactFile() error = %v", err)
+	}
+	got := string(out)
+	for _, secret := range []string{"abcdef", "hunter2", "aG90cG90Omh1bnRlcjI=", "s3cr3t-value", "admin"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redacted recipe contains %q:\n%s", secret, got)
+		}
+	}
+	for _, kept := range []string{"env.OVH_SECRET", "username: hotpot", "/etc/hotpot/nexus.key", "key: node-role.kubernetes.io/control-plane", "env.DB_PASSWORD"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("redacted recipe lost %q:\n%s", kept, got)
+		}
+	}
+}
+
+func TestRedactRecipe(t *testing.T) {
+	r := &Recipe{
+		Path: "/etc/hotpot/recipe.sops.yaml",
+		K3s:  K3sConfig{ClusterToken: "K10cluster-t0ken"},
+		CertManager: CertManagerConfig{
+			DnsAzureClientSecret: "azure-s3cr3t",
+		},
+		Repositories: []RepositoryConfig{{Name: "nexus", Username: "hotpot", Password: "hunter2"}},
+		SecretProviders: SecretProvidersConfig{
+			Vault: VaultProviderConfig{Token: "hvs.root"},
+		},
+	}
+
+	out, err := redactRecipe(r)
+	if err != nil {
+		t.Fatalf("redactRecipe() error = %v", err)
+	}
+	got := string(out)
+	for _, secret := range []string{"K10cluster-t0ken", "azure-s3cr3t", "hunter2", "hvs.root"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("printed recipe contains %q:\n%s", secret, got)
+		}
+	}
+	for _, kept := range []string{`"username": "hotpot"`, `"name": "nexus"`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("printed recipe lost %q:\n%s", kept, got)
+		}
+	}
+}
+
+func TestPrerequisiteResults(t *testing.T) {
+	r := &Recipe{Node: Node{SupportedOs: []string{"linux"}, MinCpu: 4}}
+	got := prerequisiteResults(r, &mockSystemInfo{cpuErr: errors.New("2 cpus, 4 required"), curlErr: errors.New("unreachable")})
+	want := "os: ok\narch: ok\nram: ok\ncpu: failed: 2 cpus, 4 required\ndisk: ok\ncurl: failed: unreachable\n"
+	if got != want {
+		t.Errorf("prerequisiteResults() = %q, want %q", got, want)
+	}
+}
+
+func TestManagedLabels(t *testing.T) {
+	r := &Recipe{Path: "/etc/hotpot/Edge Cluster.yaml", Revision: "1760000000"}
+	want := map[string]string{
+		kubernetes.ManagedByLabel: "hotpot",
+		kubernetes.RecipeLabel:    "edge-cluster",
+		kubernetes.RevisionLabel:  "1760000000",
+	}
+	got := managedLabels(r)
+	if len(got) != len(want) {
+		t.Fatalf("managedLabels() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("managedLabels()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+
+	r.Name = "prod"
+	if got := pruneSelector(r); got != "app.kubernetes.io/managed-by=hotpot,hotpot.zcubbs.dev/recipe=prod,hotpot.zcubbs.dev/revision!=1760000000" {
+		t.Errorf("pruneSelector() = %q", got)
+	}
+	if got := traefikValues(r).Labels[kubernetes.RecipeLabel]; got != "prod" {
+		t.Errorf("traefik labels recipe = %q, want prod", got)
+	}
+}
+
+func TestPruneObjects(t *testing.T) {
+	r := &Recipe{Name: "prod", Revision: "2"}
+	pruner := &mockPruner{pruned: []kubernetes.ObjectRef{{Kind: "Secret", Namespace: "apps", Name: "registry"}}}
+	if err := pruneObjects(r, pruner); err != nil {
+		t.Fatalf("pruneObjects() error = %v", err)
+	}
+	if len(pruner.selectors) != 1 || !strings.HasSuffix(pruner.selectors[0], "hotpot.zcubbs.dev/revision!=2") {
+		t.Errorf("selectors = %v", pruner.selectors)
+	}
+
+	// objects of a release left as is were not applied again
+	r.skipped = true
+	pruner.selectors = nil
+	if err := pruneObjects(r, pruner); err != nil {
+		t.Fatalf("pruneObjects() error = %v", err)
+	}
+	if len(pruner.selectors) != 0 {
+		t.Errorf("pruned although a release was skipped: %v", pruner.selectors)
+	}
+}
+
+func TestPurgeGitops(t *testing.T) {
+	pruner := &mockPruner{}
+	if err := purgeGitops(&Recipe{Name: "prod"}, pruner); err != nil {
+		t.Fatalf("purgeGitops() error = %v", err)
+	}
+	want := []string{
+		"app.kubernetes.io/managed-by=hotpot,hotpot.zcubbs.dev/recipe=prod",
+		"app.kubernetes.io/managed-by=hotpot,hotpot.zcubbs.dev/recipe=prod,argocd.argoproj.io/secret-type",
+	}
+	if strings.Join(pruner.selectors, " ") != strings.Join(want, " ") {
+		t.Errorf("selectors = %v, want %v", pruner.selectors, want)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tenants.yaml")
+	content := `
+namespaces:
+  - name: team-a
+    labels:
+      app.kubernetes.io/part-of: team-a
+    podSecurity: restricted
+    quota:
+      requests.cpu: 4
+      limits.memory: 8Gi
+    limitRange:
+      default:
+        memory: 256Mi
+    defaultDeny: true
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(r.Namespaces) != 1 {
+		t.Fatalf("namespaces = %v, want 1", r.Namespaces)
+	}
+	ns := r.Namespaces[0]
+	if ns.Labels["app.kubernetes.io/part-of"] != "team-a" {
+		t.Errorf("labels = %v", ns.Labels)
+	}
+	if q := ns.Quota["requests.cpu"]; q.String() != "4" {
+		t.Errorf("quota requests.cpu = %s, want 4", q.String())
+	}
+
+	manifest, err := kubernetes.NamespaceManifest(namespaceSpec(ns))
+	if err != nil {
+		t.Fatalf("NamespaceManifest() error = %v", err)
+	}
+	for _, want := range []string{
+		"pod-security.kubernetes.io/enforce: restricted",
+		"kind: ResourceQuota",
+		"limits.memory: 8Gi",
+		"kind: LimitRange",
+		"memory: 256Mi",
+		"kind: NetworkPolicy",
+	} {
+		if !strings.Contains(string(manifest), want) {
+			t.Errorf("manifest lacks %q:\n%s", want, manifest)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		namespaces []NamespaceConfig
+	}{
+		{name: "missing name", namespaces: []NamespaceConfig{{PodSecurity: "baseline"}}},
+		{name: "unknown level", namespaces: []NamespaceConfig{{Name: "team-a", PodSecurity: "strict"}}},
+		{name: "duplicate", namespaces: []NamespaceConfig{{Name: "team-a"}, {Name: "team-a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(&Recipe{Distribution: "k3s", Namespaces: tt.namespaces}); err == nil {
+				t.Error("validate() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rbac.yaml")
+	content := `
+rbac:
+  roles:
+    - name: secret-reader
+      namespace: team-a
+      rules:
+        - apiGroups: [""]
+          resources: ["secrets"]
+          verbs: ["get", "list"]
+  bindings:
+    - name: team-a-admins
+      namespace: team-a
+      role: namespace-admin
+      groups: ["team-a"]
+    - name: ci-secrets
+      namespace: team-a
+      role: secret-reader
+      serviceAccounts: ["ci", "tools/deployer"]
+    - name: auditors
+      role: read-only
+      users: ["alice@example.com"]
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := validate(r); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	roles, bindings, err := rbacSpecs(r.RBAC)
+	if err != nil {
+		t.Fatalf("rbacSpecs() error = %v", err)
+	}
+	if len(roles) != 1 || len(roles[0].Rules) != 1 || roles[0].Rules[0].Verbs[1] != "list" {
+		t.Fatalf("roles = %+v", roles)
+	}
+	if len(bindings) != 3 {
+		t.Fatalf("bindings = %+v, want 3", bindings)
+	}
+	if ref := bindings[0].RoleRef; ref.Kind != "ClusterRole" || ref.Name != "admin" {
+		t.Errorf("namespace-admin role ref = %+v, want ClusterRole admin", ref)
+	}
+	if ref := bindings[1].RoleRef; ref.Kind != "Role" || ref.Name != "secret-reader" {
+		t.Errorf("secret-reader role ref = %+v, want Role secret-reader", ref)
+	}
+	if s := bindings[1].Subjects; len(s) != 2 || s[0].Namespace != "team-a" || s[1].Namespace != "tools" || s[1].Name != "deployer" {
+		t.Errorf("service account subjects = %+v", s)
+	}
+
+	manifest, err := kubernetes.RBACManifest(roles, bindings)
+	if err != nil {
+		t.Fatalf("RBACManifest() error = %v", err)
+	}
+	for _, want := range []string{"kind: Role\n", "kind: RoleBinding", "kind: ClusterRoleBinding", "name: view"} {
+		if !strings.Contains(string(manifest), want) {
+			t.Errorf("manifest lacks %q:\n%s", want, manifest)
+		}
+	}
+
+	tests := []struct {
+		name string
+		rbac RBACConfig
+	}{
+		{name: "rule without verbs", rbac: RBACConfig{Roles: []RoleConfig{{Name: "r", Rules: []RuleConfig{{Resources: []string{"pods"}}}}}}},
+		{name: "binding without subjects", rbac: RBACConfig{Bindings: []BindingConfig{{Name: "b", Role: "read-only"}}}},
+		{name: "binding without role", rbac: RBACConfig{Bindings: []BindingConfig{{Name: "b", Users: []string{"u"}}}}},
+		{name: "cluster binding of a role", rbac: RBACConfig{
+			Roles:    []RoleConfig{{Name: "r", Namespace: "team-a", Rules: []RuleConfig{{Verbs: []string{"get"}}}}},
+			Bindings: []BindingConfig{{Name: "b", Role: "r", Users: []string{"u"}}},
+		}},
+		{name: "service account without namespace", rbac: RBACConfig{Bindings: []BindingConfig{{Name: "b", Role: "read-only", ServiceAccounts: []string{"ci"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(&Recipe{Distribution: "k3s", RBAC: tt.rbac}); err == nil {
+				t.Error("validate() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestRegistryCredentials(t *testing.T) {
+	t.Setenv("HOTPOT_REGISTRY_PASSWORD", "p4ss")
+
+	username, password, err := registryCredentials(ContainerRegistryCredentials{
+		Name:     "registry",
+		Username: "robot",
+		Password: "env.HOTPOT_REGISTRY_PASSWORD",
+	})
+	if err != nil {
+		t.Fatalf("registryCredentials() error = %v", err)
+	}
+	if username != "robot" || password != "p4ss" {
+		t.Errorf("credentials = %s:%s, want robot:p4ss", username, password)
+	}
+
+	// useVault turns plain vault paths into hcv. references
+	for path, want := range map[string]string{
+		"secret/registry#username":     "hcv.secret/registry#username",
+		"hcv.secret/registry#password": "hcv.secret/registry#password",
+		"":                             "",
+	} {
+		if got := vaultRef(path); got != want {
+			t.Errorf("vaultRef(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestValidateSecretProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers SecretProvidersConfig
+		wantErr   bool
+	}{
+		{name: "defaults"},
+		{
+			name: "known auth methods",
+			providers: SecretProvidersConfig{
+				Vault: VaultProviderConfig{AuthMethod: "approle"},
+				Azure: AzureProviderConfig{AuthMethod: "none"},
+				Gcp:   GcpProviderConfig{AuthMethod: "serviceAccount"},
+			},
+		},
+		{name: "unknown vault auth method", providers: SecretProvidersConfig{Vault: VaultProviderConfig{AuthMethod: "ldap"}}, wantErr: true},
+		{name: "unknown azure auth method", providers: SecretProvidersConfig{Azure: AzureProviderConfig{AuthMethod: "password"}}, wantErr: true},
+		{name: "unknown gcp auth method", providers: SecretProvidersConfig{Gcp: GcpProviderConfig{AuthMethod: "apiKey"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(&Recipe{Distribution: "k3s", SecretProviders: tt.providers})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// broker is registered once, the registry has no way to remove schemes
+var (
+	broker         = &mockSecretProvider{}
+	registerBroker sync.Once
+)
+
+func TestSecretProviders(t *testing.T) {
+	registerBroker.Do(func() {
+		if err := secret.Register("broker", broker); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// keep the decryption away from the keys of the machine running the tests
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GNUPGHOME", t.TempDir())
+	t.Setenv("SOPS_AGE_KEY", "")
+	_ = os.Unsetenv("SOPS_AGE_KEY")
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join("testdata", "sops", "age.txt"))
+	defer secret.ConfigureSops(secret.SopsConfig{})
+	defer secret.ConfigureAws(secret.AwsConfig{})
+
+	path := filepath.Join("testdata", "sops", "recipe.enc.yaml")
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if r.Kubeconfig != "/etc/rancher/k3s/k3s.yaml" || len(r.Namespaces) != 1 {
+		t.Errorf("Load() = %+v, want the decrypted recipe", r)
+	}
+	if r.SecretProviders.Aws.Region != "eu-west-1" || len(r.SecretProviders.Custom) != 1 {
+		t.Errorf("secretProviders = %+v, want the aws and broker entries", r.SecretProviders)
+	}
+	if broker.config["token"] != "env.BROKER_TOKEN" {
+		t.Errorf("broker config = %v, want the entry of the recipe", broker.config)
+	}
+
+	// sops references are relative to the recipe
+	if got, err := secret.Provide("sops.secrets.enc.yaml#registry.password"); err != nil || got != "p4ss" {
+		t.Errorf("Provide() = %q, %v, want p4ss", got, err)
+	}
+
+	// loading the recipe again for the next cook reads the values again
+	reads := broker.reads
+	for i := 0; i < 2; i++ {
+		if got, err := secret.Provide("broker.team/registry"); err != nil || got != "team/registry@https://broker.internal" {
+			t.Errorf("Provide() = %q, %v", got, err)
+		}
+		if _, err := Load(path); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+	if broker.reads != reads+2 {
+		t.Errorf("broker reads = %d over 2 cooks, want 2", broker.reads-reads)
+	}
+
+	plain := filepath.Join(t.TempDir(), "recipe.yaml")
+	if err := os.WriteFile(plain, []byte("secretProviders:\n  brokr:\n    url: https://broker.internal\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(plain); err == nil || !strings.Contains(err.Error(), "available schemes: aws, azure, broker, env") {
+		t.Errorf("Load() error = %v, want the available schemes", err)
+	}
+
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := Load(path); err == nil {
+		t.Error("Load() decrypted the recipe without its age key")
+	}
+}
@@ -0,0 +1,171 @@
+package recipe
+
+import (
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/certmanager"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/distribution"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/helm"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/rancher"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/traefik"
+)
+
+// StatusReport is the health of the cluster and of the components of a recipe
+type StatusReport struct {
+	Distribution string                  `json:"distribution"`
+	Version      string                  `json:"version,omitempty"`
+	ClusterReady bool                    `json:"clusterReady"`
+	Nodes        []kubernetes.NodeStatus `json:"nodes"`
+	Components   []ComponentStatus       `json:"components"`
+	Syncd        string                  `json:"syncd"`
+	Errors       []string                `json:"errors,omitempty"`
+}
+
+// Healthy reports whether the cluster is ready and every component is healthy
+func (s *StatusReport) Healthy() bool {
+	if !s.ClusterReady || len(s.Errors) > 0 {
+		return false
+	}
+	for _, c := range s.Components {
+		if !c.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentStatus is the state of the helm release of a component and of the
+// resources it manages
+type ComponentStatus struct {
+	Name         string                            `json:"name"`
+	Namespace    string                            `json:"namespace"`
+	Release      *helm.ReleaseStatus               `json:"release"`
+	Deployments  []kubernetes.DeploymentStatus     `json:"deployments,omitempty"`
+	Issuers      []certmanager.ClusterIssuerStatus `json:"issuers,omitempty"`
+	Applications []argocd.ApplicationStatus        `json:"applications,omitempty"`
+	Healthy      bool                              `json:"healthy"`
+	Errors       []string                          `json:"errors,omitempty"`
+}
+
+// component is a helm release installed by the recipe
+type component struct {
+	name         string
+	release      string
+	namespace    string
+	issuers      bool
+	applications bool
+}
+
+// Status loads the recipe at recipePath and reports the health of the cluster
+// and of every component the recipe enables
+func Status(recipePath string, deps Dependencies) (*StatusReport, error) {
+	recipe, err := Load(recipePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := selectDistribution(recipe, &deps); err != nil {
+		return nil, err
+	}
+
+	return inspect(recipe, deps.Distribution, deps.Inspector), nil
+}
+
+func inspect(r *Recipe, dist distribution.Distribution, inspector Inspector) *StatusReport {
+	report := &StatusReport{Distribution: dist.Name()}
+
+	if dist.IsInstalled() {
+		version, err := dist.InstalledVersion()
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s version: %s", dist.Name(), err))
+		}
+		report.Version = version
+	}
+
+	ready, err := inspector.ClusterReady(r.Kubeconfig)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("cluster: %s", err))
+	}
+	report.ClusterReady = ready
+
+	report.Nodes, err = inspector.Nodes(r.Kubeconfig)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("nodes: %s", err))
+	}
+
+	report.Syncd, err = inspector.SyncdState()
+	if err != nil {
+		report.Syncd = "unknown"
+	}
+
+	for _, c := range components(r) {
+		report.Components = append(report.Components, inspectComponent(r, c, inspector))
+	}
+	return report
+}
+
+// components returns the helm releases the recipe enables
+func components(r *Recipe) []component {
+	var list []component
+	if r.CertManager.Enabled {
+		list = append(list, component{name: "cert-manager", release: certmanager.ReleaseName, namespace: certmanager.Namespace, issuers: true})
+	}
+	if r.Traefik.Enabled {
+		list = append(list, component{name: "traefik", release: traefik.ReleaseName, namespace: traefik.Namespace})
+	}
+	if r.Rancher.Enabled {
+		list = append(list, component{name: "rancher", release: rancher.ReleaseName, namespace: rancher.Namespace})
+	}
+	if r.ArgoCD.Enabled {
+		list = append(list, component{name: "argocd", release: argocd.ReleaseName, namespace: argocd.Namespace, applications: r.Gitops.Enabled})
+	}
+	for _, c := range r.Charts {
+		list = append(list, component{name: c.Name, release: c.Name, namespace: c.Namespace})
+	}
+	return list
+}
+
+func inspectComponent(r *Recipe, c component, inspector Inspector) ComponentStatus {
+	status := ComponentStatus{Name: c.name, Namespace: c.namespace}
+	fail := func(what string, err error) {
+		status.Errors = append(status.Errors, fmt.Sprintf("%s: %s", what, err))
+	}
+
+	release, err := inspector.Release(c.release, c.namespace, r.Kubeconfig)
+	if err != nil {
+		fail("release", err)
+	}
+	status.Release = release
+
+	status.Deployments, err = inspector.Deployments(c.namespace, r.Kubeconfig)
+	if err != nil {
+		fail("deployments", err)
+	}
+
+	if c.issuers {
+		status.Issuers, err = inspector.ClusterIssuers(r.Kubeconfig)
+		if err != nil {
+			fail("cluster issuers", err)
+		}
+	}
+
+	if c.applications {
+		status.Applications, err = inspector.Applications(r.Kubeconfig)
+		if err != nil {
+			fail("applications", err)
+		}
+	}
+
+	status.Healthy = len(status.Errors) == 0 && release != nil && release.IsDeployed()
+	for _, d := range status.Deployments {
+		status.Healthy = status.Healthy && d.IsReady()
+	}
+	for _, i := range status.Issuers {
+		status.Healthy = status.Healthy && i.Ready
+	}
+	for _, a := range status.Applications {
+		status.Healthy = status.Healthy && a.IsHealthy()
+	}
+	return status
+}
@@ -3,11 +3,13 @@ package recipe
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/zcubbs/hotpot/pkg/go-k8s/argocd"
 	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
 	"github.com/zcubbs/hotpot/pkg/secret"
+	osx "github.com/zcubbs/hotpot/pkg/x/os"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -15,83 +17,78 @@ import (
 type step struct {
 	f func(*Recipe) error // function
 	c bool                // condition
+	p bool                // runs when planning
 }
 
 func checkPrerequisites(r *Recipe, sysInfo SystemInfo) error {
 	fmt.Printf("🍳 Checking prerequisites... \n")
-	// check if os is linux
-	for _, v := range r.Node.SupportedOs {
-		if err := sysInfo.IsOS(v); err != nil {
+	for _, c := range prerequisites(r, sysInfo) {
+		if err := c.run(); err != nil {
 			return err
 		}
+		fmt.Printf("    ├─ %s: ok\n", c.name)
 	}
-	fmt.Printf("    ├─ os: ok\n")
 
-	// check if arch is amd64
-	if err := sysInfo.IsArchIn(r.Node.SupportedArch); err != nil {
-		return err
-	}
-	fmt.Printf("    ├─ arch: ok\n")
-
-	// check if ram is enough
-	if err := sysInfo.IsRAMEnough(r.Node.MinMemory); err != nil {
-		return err
-	}
-	fmt.Printf("    ├─ ram: ok\n")
+	fmt.Printf("    └─ prerequisites ok\n")
 
-	// check if cpu is enough
-	if err := sysInfo.IsCPUEnough(r.Node.MinCpu); err != nil {
-		return err
-	}
-	fmt.Printf("    ├─ cpu: ok\n")
+	return nil
+}
 
-	// check if disk is enough, check all disks
-	for _, v := range r.Node.MinDiskSize {
-		if err := sysInfo.IsDiskSpaceEnough(v.Path, v.Size); err != nil {
-			return err
-		}
-	}
-	fmt.Printf("    ├─ disk: ok\n")
+// prerequisite is a named node check
+type prerequisite struct {
+	name string
+	run  func() error
+}
 
-	// check if curl ok for list of url (curl <url>)
-	if err := sysInfo.IsCurlOK(r.Node.Curl); err != nil {
-		return err
+func prerequisites(r *Recipe, sysInfo SystemInfo) []prerequisite {
+	return []prerequisite{
+		// check if os is linux
+		{"os", func() error {
+			for _, v := range r.Node.SupportedOs {
+				if err := sysInfo.IsOS(v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		// check if arch is amd64
+		{"arch", func() error { return sysInfo.IsArchIn(r.Node.SupportedArch) }},
+		// check if ram is enough
+		{"ram", func() error { return sysInfo.IsRAMEnough(r.Node.MinMemory) }},
+		// check if cpu is enough
+		{"cpu", func() error { return sysInfo.IsCPUEnough(r.Node.MinCpu) }},
+		// check if disk is enough, check all disks
+		{"disk", func() error {
+			for _, v := range r.Node.MinDiskSize {
+				if err := sysInfo.IsDiskSpaceEnough(v.Path, v.Size); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		// check if curl ok for list of url (curl <url>)
+		{"curl", func() error { return sysInfo.IsCurlOK(r.Node.Curl) }},
 	}
-	fmt.Printf("    ├─ curl: ok\n")
-
-	fmt.Printf("    └─ prerequisites ok\n")
-
-	return nil
 }
 
 func configureGitopsRepos(r *Recipe, namespace string, repos []ArgocdRepository) error {
 	fmt.Printf("🍲 Configuring gitops repos... \n")
 	for _, repo := range repos {
-		// Check if credentials are using environment variables
-		if strings.HasPrefix(repo.Credentials.Username, "env.") || strings.HasPrefix(repo.Credentials.Password, "env.") {
-			// Try to resolve the credentials
-			username, usernameErr := secret.Provide(repo.Credentials.Username)
-			password, passwordErr := secret.Provide(repo.Credentials.Password)
-
-			// If we can't resolve the credentials, skip this repository
-			if usernameErr != nil || passwordErr != nil {
-				fmt.Printf("⚠️ Skipping repository %s due to missing environment variables\n", repo.Name)
-				continue
-			}
-
-			// Use the resolved credentials
-			repo.Credentials.Username = username
-			repo.Credentials.Password = password
+		username, password, err := repositoryCredentials(repo)
+		if err != nil {
+			fmt.Printf("⚠️ Skipping repository %s, its credentials are not available: %v\n", repo.Name, err)
+			continue
 		}
 
-		err := r.Dependencies.ArgoCD.CreateRepository(argocd.Repository{
+		err = r.Dependencies.ArgoCD.CreateRepository(argocd.Repository{
 			Name:      repo.Name,
 			Url:       repo.Url,
 			Type:      string(repo.Type),
-			Username:  repo.Credentials.Username,
-			Password:  repo.Credentials.Password,
+			Username:  username,
+			Password:  password,
 			Namespace: namespace,
 			IsOci:     repo.IsOci,
+			Labels:    managedLabels(r),
 		}, r.Kubeconfig, r.Debug)
 		if err != nil {
 			return err
@@ -127,6 +124,7 @@ func configureGitopsProjects(r *Recipe) error {
 			Name:        project.Name,
 			Namespace:   project.Namespace,
 			ClustersUrl: clustersUrl,
+			Labels:      managedLabels(r),
 		}, r.Kubeconfig, r.Debug)
 		if err != nil {
 			return err
@@ -145,6 +143,7 @@ func configureGitopsProjects(r *Recipe) error {
 
 func configureGitopsApps(r *Recipe, project string, namespace string, apps []App) error {
 	fmt.Printf("🍛 Configuring gitops apps... \n")
+	var names []string
 	for _, app := range apps {
 		// Skip applications that reference repositories that were skipped
 		if app.Repo == "" {
@@ -168,44 +167,85 @@ func configureGitopsApps(r *Recipe, project string, namespace string, apps []App
 			SelfHeal:        app.SelfHeal,
 			AllowEmpty:      app.AllowEmpty,
 			ArgoNamespace:   namespace,
+			Labels:          managedLabels(r),
 		}, r.Kubeconfig, r.Debug)
 		if err != nil {
 			return err
 		}
+		names = append(names, app.Name)
+	}
+
+	// apps may depend on each other, they are awaited once all are created
+	if !r.Gitops.Wait || len(names) == 0 {
+		return nil
+	}
+	fmt.Printf("⏳ Waiting for gitops apps to be synced and healthy... \n")
+	return r.Dependencies.ArgoCD.WaitApplications(namespace, names, r.Gitops.Timeout, r.Kubeconfig, r.Debug)
+}
+
+func createNamespaces(r *Recipe) error {
+	fmt.Printf("🥘 Creating namespaces... \n")
+	labels := managedLabels(r)
+	for _, ns := range r.Namespaces {
+		if err := kubernetes.ApplyNamespace(context.Background(), r.Kubeconfig, namespaceSpec(ns), labels, r.Debug); err != nil {
+			return fmt.Errorf("failed to apply namespace %s \n %w", ns.Name, err)
+		}
 	}
 	return nil
 }
 
+func namespaceSpec(ns NamespaceConfig) kubernetes.NamespaceSpec {
+	return kubernetes.NamespaceSpec{
+		Name:        ns.Name,
+		Labels:      ns.Labels,
+		Annotations: ns.Annotations,
+		PodSecurity: ns.PodSecurity,
+		Quota:       ns.Quota,
+		LimitRange: v1.LimitRangeItem{
+			Default:        ns.LimitRange.Default,
+			DefaultRequest: ns.LimitRange.DefaultRequest,
+			Max:            ns.LimitRange.Max,
+			Min:            ns.LimitRange.Min,
+		},
+		DefaultDeny: ns.DefaultDeny,
+	}
+}
+
 func createSecrets(r *Recipe) error {
 	fmt.Printf("🍝 Creating secrets... \n")
 	if r.Secrets.Enabled {
-		if err := createContainerRegistrySecrets(r.Secrets.ContainerRegistries, r.Kubeconfig, r.Debug); err != nil {
+		labels := managedLabels(r)
+		if err := createContainerRegistrySecrets(r.Secrets.ContainerRegistries, labels, r.Kubeconfig, r.Debug); err != nil {
 			return err
 		}
-		if err := createGenericSecrets(r.Secrets.GenericSecrets, r.Kubeconfig, r.Debug); err != nil {
+		if err := createGenericSecrets(r.Secrets.GenericSecrets, labels, r.Kubeconfig, r.Debug); err != nil {
 			return err
 		}
-		if err := createGenericKeyValueSecrets(r.Secrets.GenericKeyValueSecrets, r.Kubeconfig, r.Debug); err != nil {
+		if err := createGenericKeyValueSecrets(r.Secrets.GenericKeyValueSecrets, labels, r.Kubeconfig, r.Debug); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func createContainerRegistrySecrets(secrets []ContainerRegistryCredentials, kubeconfig string, debug bool) error {
+func createContainerRegistrySecrets(secrets []ContainerRegistryCredentials, labels map[string]string, kubeconfig string, debug bool) error {
 	fmt.Printf("🍜 Creating container registry secrets... \n")
-	for _, secret := range secrets {
-		// create secret
-		for _, namespace := range secret.Namespaces {
+	for _, registry := range secrets {
+		username, password, err := registryCredentials(registry)
+		if err != nil {
+			return err
+		}
+		for _, namespace := range registry.Namespaces {
 			err := kubernetes.CreateContainerRegistrySecret(
 				context.Background(),
 				kubeconfig,
 				kubernetes.ContainerRegistrySecret{
-					Name:     secret.Name,
-					Server:   secret.Url,
-					Username: secret.Username,
-					Password: secret.Password,
+					Name:     registry.Name,
+					Server:   registry.Url,
+					Username: username,
+					Password: password,
 					Email:    "",
+					Labels:   labels,
 				},
 				[]string{namespace},
 				true,
@@ -219,7 +259,49 @@ func createContainerRegistrySecrets(secrets []ContainerRegistryCredentials, kube
 	return nil
 }
 
-func createGenericSecrets(secrets []GenericSecret, kubeconfig string, debug bool) error {
+// registryCredentials resolves the secret references of a registry username and password
+func registryCredentials(registry ContainerRegistryCredentials) (string, string, error) {
+	username, password := registry.Username, registry.Password
+	if registry.UseVault {
+		username, password = vaultRef(username), vaultRef(password)
+	}
+	username, err := secret.Provide(username)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to provide container registry %s username \n %w", registry.Name, err)
+	}
+	password, err = secret.Provide(password)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to provide container registry %s password \n %w", registry.Name, err)
+	}
+	return username, password, nil
+}
+
+// repositoryCredentials resolves the secret references of a gitops repository username and password
+func repositoryCredentials(repo ArgocdRepository) (string, string, error) {
+	username, password := repo.Credentials.Username, repo.Credentials.Password
+	if repo.Credentials.UseVault {
+		username, password = vaultRef(username), vaultRef(password)
+	}
+	username, err := secret.Provide(username)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to provide gitops repository %s username \n %w", repo.Name, err)
+	}
+	password, err = secret.Provide(password)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to provide gitops repository %s password \n %w", repo.Name, err)
+	}
+	return username, password, nil
+}
+
+// vaultRef turns a vault path of a useVault credential into a hcv. reference
+func vaultRef(path string) string {
+	if path == "" || strings.HasPrefix(path, "hcv.") {
+		return path
+	}
+	return "hcv." + path
+}
+
+func createGenericSecrets(secrets []GenericSecret, labels map[string]string, kubeconfig string, debug bool) error {
 	fmt.Printf("🍡 Creating generic secrets... \n")
 	for _, secret := range secrets {
 		data := make(map[string][]byte)
@@ -233,6 +315,7 @@ func createGenericSecrets(secrets []GenericSecret, kubeconfig string, debug bool
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      secret.Name,
 					Namespace: secret.Namespace,
+					Labels:    labels,
 				},
 				Type: v1.SecretType(secret.Type),
 				Data: data,
@@ -248,7 +331,7 @@ func createGenericSecrets(secrets []GenericSecret, kubeconfig string, debug bool
 	return nil
 }
 
-func createGenericKeyValueSecrets(secrets []GenericKeyValueSecret, kubeconfig string, debug bool) error {
+func createGenericKeyValueSecrets(secrets []GenericKeyValueSecret, labels map[string]string, kubeconfig string, debug bool) error {
 	fmt.Printf("🍢 Creating generic key value secrets... \n")
 	for _, secret := range secrets {
 		data := make(map[string][]byte)
@@ -262,6 +345,7 @@ func createGenericKeyValueSecrets(secrets []GenericKeyValueSecret, kubeconfig st
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      secret.Name,
 					Namespace: secret.Namespace,
+					Labels:    labels,
 				},
 				Type: v1.SecretType(secret.Type),
 				Data: data,
@@ -277,6 +361,39 @@ func createGenericKeyValueSecrets(secrets []GenericKeyValueSecret, kubeconfig st
 	return nil
 }
 
+// snapshotCronMarker tags the crontab line managed by scheduleSnapshots
+const snapshotCronMarker = "# hotpot-snapshot"
+
+// scheduleSnapshots installs the snapshot cron job, or removes it when
+// snapshots are disabled in the recipe
+func scheduleSnapshots(r *Recipe) error {
+	if !r.K3s.Snapshots.Enabled {
+		return osx.RemoveCronJobs(snapshotCronMarker)
+	}
+
+	fmt.Printf("🥡 Scheduling k3s snapshots... \n")
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to resolve hotpot executable \n %w", err)
+	}
+
+	// replace any previous schedule so changes to the recipe are picked up
+	if err := osx.RemoveCronJobs(snapshotCronMarker); err != nil {
+		return err
+	}
+	return osx.AddCronJob(snapshotCronJob(r.K3s.Snapshots.Schedule, exe, r.Path))
+}
+
+func snapshotCronJob(schedule, exe, recipePath string) string {
+	return fmt.Sprintf("%s %s snapshot save -r %s --prune %s", schedule, cronQuote(exe), cronQuote(recipePath), snapshotCronMarker)
+}
+
+// cronQuote single quotes s for the shell running the cron job, and escapes
+// the % cron turns into newlines
+func cronQuote(s string) string {
+	return strings.ReplaceAll("'"+strings.ReplaceAll(s, "'", `'\''`)+"'", "%", `\%`)
+}
+
 func printKubeconfig(r *Recipe) error {
 	fmt.Printf("🍳 Kubeconfig: %s\n", r.Kubeconfig)
 	return nil
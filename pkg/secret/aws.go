@@ -0,0 +1,142 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AwsConfig configures the aws. provider. Credentials come from the standard
+// chain: environment, shared config and credentials files, web identity, then
+// the ECS task or EC2 instance role.
+type AwsConfig struct {
+	// Region defaults to AWS_REGION or the profile region
+	Region  string
+	Profile string
+	// Endpoint overrides the Secrets Manager and SSM endpoints, e.g. http://localhost:4566 for LocalStack
+	Endpoint string
+}
+
+var (
+	awsMu     sync.Mutex
+	awsConfig AwsConfig
+	// awsCfg is loaded on the first read, the values read are cached by the registry
+	awsCfg *aws.Config
+)
+
+// ConfigureAws sets the configuration of the aws. provider
+func ConfigureAws(config AwsConfig) {
+	awsMu.Lock()
+	defer awsMu.Unlock()
+	awsConfig = config
+	awsCfg = nil
+	ClearCache()
+}
+
+// provideFromAws returns the value of an aws.sm/<name>#<jsonKey> reference to a
+// Secrets Manager secret, the key selecting a field of a JSON secret, or of an
+// aws.ssm/<path> reference to a SSM parameter, decrypted when a SecureString.
+func provideFromAws(key string) (string, error) {
+	service, ref, _ := strings.Cut(strings.TrimPrefix(key, "aws."), "/")
+	if ref == "" {
+		return "", fmt.Errorf("failed to get secret from aws: %s is not aws.sm/<name> or aws.ssm/<path>", key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cfg, err := loadAwsConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch service {
+	case "sm":
+		name, field, _ := strings.Cut(ref, "#")
+		v, err := getSecretValue(ctx, cfg, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret %s from aws secrets manager \n %w", name, err)
+		}
+		if field == "" {
+			return v, nil
+		}
+		return jsonField(v, field)
+	case "ssm":
+		name := ref
+		// hierarchical parameters start with a slash, aws.ssm/hotpot/token is /hotpot/token
+		if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		v, err := getParameter(ctx, cfg, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to get parameter %s from aws ssm \n %w", name, err)
+		}
+		return v, nil
+	default:
+		return "", fmt.Errorf("failed to get secret from aws: unknown service %s, use aws.sm/ or aws.ssm/", service)
+	}
+}
+
+// loadAwsConfig returns the aws config of the provider, loading it on first use
+func loadAwsConfig(ctx context.Context) (aws.Config, error) {
+	awsMu.Lock()
+	defer awsMu.Unlock()
+	if awsCfg != nil {
+		return *awsCfg, nil
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if awsConfig.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(awsConfig.Region))
+	}
+	if awsConfig.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(awsConfig.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load aws config \n %w", err)
+	}
+	if awsConfig.Endpoint != "" {
+		cfg.BaseEndpoint = aws.String(awsConfig.Endpoint)
+	}
+	awsCfg = &cfg
+	return cfg, nil
+}
+
+func getSecretValue(ctx context.Context, cfg aws.Config, name string) (string, error) {
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString != nil {
+		return *out.SecretString, nil
+	}
+	return string(out.SecretBinary), nil
+}
+
+func getParameter(ctx context.Context, cfg aws.Config, name string) (string, error) {
+	out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// jsonField returns the value at the dotted path of a JSON secret
+func jsonField(v, path string) (string, error) {
+	doc, err := parseDocument([]byte(v))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse secret as json, required by #%s \n %w", path, err)
+	}
+	return lookup(doc, path)
+}
@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAws(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	// localstack stands in for Secrets Manager and SSM and counts the reads
+	reads := 0
+	localstack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch {
+		case r.Header.Get("X-Amz-Target") == "secretsmanager.GetSecretValue" && body["SecretId"] == "prod/route53":
+			_, _ = w.Write([]byte(`{"Name":"prod/route53","SecretString":"{\"accessKey\":\"AKIA\",\"secretKey\":\"s3cr3t\",\"port\":5432}"}`))
+		case r.Header.Get("X-Amz-Target") == "AmazonSSM.GetParameter" && body["Name"] == "/hotpot/ecr/password" && body["WithDecryption"] == true:
+			_, _ = w.Write([]byte(`{"Parameter":{"Name":"/hotpot/ecr/password","Type":"SecureString","Value":"ecr-p4ss"}}`))
+		default:
+			w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+		}
+	}))
+	defer localstack.Close()
+	defer ConfigureAws(AwsConfig{})
+
+	ConfigureAws(AwsConfig{Region: "eu-west-1", Endpoint: localstack.URL})
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "aws.sm/prod/route53#accessKey", want: "AKIA"},
+		{key: "aws.sm/prod/route53#secretKey", want: "s3cr3t"},
+		{key: "aws.sm/prod/route53#port", want: "5432"},
+		{key: "aws.sm/prod/route53", want: `{"accessKey":"AKIA","secretKey":"s3cr3t","port":5432}`},
+		{key: "aws.ssm/hotpot/ecr/password", want: "ecr-p4ss"},
+		{key: "aws.ssm//hotpot/ecr/password", want: "ecr-p4ss"},
+		{key: "aws.sm/prod/route53#region", wantErr: true},
+		{key: "aws.sm/prod/dns", wantErr: true},
+		{key: "aws.kms/key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := Provide(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// each key is read once, kms is rejected before any read
+	if reads != 8 {
+		t.Errorf("localstack reads = %d, want 8", reads)
+	}
+
+	// the registry caches the values read, not the errors
+	for _, tt := range tests {
+		_, _ = Provide(tt.key)
+	}
+	if reads != 10 {
+		t.Errorf("localstack reads = %d, want 10 once the values are cached", reads)
+	}
+
+	// configuring again drops the cached values
+	ConfigureAws(AwsConfig{Region: "eu-west-1", Endpoint: localstack.URL})
+	if _, err := Provide("aws.sm/prod/route53#accessKey"); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 11 {
+		t.Errorf("localstack reads = %d, want 11 after configuring again", reads)
+	}
+}
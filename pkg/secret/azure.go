@@ -0,0 +1,178 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AzureAuthServicePrincipal = "servicePrincipal"
+	AzureAuthManagedIdentity  = "managedIdentity"
+	// AzureAuthNone sends no token, for local Key Vault emulators
+	AzureAuthNone = "none"
+
+	azureKeyVaultScope      = "https://vault.azure.net/.default"
+	azureKeyVaultApiVersion = "7.4"
+)
+
+// AzureConfig configures the azure. provider. Without an AuthMethod the
+// default azure chain is used: environment service principal, workload
+// identity, managed identity, then the az CLI.
+type AzureConfig struct {
+	// AuthMethod is servicePrincipal, managedIdentity or none
+	AuthMethod string
+	TenantId   string
+	// ClientId is the service principal, or the user-assigned managed identity
+	ClientId string
+	// ClientSecret may be a secret reference, e.g. env.AZURE_CLIENT_SECRET
+	ClientSecret string
+	// Endpoint replaces the https://<vault>.vault.azure.net url of every vault, e.g. for an emulator
+	Endpoint string
+}
+
+var (
+	azureMu     sync.Mutex
+	azureConfig AzureConfig
+	azureCred   azcore.TokenCredential
+)
+
+// ConfigureAzure sets the configuration of the azure. provider
+func ConfigureAzure(config AzureConfig) {
+	azureMu.Lock()
+	defer azureMu.Unlock()
+	azureConfig = config
+	azureCred = nil
+	ClearCache()
+}
+
+// provideFromAzure returns the value of an azure.<vault>/<secret>/<version>#<jsonKey>
+// reference to a Key Vault secret. The version defaults to the latest, the key
+// selects a field of a JSON secret.
+func provideFromAzure(key string) (string, error) {
+	ref, field, _ := strings.Cut(strings.TrimPrefix(key, "azure."), "#")
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("failed to get secret from azure: %s is not azure.<vault>/<secret>/<version>", key)
+	}
+	vaultName, name, version := parts[0], parts[1], ""
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	azureMu.Lock()
+	config := azureConfig
+	cred, err := azureCredential()
+	azureMu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from azure \n %w", err)
+	}
+
+	vaultUrl := config.Endpoint
+	if vaultUrl == "" {
+		host := vaultName
+		if !strings.Contains(host, ".") {
+			host += ".vault.azure.net"
+		}
+		vaultUrl = "https://" + host
+	}
+
+	v, err := getKeyVaultSecret(cred, vaultUrl, name, version)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s from azure key vault %s \n %w", name, vaultName, err)
+	}
+	if field == "" {
+		return v, nil
+	}
+	return jsonField(v, field)
+}
+
+// azureCredential returns the credential of the configured auth method, nil
+// for none, azureMu must be held
+func azureCredential() (azcore.TokenCredential, error) {
+	if azureCred != nil || azureConfig.AuthMethod == AzureAuthNone {
+		return azureCred, nil
+	}
+
+	var cred azcore.TokenCredential
+	var err error
+	switch azureConfig.AuthMethod {
+	case "":
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+	case AzureAuthServicePrincipal:
+		var clientSecret string
+		clientSecret, err = resolveCredential(azureConfig.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve azure client secret \n %w", err)
+		}
+		cred, err = azidentity.NewClientSecretCredential(azureConfig.TenantId, azureConfig.ClientId, clientSecret, nil)
+	case AzureAuthManagedIdentity:
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if azureConfig.ClientId != "" {
+			opts.ID = azidentity.ClientID(azureConfig.ClientId)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(opts)
+	default:
+		return nil, fmt.Errorf("unknown azure auth method %s, use servicePrincipal, managedIdentity or none", azureConfig.AuthMethod)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure credential \n %w", err)
+	}
+	azureCred = cred
+	return cred, nil
+}
+
+func getKeyVaultSecret(cred azcore.TokenCredential, vaultUrl, name, version string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	u := strings.TrimRight(vaultUrl, "/") + "/secrets/" + url.PathEscape(name)
+	if version != "" {
+		u += "/" + url.PathEscape(version)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u+"?api-version="+azureKeyVaultApiVersion, nil)
+	if err != nil {
+		return "", err
+	}
+	if cred != nil {
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureKeyVaultScope}})
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var body struct {
+		Value string `json:"value"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return "", fmt.Errorf("unexpected key vault response %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%d %s: %s", resp.StatusCode, body.Error.Code, body.Error.Message)
+	}
+	return body.Value, nil
+}
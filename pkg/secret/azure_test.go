@@ -0,0 +1,52 @@
+package secret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzure(t *testing.T) {
+	// emulator stands in for Key Vault, the secret dns-client-secret has two versions
+	emulator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/secrets/dns-client-secret", "/secrets/dns-client-secret/v2":
+			_, _ = w.Write([]byte(`{"value":"azure-s3cr3t","id":"dns-client-secret/v2"}`))
+		case "/secrets/dns-client-secret/v1":
+			_, _ = w.Write([]byte(`{"value":"azure-old","id":"dns-client-secret/v1"}`))
+		case "/secrets/ovh":
+			_, _ = w.Write([]byte(`{"value":"{\"consumerKey\":\"ck\"}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"SecretNotFound","message":"not found"}}`))
+		}
+	}))
+	defer emulator.Close()
+	defer ConfigureAzure(AzureConfig{})
+
+	ConfigureAzure(AzureConfig{AuthMethod: AzureAuthNone, Endpoint: emulator.URL})
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "azure.hotpot/dns-client-secret", want: "azure-s3cr3t"},
+		{key: "azure.hotpot/dns-client-secret/v1", want: "azure-old"},
+		{key: "azure.hotpot/ovh#consumerKey", want: "ck"},
+		{key: "azure.hotpot/ovh#applicationKey", wantErr: true},
+		{key: "azure.hotpot/missing", wantErr: true},
+		{key: "azure.hotpot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := Provide(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
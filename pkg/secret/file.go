@@ -0,0 +1,122 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/x/style"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+)
+
+const filePrefix = "file://"
+
+// provideFromFile returns the content of the file of a file:// key, or the value
+// at the dotted path following # in the json or yaml file.
+// The path expands ~ and environment variables, e.g. file://$CREDENTIALS_DIRECTORY/registry.
+func provideFromFile(key string) (string, error) {
+	path, selector, _ := strings.Cut(strings.TrimPrefix(key, filePrefix), "#")
+	path, err := expandPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from file \n %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from file \n %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("failed to get secret from file %s: is a directory", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 {
+		style.PrintColoredWarning(fmt.Sprintf("secret file %s is world-readable, restrict it with chmod o-r", path))
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from file \n %w", err)
+	}
+	if selector == "" {
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	doc, err := parseDocument(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse secret file %s \n %w", path, err)
+	}
+	v, err := lookup(doc, selector)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s from file %s \n %w", selector, path, err)
+	}
+	return v, nil
+}
+
+// expandPath expands a leading ~ and environment variables of path
+func expandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if path == "" {
+		return "", fmt.Errorf("empty file path")
+	}
+	return filepath.Clean(path), nil
+}
+
+// parseDocument parses a json or yaml document, keeping numbers as written, e.g. ports and ids
+func parseDocument(b []byte) (interface{}, error) {
+	var doc interface{}
+	useNumber := func(d *json.Decoder) *json.Decoder { d.UseNumber(); return d }
+	if err := yaml.Unmarshal(b, &doc, useNumber); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// lookup returns the scalar at the dotted path of doc. Keys holding dots are
+// matched whole first, list items are selected by index.
+func lookup(doc interface{}, path string) (string, error) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if value, ok := v[path]; ok {
+			return scalar(value)
+		}
+		for i := len(path) - 1; i > 0; i-- {
+			if path[i] != '.' {
+				continue
+			}
+			if value, ok := v[path[:i]]; ok {
+				return lookup(value, path[i+1:])
+			}
+		}
+	case []interface{}:
+		head, rest, found := strings.Cut(path, ".")
+		if i, err := strconv.Atoi(head); err == nil && i >= 0 && i < len(v) {
+			if !found {
+				return scalar(v[i])
+			}
+			return lookup(v[i], rest)
+		}
+	}
+	return "", fmt.Errorf("key %s not found", path)
+}
+
+func scalar(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case nil:
+		return "", fmt.Errorf("value is empty")
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("value is not a scalar")
+	default:
+		return fmt.Sprint(s), nil
+	}
+}
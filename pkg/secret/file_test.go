@@ -0,0 +1,130 @@
+package secret
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, perm os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(dir, name), perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("token", "t0ken\n", 0600)
+	write("registry.yaml", `
+registry:
+  username: robot
+  password: p4ss
+  port: 5000
+  insecure: false
+  email: null
+auths:
+  ghcr.io:
+    token: ghp
+mirrors:
+  - name: docker.io
+    endpoints: [https://mirror.internal]
+`, 0600)
+	write("ids.json", `{"tenant": {"id": 12345678901234567890, "name": "hub"}, "keys": ["a", "b"]}`, 0600)
+	write("shared", "shared\n", 0644)
+	if err := os.Mkdir(filepath.Join(dir, "credentials"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	t.Setenv("HOME", dir)
+	ClearCache()
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr string
+	}{
+		{key: "file://" + filepath.Join(dir, "token"), want: "t0ken"},
+		{key: "file://$CREDENTIALS_DIRECTORY/token", want: "t0ken"},
+		{key: "file://~/token", want: "t0ken"},
+		{key: "file://" + dir + "/../" + filepath.Base(dir) + "/token", want: "t0ken"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.username", want: "robot"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.port", want: "5000"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.insecure", want: "false"},
+		// keys holding dots are matched whole
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#auths.ghcr.io.token", want: "ghp"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#mirrors.0.name", want: "docker.io"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#mirrors.0.endpoints.0", want: "https://mirror.internal"},
+		{key: "file://$CREDENTIALS_DIRECTORY/ids.json#tenant.id", want: "12345678901234567890"},
+		{key: "file://$CREDENTIALS_DIRECTORY/ids.json#keys.1", want: "b"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry", wantErr: "not a scalar"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#mirrors", wantErr: "not a scalar"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.email", wantErr: "value is empty"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.token", wantErr: "key token not found"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#mirrors.1.name", wantErr: "not found"},
+		{key: "file://$CREDENTIALS_DIRECTORY/registry.yaml#mirrors.first", wantErr: "not found"},
+		{key: "file://$CREDENTIALS_DIRECTORY/token#value", wantErr: "failed to get secret value"},
+		{key: "file://$CREDENTIALS_DIRECTORY/credentials", wantErr: "is a directory"},
+		{key: "file://$CREDENTIALS_DIRECTORY/missing", wantErr: "no such file"},
+		{key: "file://$HOTPOT_UNSET", wantErr: "empty file path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := Provide(tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Provide() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Provide() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Provide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	out := captureStdout(t, func() {
+		if got, err := Provide("file://$CREDENTIALS_DIRECTORY/shared"); err != nil || got != "shared" {
+			t.Errorf("Provide() = %q, %v, want shared", got, err)
+		}
+		if _, err := Provide("file://$CREDENTIALS_DIRECTORY/registry.yaml#registry.password"); err != nil {
+			t.Error(err)
+		}
+	})
+	if !strings.Contains(out, filepath.Join(dir, "shared")+" is world-readable") {
+		t.Errorf("output = %q, want a warning for the world-readable file", out)
+	}
+	if strings.Contains(out, "registry.yaml") {
+		t.Errorf("output = %q, want no warning for the owner-only file", out)
+	}
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	_ = w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
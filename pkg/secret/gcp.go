@@ -0,0 +1,172 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GcpAuthServiceAccount = "serviceAccount"
+	// GcpAuthNone sends no token, for local Secret Manager emulators
+	GcpAuthNone = "none"
+
+	gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	gcpCloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// GcpConfig configures the gcp. provider. Without an AuthMethod the
+// application default credentials are used: GOOGLE_APPLICATION_CREDENTIALS,
+// the gcloud credentials, then the metadata server of GCE and GKE.
+type GcpConfig struct {
+	// AuthMethod is serviceAccount or none
+	AuthMethod string
+	// CredentialsFile is the service account key of the serviceAccount auth method
+	CredentialsFile string
+	// Project is used by references without a project
+	Project string
+	// Endpoint replaces https://secretmanager.googleapis.com, e.g. for an emulator
+	Endpoint string
+}
+
+var (
+	gcpMu     sync.Mutex
+	gcpConfig GcpConfig
+	gcpClient *http.Client
+)
+
+// ConfigureGcp sets the configuration of the gcp. provider
+func ConfigureGcp(config GcpConfig) {
+	gcpMu.Lock()
+	defer gcpMu.Unlock()
+	gcpConfig = config
+	gcpClient = nil
+	ClearCache()
+}
+
+// provideFromGcp returns the value of a gcp.<project>/<secret>/<version>#<jsonKey>
+// reference to a Secret Manager secret. The project defaults to the configured
+// one when only the secret is given, the version to latest, and the key selects
+// a field of a JSON secret.
+func provideFromGcp(key string) (string, error) {
+	ref, field, _ := strings.Cut(strings.TrimPrefix(key, "gcp."), "#")
+	parts := strings.Split(ref, "/")
+
+	gcpMu.Lock()
+	client, err := gcpHttpClient()
+	// the project defaults to the one of the credentials
+	config := gcpConfig
+	gcpMu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from gcp \n %w", err)
+	}
+
+	project, name, version := config.Project, "", "latest"
+	switch len(parts) {
+	case 1:
+		name = parts[0]
+	case 2:
+		project, name = parts[0], parts[1]
+	case 3:
+		project, name, version = parts[0], parts[1], parts[2]
+	}
+	if project == "" || name == "" || version == "" {
+		return "", fmt.Errorf("failed to get secret from gcp: %s is not gcp.<project>/<secret>/<version>, or gcp.<secret> with secretProviders.gcp.project", key)
+	}
+
+	v, err := accessSecretVersion(client, config.Endpoint, project, name, version)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s from gcp secret manager \n %w", name, err)
+	}
+	if field == "" {
+		return v, nil
+	}
+	return jsonField(v, field)
+}
+
+// gcpHttpClient returns a client authenticated by the configured auth method, gcpMu must be held
+func gcpHttpClient() (*http.Client, error) {
+	if gcpClient != nil {
+		return gcpClient, nil
+	}
+
+	ctx := context.Background()
+	var creds *google.Credentials
+	var err error
+	switch gcpConfig.AuthMethod {
+	case "":
+		creds, err = google.FindDefaultCredentials(ctx, gcpCloudPlatformScope)
+	case GcpAuthServiceAccount:
+		var b []byte
+		b, err = os.ReadFile(gcpConfig.CredentialsFile)
+		if err == nil {
+			creds, err = google.CredentialsFromJSON(ctx, b, gcpCloudPlatformScope)
+		}
+	case GcpAuthNone:
+		gcpClient = &http.Client{}
+		return gcpClient, nil
+	default:
+		return nil, fmt.Errorf("unknown gcp auth method %s, use serviceAccount or none", gcpConfig.AuthMethod)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gcp credentials \n %w", err)
+	}
+	if gcpConfig.Project == "" {
+		gcpConfig.Project = creds.ProjectID
+	}
+	gcpClient = oauth2.NewClient(ctx, creds.TokenSource)
+	return gcpClient, nil
+}
+
+func accessSecretVersion(client *http.Client, endpoint, project, name, version string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if endpoint == "" {
+		endpoint = gcpSecretManagerEndpoint
+	}
+	u := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access", strings.TrimRight(endpoint, "/"), project, name, version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return "", fmt.Errorf("unexpected secret manager response %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%d %s: %s", resp.StatusCode, body.Error.Status, body.Error.Message)
+	}
+	data, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret payload \n %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,61 @@
+package secret
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGcp(t *testing.T) {
+	// emulator stands in for Secret Manager, version 1 of registry is pinned
+	emulator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := func(v string) string {
+			return `{"name":"projects/hotpot/secrets/registry/versions/1","payload":{"data":"` + base64.StdEncoding.EncodeToString([]byte(v)) + `"}}`
+		}
+		switch r.URL.Path {
+		case "/v1/projects/hotpot/secrets/registry/versions/latest:access":
+			_, _ = w.Write([]byte(payload(`{"password":"gcp-p4ss"}`)))
+		case "/v1/projects/hotpot/secrets/registry/versions/1:access":
+			_, _ = w.Write([]byte(payload(`{"password":"gcp-old"}`)))
+		case "/v1/projects/other/secrets/token/versions/latest:access":
+			_, _ = w.Write([]byte(payload("t0ken")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","message":"not found"}}`))
+		}
+	}))
+	defer emulator.Close()
+	defer ConfigureGcp(GcpConfig{})
+
+	ConfigureGcp(GcpConfig{AuthMethod: GcpAuthNone, Project: "hotpot", Endpoint: emulator.URL})
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "gcp.registry#password", want: "gcp-p4ss"},
+		{key: "gcp.hotpot/registry/1#password", want: "gcp-old"},
+		{key: "gcp.other/token", want: "t0ken"},
+		{key: "gcp.registry#username", wantErr: true},
+		{key: "gcp.hotpot/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := Provide(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// references without a project need the configured one
+	ConfigureGcp(GcpConfig{AuthMethod: GcpAuthNone, Endpoint: emulator.URL})
+	if _, err := Provide("gcp.registry#password"); err == nil {
+		t.Error("Provide() read a secret without a project")
+	}
+}
@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"github.com/zcubbs/hotpot/pkg/go-k8s/kubernetes"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KubernetesConfig configures the k8s. provider
+type KubernetesConfig struct {
+	// Kubeconfig holds the cluster secrets are read from, resolved like kubectl when empty
+	Kubeconfig string
+	// Context is the kubeconfig context used by default, the current context when empty
+	Context string
+}
+
+var (
+	k8sMu     sync.Mutex
+	k8sConfig KubernetesConfig
+)
+
+// ConfigureKubernetes sets the configuration of the k8s. provider
+func ConfigureKubernetes(config KubernetesConfig) {
+	k8sMu.Lock()
+	defer k8sMu.Unlock()
+	k8sConfig = config
+	ClearCache()
+}
+
+// provideFromK8s returns the value of a k8s.<namespace>/<secret>/<key> reference.
+// The key may be left out of secrets holding a single key, and ?context=<name>
+// reads the secret from another context of the kubeconfig, e.g. a management cluster.
+func provideFromK8s(key string) (string, error) {
+	ref, query, _ := strings.Cut(strings.TrimPrefix(key, "k8s."), "?")
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("failed to get secret from k8s: %s is not k8s.<namespace>/<secret>/<key>", key)
+	}
+	namespace, name := parts[0], parts[1]
+
+	k8sMu.Lock()
+	config := k8sConfig
+	k8sMu.Unlock()
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret from k8s: invalid options of %s \n %w", key, err)
+		}
+		if c := values.Get("context"); c != "" {
+			config.Context = c
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s, err := kubernetes.GetSecretFromContext(ctx, config.Kubeconfig, config.Context, namespace, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s from k8s \n %w", namespace, name, err)
+	}
+
+	field := ""
+	if len(parts) == 3 {
+		field = parts[2]
+	}
+	if field == "" {
+		if len(s.Data) != 1 {
+			return "", fmt.Errorf("failed to get secret from k8s: %s/%s holds %d keys, select one with %s/%s/<key>", namespace, name, len(s.Data), namespace, name)
+		}
+		for k := range s.Data {
+			field = k
+		}
+	}
+	v, ok := s.Data[field]
+	if !ok {
+		return "", fmt.Errorf("failed to get secret from k8s: key %s not found in %s/%s", field, namespace, name)
+	}
+	return string(v), nil
+}
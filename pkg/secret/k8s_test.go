@@ -0,0 +1,86 @@
+package secret
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// apiServer serves the secret cert-manager/ovh of one cluster
+func apiServer(t *testing.T, cluster string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/namespaces/cert-manager/secrets/ovh" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"ovh","namespace":"cert-manager"},"data":{"applicationKey":"` +
+			base64.StdEncoding.EncodeToString([]byte(cluster+"-key")) + `","applicationSecret":"c2VjcmV0"}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestKubernetes(t *testing.T) {
+	child, mgmt := apiServer(t, "child"), apiServer(t, "mgmt")
+	defer ConfigureKubernetes(KubernetesConfig{})
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	content := `apiVersion: v1
+kind: Config
+current-context: child
+clusters:
+  - name: child
+    cluster: {server: ` + child.URL + `}
+  - name: mgmt
+    cluster: {server: ` + mgmt.URL + `}
+users:
+  - name: admin
+    user: {token: t0ken}
+contexts:
+  - name: child
+    context: {cluster: child, user: admin}
+  - name: mgmt
+    context: {cluster: mgmt, user: admin}
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  KubernetesConfig
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "current context", key: "k8s.cert-manager/ovh/applicationKey", want: "child-key"},
+		{name: "context query", key: "k8s.cert-manager/ovh/applicationKey?context=mgmt", want: "mgmt-key"},
+		{name: "configured context", config: KubernetesConfig{Context: "mgmt"}, key: "k8s.cert-manager/ovh/applicationKey", want: "mgmt-key"},
+		{name: "other key", key: "k8s.cert-manager/ovh/applicationSecret", want: "secret"},
+		{name: "several keys and none selected", key: "k8s.cert-manager/ovh", wantErr: true},
+		{name: "missing key", key: "k8s.cert-manager/ovh/consumerKey", wantErr: true},
+		{name: "missing secret", key: "k8s.cert-manager/azuredns/clientSecret", wantErr: true},
+		{name: "no namespace", key: "k8s.ovh", wantErr: true},
+		{name: "unknown context", key: "k8s.cert-manager/ovh/applicationKey?context=staging", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Kubeconfig = kubeconfig
+			ConfigureKubernetes(tt.config)
+
+			got, err := Provide(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provide(%s) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provide(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
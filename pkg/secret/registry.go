@@ -0,0 +1,176 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider resolves the secret references of a scheme
+type Provider interface {
+	// Provide returns the value of a reference, its scheme included, e.g. broker.team/registry
+	Provide(key string, args ...interface{}) (string, error)
+}
+
+// Configurer is implemented by providers configured from the recipe, with the
+// secretProviders entry named after their scheme
+type Configurer interface {
+	Configure(config map[string]interface{}) error
+}
+
+// ProviderFunc adapts a function to a Provider
+type ProviderFunc func(key string, args ...interface{}) (string, error)
+
+// Provide calls f(key, args...)
+func (f ProviderFunc) Provide(key string, args ...interface{}) (string, error) {
+	return f(key, args...)
+}
+
+type registration struct {
+	scheme   string
+	prefix   string
+	provider Provider
+}
+
+var (
+	registryMu sync.RWMutex
+	providers  []registration
+	// values caches the resolved references until a provider is configured again
+	values map[string]string
+)
+
+func init() {
+	register("env", "env.", withoutArgs(provideFromEnv))
+	register("file", filePrefix, withoutArgs(provideFromFile))
+	register("sops", "sops.", ProviderFunc(provideFromSops))
+	register("zkv", "zkv.", withoutArgs(provideFromZkv))
+	register("hcv", "hcv.", withoutArgs(provideFromHcv))
+	register("gcp", "gcp.", withoutArgs(provideFromGcp))
+	register("aws", "aws.", withoutArgs(provideFromAws))
+	register("azure", "azure.", withoutArgs(provideFromAzure))
+	register("k8s", "k8s.", withoutArgs(provideFromK8s))
+}
+
+// Register adds the provider of the references starting with scheme and a dot,
+// e.g. broker for broker.team/registry. Schemes are registered once.
+func Register(scheme string, p Provider) error {
+	if scheme == "" || strings.ContainsAny(scheme, "./:#") {
+		return fmt.Errorf("invalid secret scheme %q", scheme)
+	}
+	if p == nil {
+		return fmt.Errorf("secret scheme %s has no provider", scheme)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := findScheme(scheme); ok {
+		return fmt.Errorf("secret scheme %s is already registered", scheme)
+	}
+	register(scheme, scheme+".", p)
+	return nil
+}
+
+// register adds a provider, registryMu must be held by callers after init
+func register(scheme, prefix string, p Provider) {
+	providers = append(providers, registration{scheme: scheme, prefix: prefix, provider: p})
+	values = nil
+}
+
+// Configure hands the configuration of a recipe secretProviders entry to the
+// provider of scheme, and clears the cached values
+func Configure(scheme string, config map[string]interface{}) error {
+	registryMu.RLock()
+	r, ok := findScheme(scheme)
+	registryMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown secret provider %s, available schemes: %s", scheme, strings.Join(Schemes(), ", "))
+	}
+	c, ok := r.provider.(Configurer)
+	if !ok {
+		return fmt.Errorf("secret provider %s takes no configuration", scheme)
+	}
+	if err := c.Configure(config); err != nil {
+		return fmt.Errorf("failed to configure secret provider %s \n %w", scheme, err)
+	}
+	ClearCache()
+	return nil
+}
+
+// Schemes returns the registered schemes, sorted
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	schemes := make([]string, 0, len(providers))
+	for _, r := range providers {
+		schemes = append(schemes, r.scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// IsReference reports whether v is a reference to a registered scheme
+func IsReference(v string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := findProvider(v)
+	return ok
+}
+
+// ClearCache forgets the resolved references, so they are read again
+func ClearCache() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	values = nil
+}
+
+// findScheme returns the registration of scheme, registryMu must be held
+func findScheme(scheme string) (registration, bool) {
+	for _, r := range providers {
+		if r.scheme == scheme {
+			return r, true
+		}
+	}
+	return registration{}, false
+}
+
+// findProvider returns the registration whose prefix starts key, registryMu must be held
+func findProvider(key string) (registration, bool) {
+	for _, r := range providers {
+		if strings.HasPrefix(key, r.prefix) {
+			return r, true
+		}
+	}
+	return registration{}, false
+}
+
+// cached returns the resolved reference of id
+func cached(id string) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	v, ok := values[id]
+	return v, ok
+}
+
+func cache(id, v string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if values == nil {
+		values = make(map[string]string)
+	}
+	values[id] = v
+}
+
+// cacheId tells apart the references resolved with other args, e.g. another sops key file
+func cacheId(key string, args []interface{}) string {
+	if len(args) == 0 {
+		return key
+	}
+	return key + "\x00" + fmt.Sprint(args...)
+}
+
+func withoutArgs(f func(key string) (string, error)) Provider {
+	return ProviderFunc(func(key string, _ ...interface{}) (string, error) {
+		return f(key)
+	})
+}
@@ -0,0 +1,147 @@
+package secret
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// broker stands in for a custom provider, e.g. an in-house credential broker
+type broker struct {
+	url   interface{}
+	reads int
+}
+
+func (b *broker) Configure(config map[string]interface{}) error {
+	if config["url"] == nil {
+		return fmt.Errorf("url is required")
+	}
+	b.url = config["url"]
+	return nil
+}
+
+func (b *broker) Provide(key string, args ...interface{}) (string, error) {
+	b.reads++
+	return fmt.Sprintf("%s@%s%v", strings.TrimPrefix(key, "broker."), b.url, args), nil
+}
+
+// registerBroker registers b for the test, the registry has no way to remove schemes
+func registerBroker(t *testing.T, b *broker) {
+	t.Helper()
+	registryMu.Lock()
+	registered := slices.Clone(providers)
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		providers = registered
+		values = nil
+	})
+	if err := Register("broker", b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	b := &broker{}
+	registerBroker(t, b)
+
+	for _, scheme := range []string{"broker", "hcv", "my.broker", "broker:", ""} {
+		if err := Register(scheme, b); err == nil {
+			t.Errorf("Register(%q) error = nil, want an error", scheme)
+		}
+	}
+	if err := Register("vendor", nil); err == nil {
+		t.Error("Register() accepted a scheme without a provider")
+	}
+
+	schemes := Schemes()
+	if !slices.IsSorted(schemes) || !slices.Contains(schemes, "broker") || !slices.Contains(schemes, "hcv") {
+		t.Errorf("Schemes() = %v, want the sorted built-in and broker schemes", schemes)
+	}
+	for ref, want := range map[string]bool{
+		"broker.team/registry": true,
+		"brokers.team":         false,
+		"hcv.secret/registry":  true,
+		"plain-value":          false,
+	} {
+		if got := IsReference(ref); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	b := &broker{}
+	registerBroker(t, b)
+
+	if err := Configure("broker", map[string]interface{}{"url": "https://broker.internal"}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if got, err := Provide("broker.team/registry"); err != nil || got != "team/registry@https://broker.internal[]" {
+		t.Errorf("Provide() = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name    string
+		scheme  string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{name: "unknown scheme", scheme: "brokr", wantErr: "available schemes: aws, azure, broker, env"},
+		{name: "invalid config", scheme: "broker", config: map[string]interface{}{"token": "t0ken"}, wantErr: "url is required"},
+		{name: "unconfigurable", scheme: "hcv", config: map[string]interface{}{"address": "https://vault.internal"}, wantErr: "takes no configuration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure(tt.scheme, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Configure() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvideCache(t *testing.T) {
+	b := &broker{}
+	registerBroker(t, b)
+	if err := Configure("broker", map[string]interface{}{"url": "https://broker.internal"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Provide("broker.team/registry"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.reads != 1 {
+		t.Errorf("broker reads = %d, want the value cached after 1", b.reads)
+	}
+
+	// other args are another value
+	if got, _ := Provide("broker.team/registry", "ci"); got != "team/registry@https://broker.internal[ci]" {
+		t.Errorf("Provide() with args = %q", got)
+	}
+	if b.reads != 2 {
+		t.Errorf("broker reads = %d, want 2", b.reads)
+	}
+
+	ClearCache()
+	if _, err := Provide("broker.team/registry"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure("broker", map[string]interface{}{"url": "https://broker.internal"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Provide("broker.team/registry"); err != nil {
+		t.Fatal(err)
+	}
+	if b.reads != 4 {
+		t.Errorf("broker reads = %d, want the value read again after clearing and configuring", b.reads)
+	}
+
+	if got, err := Provide("plain-value"); err != nil || got != "plain-value" {
+		t.Errorf("Provide() = %q, %v, want the value itself", got, err)
+	}
+}
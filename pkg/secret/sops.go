@@ -0,0 +1,101 @@
+package secret
+
+import (
+	"fmt"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/zcubbs/hotpot/pkg/crypto/sops"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SopsConfig configures the sops. provider
+type SopsConfig struct {
+	// AgeKeyFile and PgpKeyFile decrypt the files, besides the default sops age
+	// identities and GnuPG keyring
+	AgeKeyFile string
+	PgpKeyFile string
+	// BaseDir resolves relative file paths, e.g. the recipe directory
+	BaseDir string
+}
+
+var (
+	sopsMu     sync.Mutex
+	sopsConfig SopsConfig
+	// sopsFiles caches the decrypted files until the provider is configured again
+	sopsFiles map[string][]byte
+)
+
+// ConfigureSops sets the configuration of the sops. provider and clears the files it decrypted
+func ConfigureSops(config SopsConfig) {
+	sopsMu.Lock()
+	defer sopsMu.Unlock()
+	sopsConfig = config
+	sopsFiles = nil
+	ClearCache()
+}
+
+// provideFromSops returns the value of a sops.<file>#<path> reference: the
+// dotted path of a yaml or json file, the variable of a dotenv file, or the
+// whole file without a path. args[0] may be the age or pgp key file decrypting it.
+func provideFromSops(key string, args ...interface{}) (string, error) {
+	path, selector, _ := strings.Cut(strings.TrimPrefix(key, "sops."), "#")
+	path, err := expandPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret from sops \n %w", err)
+	}
+
+	sopsMu.Lock()
+	defer sopsMu.Unlock()
+	if !filepath.IsAbs(path) && sopsConfig.BaseDir != "" {
+		path = filepath.Join(sopsConfig.BaseDir, path)
+	}
+
+	keys := sops.Keys{AgeKeyFile: sopsConfig.AgeKeyFile, PgpKeyFile: sopsConfig.PgpKeyFile}
+	cacheKey := path
+	if len(args) > 0 {
+		if keyPath, ok := args[0].(string); ok && keyPath != "" {
+			if keys, err = sops.KeysFromFile(keyPath); err != nil {
+				return "", fmt.Errorf("failed to get secret from sops \n %w", err)
+			}
+			cacheKey = path + "#" + keyPath
+		}
+	}
+
+	plaintext, ok := sopsFiles[cacheKey]
+	if !ok {
+		plaintext, err = sops.DecryptFile(path, keys)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret from sops file %s \n %w", path, err)
+		}
+		if sopsFiles == nil {
+			sopsFiles = make(map[string][]byte)
+		}
+		sopsFiles[cacheKey] = plaintext
+	}
+
+	if selector == "" {
+		return strings.TrimRight(string(plaintext), "\r\n"), nil
+	}
+	switch formats.FormatForPath(path) {
+	case formats.Yaml, formats.Json:
+		doc, err := parseDocument(plaintext)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse sops file %s \n %w", path, err)
+		}
+		v, err := lookup(doc, selector)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret %s from sops file %s \n %w", selector, path, err)
+		}
+		return v, nil
+	case formats.Dotenv:
+		for _, line := range strings.Split(string(plaintext), "\n") {
+			if k, v, found := strings.Cut(line, "="); found && k == selector {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("failed to get secret %s from sops file %s: not found", selector, path)
+	default:
+		return "", fmt.Errorf("failed to get secret %s from sops file %s: only yaml, json and dotenv files have keys", selector, path)
+	}
+}
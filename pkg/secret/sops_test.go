@@ -0,0 +1,158 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/rand"
+	"filippo.io/age"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/pgp"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sopsEncrypt encrypts plain like sops --encrypt for the age recipient and pgp
+// key, writing the sops file to name in dir
+func sopsEncrypt(t *testing.T, dir, name, plain, ageRecipient string, pgpKey *openpgp.Entity) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	store := common.StoreForFormat(formats.FormatForPath(path), config.NewStoresConfig())
+	branches, err := store.LoadPlainFile([]byte(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var group sops.KeyGroup
+	if ageRecipient != "" {
+		key, err := sopsage.MasterKeyFromRecipient(ageRecipient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group = append(group, key)
+	}
+	if pgpKey != nil {
+		var pubRing bytes.Buffer
+		if err := pgpKey.Serialize(&pubRing); err != nil {
+			t.Fatal(err)
+		}
+		ringPath := filepath.Join(t.TempDir(), "pubring.gpg")
+		if err := os.WriteFile(ringPath, pubRing.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		key := pgp.NewMasterKeyFromFingerprint(fmt.Sprintf("%X", pgpKey.PrimaryKey.Fingerprint))
+		pgp.PubRing(ringPath).ApplyToMasterKey(key)
+		group = append(group, key)
+	}
+
+	// the keys encrypt the data key themselves, the sops key services would drop the pubring
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range group {
+		if err := key.Encrypt(dataKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree := sops.Tree{Branches: branches, Metadata: sops.Metadata{KeyGroups: []sops.KeyGroup{group}, Version: "3.9.0"}}
+	if err := common.EncryptTree(common.EncryptTreeOpts{Tree: &tree, Cipher: aes.NewCipher(), DataKey: dataKey}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := store.EmitEncryptedFile(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSops(t *testing.T) {
+	dir := t.TempDir()
+	// keep the decryption away from the keys of the machine running the tests
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GNUPGHOME", t.TempDir())
+	t.Setenv("SOPS_AGE_KEY", "")
+	_ = os.Unsetenv("SOPS_AGE_KEY")
+	defer ConfigureSops(SopsConfig{})
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ageKeyFile := filepath.Join(dir, "age.txt")
+	if err := os.WriteFile(ageKeyFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY_FILE", ageKeyFile)
+
+	pgpKey, err := openpgp.NewEntity("hotpot", "", "ops@hotpot.local", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pgpKey.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	pgpKeyFile := filepath.Join(dir, "pgp.asc")
+	if err := os.WriteFile(pgpKeyFile, armored.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sopsEncrypt(t, dir, "secrets.enc.yaml", "registry:\n  password: p4ss\n  port: 5000\n", "", pgpKey)
+	sopsEncrypt(t, dir, "dns.enc.env", "OVH_CONSUMER_KEY=ck\n", identity.Recipient().String(), nil)
+	sopsEncrypt(t, dir, "token.enc.json", `{"token": "t0ken"}`, identity.Recipient().String(), nil)
+
+	ConfigureSops(SopsConfig{PgpKeyFile: pgpKeyFile, BaseDir: dir})
+
+	tests := []struct {
+		key     string
+		args    []interface{}
+		want    string
+		wantErr bool
+	}{
+		{key: "sops.secrets.enc.yaml#registry.password", want: "p4ss"},
+		{key: "sops.secrets.enc.yaml#registry.port", want: "5000"},
+		{key: "sops." + filepath.Join(dir, "dns.enc.env") + "#OVH_CONSUMER_KEY", want: "ck"},
+		{key: "sops.dns.enc.env", want: "OVH_CONSUMER_KEY=ck"},
+		{key: "sops.token.enc.json#token", want: "t0ken"},
+		{key: "sops.secrets.enc.yaml#registry.user", wantErr: true},
+		{key: "sops.dns.enc.env#OVH_APPLICATION_KEY", wantErr: true},
+		// the age key given by the caller cannot decrypt the pgp secrets
+		{key: "sops.secrets.enc.yaml#registry.password", args: []interface{}{ageKeyFile}, wantErr: true},
+		{key: "sops.dns.enc.env#OVH_CONSUMER_KEY", args: []interface{}{ageKeyFile}, want: "ck"},
+		{key: "sops.missing.enc.yaml#password", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := Provide(tt.key, tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// without the pgp key the pgp secrets cannot be decrypted
+	ConfigureSops(SopsConfig{BaseDir: dir})
+	if _, err := Provide("sops.secrets.enc.yaml#registry.password"); err == nil {
+		t.Error("Provide() decrypted a pgp secret without its key")
+	}
+}
@@ -0,0 +1,351 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+
+	// vaultJwtPath is the service account token of a pod, used by the kubernetes auth method
+	vaultJwtPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// VaultConfig configures the hcv. provider. Empty fields fall back to the
+// environment variables of the vault CLI: VAULT_ADDR, VAULT_NAMESPACE, VAULT_TOKEN,
+// VAULT_CACERT, plus VAULT_ROLE_ID, VAULT_SECRET_ID and VAULT_ROLE.
+// Token and SecretId may be secret references, e.g. file:///etc/hotpot/vault-token.
+type VaultConfig struct {
+	Address   string
+	Namespace string
+	// AuthMethod is token, approle or kubernetes. When empty it is approle with
+	// a RoleId, kubernetes with a Role and token otherwise.
+	AuthMethod string
+	// AuthMount is the path the auth method is mounted at, defaults to the method name
+	AuthMount string
+	Token     string
+	RoleId    string
+	SecretId  string
+	Role      string
+	// JwtPath is the service account token read by the kubernetes auth method
+	JwtPath string
+	// KvVersion is the version of the kv engines, 1 or 2. When 0 it is looked up for each mount.
+	KvVersion int
+	CaCert    string
+}
+
+var (
+	vaultMu     sync.Mutex
+	vaultConfig VaultConfig
+	vault       *vaultClient
+)
+
+// ConfigureVault sets the configuration of the hcv. provider, the next
+// reference logs in again
+func ConfigureVault(config VaultConfig) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	vaultConfig = config
+	vault = nil
+	ClearCache()
+}
+
+// provideFromHcv returns the value of a hcv.<path>#<key> reference, e.g.
+// hcv.secret/registry#password. The key may be left out of secrets holding a single key.
+func provideFromHcv(key string) (string, error) {
+	path, field, _ := strings.Cut(strings.TrimPrefix(key, "hcv."), "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("failed to get secret from hcv: %s has no path", key)
+	}
+
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vault == nil {
+		c, err := newVaultClient(vaultConfig)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret from hcv \n %w", err)
+		}
+		vault = c
+	}
+
+	data, err := vault.read(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s from hcv \n %w", path, err)
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("failed to get secret from hcv: %s holds %d keys, select one with %s#<key>", path, len(data), key)
+		}
+		for k := range data {
+			field = k
+		}
+	}
+	v, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("failed to get secret from hcv: key %s not found in %s", field, path)
+	}
+	return scalar(v)
+}
+
+type vaultClient struct {
+	config VaultConfig
+	http   *http.Client
+	token  string
+	// mounts caches the kv mounts by the path they are mounted at
+	mounts map[string]int
+}
+
+func newVaultClient(config VaultConfig) (*vaultClient, error) {
+	config = vaultConfigFromEnv(config)
+	if config.Address == "" {
+		return nil, fmt.Errorf("vault address is not set, configure secretProviders.vault.address or VAULT_ADDR")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CaCert != "" {
+		pem, err := os.ReadFile(config.CaCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault ca cert \n %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", config.CaCert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	c := &vaultClient{
+		config: config,
+		http:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		mounts: make(map[string]int),
+	}
+	if err := c.login(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func vaultConfigFromEnv(c VaultConfig) VaultConfig {
+	env := func(v *string, name string) {
+		if *v == "" {
+			*v = os.Getenv(name)
+		}
+	}
+	env(&c.Address, "VAULT_ADDR")
+	env(&c.Namespace, "VAULT_NAMESPACE")
+	env(&c.Token, "VAULT_TOKEN")
+	env(&c.CaCert, "VAULT_CACERT")
+	env(&c.RoleId, "VAULT_ROLE_ID")
+	env(&c.SecretId, "VAULT_SECRET_ID")
+	env(&c.Role, "VAULT_ROLE")
+	c.Address = strings.TrimRight(c.Address, "/")
+
+	if c.AuthMethod == "" {
+		switch {
+		case c.RoleId != "":
+			c.AuthMethod = VaultAuthAppRole
+		case c.Role != "":
+			c.AuthMethod = VaultAuthKubernetes
+		default:
+			c.AuthMethod = VaultAuthToken
+		}
+	}
+	if c.AuthMount == "" {
+		c.AuthMount = c.AuthMethod
+	}
+	if c.JwtPath == "" {
+		c.JwtPath = vaultJwtPath
+	}
+	return c
+}
+
+func (c *vaultClient) login() error {
+	var body map[string]string
+	switch c.config.AuthMethod {
+	case VaultAuthToken:
+		token, err := resolveCredential(c.config.Token)
+		if err != nil {
+			return fmt.Errorf("failed to resolve vault token \n %w", err)
+		}
+		if token == "" {
+			token = tokenHelper()
+		}
+		if token == "" {
+			return fmt.Errorf("vault token is not set, configure secretProviders.vault.token or VAULT_TOKEN")
+		}
+		c.token = token
+		return nil
+	case VaultAuthAppRole:
+		secretId, err := resolveCredential(c.config.SecretId)
+		if err != nil {
+			return fmt.Errorf("failed to resolve vault secret id \n %w", err)
+		}
+		body = map[string]string{"role_id": c.config.RoleId, "secret_id": secretId}
+	case VaultAuthKubernetes:
+		jwt, err := os.ReadFile(c.config.JwtPath)
+		if err != nil {
+			return fmt.Errorf("failed to read service account token \n %w", err)
+		}
+		body = map[string]string{"role": c.config.Role, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("unknown vault auth method %s, use token, approle or kubernetes", c.config.AuthMethod)
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := c.do(http.MethodPost, "auth/"+strings.Trim(c.config.AuthMount, "/")+"/login", body, &resp); err != nil {
+		return fmt.Errorf("failed to log in to vault with %s \n %w", c.config.AuthMethod, err)
+	}
+	if resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", c.config.AuthMethod)
+	}
+	c.token = resp.Auth.ClientToken
+	return nil
+}
+
+// read returns the data of the kv secret at path, which does not hold the
+// data/ segment of kv v2 engines
+func (c *vaultClient) read(path string) (map[string]interface{}, error) {
+	mount, version, err := c.mount(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 2 {
+		var resp struct {
+			Data struct {
+				Data map[string]interface{} `json:"data"`
+			} `json:"data"`
+		}
+		rest := strings.TrimPrefix(strings.TrimPrefix(path, mount), "data/")
+		if err := c.do(http.MethodGet, mount+"data/"+rest, nil, &resp); err != nil {
+			return nil, err
+		}
+		return resp.Data.Data, nil
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := c.do(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// mount returns the mount path, with a trailing slash, and the kv version of path
+func (c *vaultClient) mount(path string) (string, int, error) {
+	for mount, version := range c.mounts {
+		if strings.HasPrefix(path, mount) {
+			return mount, version, nil
+		}
+	}
+
+	if c.config.KvVersion != 0 {
+		// without a lookup the mount is the first segment, e.g. secret/
+		mount, _, _ := strings.Cut(path, "/")
+		c.mounts[mount+"/"] = c.config.KvVersion
+		return mount + "/", c.config.KvVersion, nil
+	}
+
+	var resp struct {
+		Data struct {
+			Path    string            `json:"path"`
+			Options map[string]string `json:"options"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodGet, "sys/internal/ui/mounts/"+path, nil, &resp); err != nil {
+		return "", 0, fmt.Errorf("failed to look up the kv version of %s, set secretProviders.vault.kvVersion \n %w", path, err)
+	}
+	version := 1
+	if resp.Data.Options["version"] == "2" {
+		version = 2
+	}
+	c.mounts[resp.Data.Path] = version
+	return resp.Data.Path, version, nil
+}
+
+func (c *vaultClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.config.Address+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(b, &e) == nil && len(e.Errors) > 0 {
+			return fmt.Errorf("vault %s %s: %d %s", method, path, resp.StatusCode, strings.Join(e.Errors, ", "))
+		}
+		return fmt.Errorf("vault %s %s: %d", method, path, resp.StatusCode)
+	}
+	return json.Unmarshal(b, out)
+}
+
+// tokenHelper returns the token the vault CLI stored at login
+func tokenHelper() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// resolveCredential resolves a provider credential that may itself be an
+// env. or file:// reference
+func resolveCredential(v string) (string, error) {
+	if strings.HasPrefix(v, "hcv.") {
+		return "", fmt.Errorf("vault credentials can not be read from vault")
+	}
+	if v == "" {
+		return "", nil
+	}
+	return Provide(v)
+}
@@ -0,0 +1,98 @@
+package secret
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// vaultStandIn serves the kv v2 mount secret/ and the kv v1 mount kv/, with
+// approle and kubernetes logins
+func vaultStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	write := func(w http.ResponseWriter, v interface{}) {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.URL.Path == "/v1/auth/approle/login" && body["role_id"] == "hotpot" && body["secret_id"] == "s3cr3t":
+			write(w, map[string]interface{}{"auth": map[string]string{"client_token": "approle-token"}})
+		case r.URL.Path == "/v1/auth/k8s/login" && body["role"] == "hotpot" && body["jwt"] == "sa-jwt":
+			write(w, map[string]interface{}{"auth": map[string]string{"client_token": "k8s-token"}})
+		case r.Header.Get("X-Vault-Token") == "":
+			w.WriteHeader(http.StatusForbidden)
+			write(w, map[string][]string{"errors": {"permission denied"}})
+		case strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/secret/"):
+			write(w, map[string]interface{}{"data": map[string]interface{}{"path": "secret/", "options": map[string]string{"version": "2"}}})
+		case strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/kv/"):
+			write(w, map[string]interface{}{"data": map[string]interface{}{"path": "kv/", "options": nil}})
+		case r.URL.Path == "/v1/secret/data/registry":
+			write(w, map[string]interface{}{"data": map[string]interface{}{"data": map[string]string{"username": "robot", "password": "p4ss"}}})
+		case r.URL.Path == "/v1/kv/token":
+			write(w, map[string]interface{}{"data": map[string]string{"value": "t0ken"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			write(w, map[string][]string{"errors": {}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVault(t *testing.T) {
+	server := vaultStandIn(t)
+	defer ConfigureVault(VaultConfig{})
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwt, []byte("sa-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOTPOT_VAULT_SECRET_ID", "s3cr3t")
+
+	configs := map[string]VaultConfig{
+		"token":      {Address: server.URL, Token: "root"},
+		"approle":    {Address: server.URL, RoleId: "hotpot", SecretId: "env.HOTPOT_VAULT_SECRET_ID"},
+		"kubernetes": {Address: server.URL, AuthMethod: VaultAuthKubernetes, AuthMount: "k8s", Role: "hotpot", JwtPath: jwt},
+	}
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "hcv.secret/registry#username", want: "robot"},
+		{key: "hcv.secret/data/registry#password", want: "p4ss"},
+		{key: "hcv.kv/token", want: "t0ken"},
+		// several keys and none selected
+		{key: "hcv.secret/registry", wantErr: true},
+		{key: "hcv.secret/registry#email", wantErr: true},
+		{key: "hcv.secret/missing#password", wantErr: true},
+		{key: "hcv.", wantErr: true},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			ConfigureVault(config)
+			for _, tt := range tests {
+				got, err := Provide(tt.key)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Provide(%s) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("Provide(%s) = %q, want %q", tt.key, got, tt.want)
+				}
+			}
+		})
+	}
+
+	ConfigureVault(VaultConfig{Address: server.URL, RoleId: "hotpot", SecretId: "wrong"})
+	if _, err := Provide("hcv.kv/token"); err == nil || !strings.Contains(err.Error(), "approle") {
+		t.Errorf("Provide() with a wrong secret id error = %v, want an approle login error", err)
+	}
+}
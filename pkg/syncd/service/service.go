@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/zcubbs/hotpot/pkg/x/bash"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const (
@@ -181,6 +183,26 @@ func Status() (string, error) {
 	}
 }
 
+// State returns the service state, e.g. active, inactive or failed
+func State() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if err := exec.Command("launchctl", "list", "com.zcubbs.hotpot.syncd").Run(); err != nil {
+			return "not loaded", nil
+		}
+		return "loaded", nil
+
+	default: // Linux
+		// is-active exits non-zero for any state but active, the state is still printed
+		output, err := exec.Command("systemctl", "is-active", serviceName).Output()
+		state := strings.TrimSpace(string(output))
+		if state == "" {
+			return "", fmt.Errorf("failed to get service state: %w", err)
+		}
+		return state, nil
+	}
+}
+
 // reloadDaemon reloads the systemd daemon
 func reloadDaemon() error {
 	return bash.ExecuteCmd("systemctl", false, "daemon-reload")
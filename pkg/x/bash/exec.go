@@ -7,6 +7,7 @@ package bash
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -72,3 +73,20 @@ func ExecuteCmdWithOutput(command string, args ...string) (string, error) {
 	}
 	return stdout.String(), nil
 }
+
+// ExecuteCmdWithEnv executes a command with env added to the environment and
+// returns the output, keeping values such as credentials off the process list
+func ExecuteCmdWithEnv(command string, env []string, args ...string) (string, error) {
+	var stdout, stderr strings.Builder
+
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return stderr.String(), err
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,182 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var release = []byte(strings.Repeat("hotpot release binary\n", 512))
+
+func sum(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// releaseServer serves release and its manifests, honoring range requests
+func releaseServer(t *testing.T, ranges *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/hotpot":
+			if ranges != nil {
+				*ranges = append(*ranges, r.Header.Get("Range"))
+			}
+			http.ServeContent(w, r, "hotpot", time.Time{}, bytes.NewReader(release))
+		case "/v1/sha256sum.txt":
+			_, _ = w.Write([]byte(sum([]byte("other")) + "  other\n" + sum(release) + " *dist/hotpot\n"))
+		case "/v1/bad.txt":
+			_, _ = w.Write([]byte(sum([]byte("tampered")) + "  hotpot\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch(t *testing.T) {
+	server := releaseServer(t, nil)
+
+	tests := []struct {
+		name    string
+		a       Artifact
+		wantErr error
+	}{
+		{name: "pinned sum", a: Artifact{Sha256: sum(release)}},
+		{name: "manifest", a: Artifact{ChecksumURL: server.URL + "/v1/sha256sum.txt"}},
+		{name: "bad sum", a: Artifact{Sha256: sum([]byte("tampered"))}, wantErr: ErrChecksumMismatch},
+		{name: "bad manifest", a: Artifact{ChecksumURL: server.URL + "/v1/bad.txt"}, wantErr: ErrChecksumMismatch},
+		{name: "missing sum", a: Artifact{}, wantErr: ErrNoChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CacheDir = t.TempDir()
+			tt.a.Name, tt.a.Version, tt.a.URL = "hotpot", "v1", server.URL+"/v1/hotpot"
+
+			file, err := Fetch(tt.a, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				entries, _ := filepath.Glob(filepath.Join(CacheDir, "hotpot", "v1", "*"))
+				if len(entries) > 0 {
+					t.Errorf("Fetch() left %v in the cache", entries)
+				}
+				return
+			}
+			b, err := os.ReadFile(file)
+			if err != nil || !bytes.Equal(b, release) {
+				t.Errorf("Fetch() = %s, want the release", file)
+			}
+		})
+	}
+}
+
+func TestFetchResume(t *testing.T) {
+	var ranges []string
+	server := releaseServer(t, &ranges)
+	CacheDir = t.TempDir()
+	a := Artifact{Name: "hotpot", Version: "v1", URL: server.URL + "/v1/hotpot", Sha256: sum(release)}
+
+	// an interrupted download left the first half
+	dir := filepath.Join(CacheDir, "hotpot", "v1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	half := len(release) / 2
+	if err := os.WriteFile(filepath.Join(dir, "hotpot.part"), release[:half], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Fetch(a, false)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != fmt.Sprintf("bytes=%d-", half) {
+		t.Errorf("requested ranges = %q, want the second half", ranges)
+	}
+	if b, _ := os.ReadFile(file); !bytes.Equal(b, release) {
+		t.Error("resumed file does not match the release")
+	}
+
+	// verified files are served from the cache
+	if _, err := Fetch(a, false); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(ranges) != 1 {
+		t.Errorf("Fetch() downloaded a cached file again")
+	}
+}
+
+func TestFetchInsecure(t *testing.T) {
+	var ranges []string
+	server := releaseServer(t, &ranges)
+	CacheDir = t.TempDir()
+	t.Setenv("TMPDIR", t.TempDir())
+	a := Artifact{Name: "hotpot", Version: "v1", URL: server.URL + "/v1/hotpot", Insecure: true}
+
+	for i := 0; i < 2; i++ {
+		file, err := Fetch(a, false)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if b, _ := os.ReadFile(file); !bytes.Equal(b, release) {
+			t.Error("Fetch() does not return the release")
+		}
+	}
+	if len(ranges) != 2 {
+		t.Errorf("downloads = %d, want unverified files fetched every time", len(ranges))
+	}
+	if entries, _ := os.ReadDir(CacheDir); len(entries) > 0 {
+		t.Errorf("Fetch() cached an unverified file")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hotpot")
+	if err := os.WriteFile(file, release, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(file, strings.ToUpper(sum(release))); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify(file, sum([]byte("tampered"))); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Verify() error = %v, want a mismatch", err)
+	}
+	if err := Verify(file, ""); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("Verify() error = %v, want no checksum", err)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		file     string
+		want     string
+	}{
+		{name: "sha256sum", manifest: "aaa  k3s\nbbb  k3s-arm64\n", file: "k3s-arm64", want: "bbb"},
+		{name: "binary mode", manifest: "aaa *helm-v3.16.4-linux-amd64.tar.gz\n", file: "helm-v3.16.4-linux-amd64.tar.gz", want: "aaa"},
+		{name: "directories", manifest: "aaa  dist/k9s_Linux_amd64.tar.gz\n", file: "k9s_Linux_amd64.tar.gz", want: "aaa"},
+		{name: "bare digest", manifest: "aaa\n", file: "helm.tar.gz", want: "aaa"},
+		{name: "missing", manifest: "aaa  k3s\nbbb  k3s-arm64\n", file: "k3s-armhf", want: ""},
+		{name: "empty", manifest: "", file: "k3s", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseManifest(tt.manifest, tt.file); got != tt.want {
+				t.Errorf("ParseManifest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
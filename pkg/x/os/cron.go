@@ -1,6 +1,7 @@
 package os
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -41,3 +42,46 @@ func AddCronJob(cronJob string) error {
 
 	return nil
 }
+
+// RemoveCronJobs removes every line of the current user's crontab containing marker.
+// A missing crontab, or crontab command, has nothing to remove.
+func RemoveCronJobs(marker string) error {
+	currentCron, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "no crontab for") {
+			return nil
+		}
+		if exitErr != nil {
+			return fmt.Errorf("failed to read crontab. Error: %v. Output: %s", err, exitErr.Stderr)
+		}
+		return fmt.Errorf("failed to read crontab \n %w", err)
+	}
+	cronOutput := string(currentCron)
+
+	var lines []string
+	for _, line := range strings.Split(cronOutput, "\n") {
+		if strings.Contains(line, marker) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	updatedCron := strings.Join(lines, "\n")
+	if updatedCron == cronOutput {
+		return nil
+	}
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(updatedCron)
+
+	combinedOutput, execErr := cmd.CombinedOutput()
+	if execErr != nil {
+		return fmt.Errorf("failed to remove cron jobs. Error: %v. Output: %s", execErr, combinedOutput)
+	}
+
+	return nil
+}